@@ -0,0 +1,52 @@
+// 附件存储清理
+// 对比存储和数据库，列出没有对应申请的目录、没有记录引用的文件、记录存在但文件缺失的附件，以及多次重试仍失败的删除任务
+// 用法：go run ./cmd/gc [-min-age 24h] [-delete]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"os"
+	"time"
+)
+
+func main() {
+	minAge := flag.Duration("min-age", 24*time.Hour, "只处理修改时间早于此时长的文件，避免误删正在上传的文件")
+	remove := flag.Bool("delete", false, "删除孤儿目录和孤儿文件，重新执行失败的删除任务")
+	flag.Parse()
+
+	garbage, err := models.CollectStorageGarbage(context.Background(), *minAge)
+	if err != nil {
+		utils.Logger.Error("检查附件存储失败: " + err.Error())
+		os.Exit(2)
+	}
+
+	unresolved := 0
+	for _, g := range garbage {
+		status := "未处理"
+		fixed := false
+		if *remove {
+			fixed, err = models.FixStorageGarbage(context.Background(), g)
+			switch {
+			case err != nil:
+				status = "处理失败: " + err.Error()
+				fixed = false
+			case fixed:
+				status = "已处理"
+			default:
+				status = "需人工核实"
+			}
+		}
+		if !fixed {
+			unresolved++
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", g.Kind, g.Key, g.Detail, status)
+	}
+	fmt.Printf("发现问题 %d 项，未解决 %d 项\n", len(garbage), unresolved)
+	if unresolved > 0 {
+		os.Exit(1)
+	}
+}
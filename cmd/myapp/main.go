@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"intellectual_property/internal/api"
 	"intellectual_property/pkg/models"
 	"intellectual_property/pkg/utils"
@@ -21,7 +22,46 @@ func main() {
 		&models.ArticleAuthor{},
 		&models.ArticleFee{},
 		&models.RouteStats{},
+		&models.WorkflowDefinition{},
+		&models.WorkflowStage{},
+		&models.ApprovalEvent{},
+		&models.Role{},
+		&models.Permission{},
+		&models.RolePermission{},
+		&models.UserRole{},
+		&models.LoginHistory{},
+		&models.PaymentOrder{},
+		&models.FeeAdjustment{},
+		&models.FeeTariff{},
+		&models.FeeDiscount{},
+		&models.Receipt{},
+		&models.Attachment{},
+		&models.StorageTask{},
+		&models.UploadSession{},
+		&models.UploadChunk{},
+		&models.PaymentCloseTask{},
 	)
+	// 初始化角色权限
+	if err := models.InitRBAC(); err != nil {
+		utils.Logger.Error(err.Error())
+	}
+	// 初始化默认审批流程
+	if err := models.InitWorkflows(); err != nil {
+		utils.Logger.Error(err.Error())
+	}
+	// 初始化费用标准
+	if err := models.InitFeeTariffs(); err != nil {
+		utils.Logger.Error(err.Error())
+	}
+
+	// 定时处理逾期费用，多实例部署时通过 redis 锁保证每个周期只执行一次
+	go utils.RunPeriodicJob(context.Background(), "fee_overdue", utils.FeeOverdueConfig.Interval, models.RunOverdueFeeJob)
+	// 定时重试删除记录后未能删除的文件
+	go utils.RunPeriodicJob(context.Background(), "storage_tasks", utils.StorageSettings.TaskInterval, models.RunStorageTaskJob)
+	// 定时重试本地关闭订单后未能关闭的渠道交易
+	go utils.RunPeriodicJob(context.Background(), "payment_close", utils.PaymentConfig.CloseInterval, models.RunPaymentCloseJob)
+	// 定时删除过期未完成的分片上传
+	go utils.RunPeriodicJob(context.Background(), "upload_cleanup", utils.UploadConfig.Resumable.CleanupInterval, models.RunUploadCleanupJob)
 
 	// 添加路由
 	api.InitApi(r)
@@ -0,0 +1,61 @@
+// 支付订单对账
+// 查询一段时间内创建的支付订单在支付渠道中的交易状态，列出与本地不一致的订单
+// 用法：go run ./cmd/reconcile -since 72h [-fix]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"os"
+	"time"
+)
+
+func main() {
+	since := flag.Duration("since", 72*time.Hour, "对账的时间范围，从当前时间往前推")
+	fix := flag.Bool("fix", false, "自动修复漏掉的支付通知和渠道已关闭的订单")
+	flag.Parse()
+
+	orders, err := models.GetPaymentOrdersSince(time.Now().Add(-*since))
+	if err != nil {
+		utils.Logger.Error("查询支付订单失败: " + err.Error())
+		os.Exit(2)
+	}
+
+	// 线下转账没有渠道交易可查，不参与对账
+	queriers := map[string]utils.TradeQuerier{}
+	for _, channel := range utils.PaymentChannels() {
+		if channel == utils.PaymentChannelOffline {
+			continue
+		}
+		p, _ := utils.GetPaymentProvider(channel)
+		queriers[channel] = p
+	}
+	mismatches := models.ReconcilePaymentOrders(context.Background(), orders, queriers)
+	unresolved := 0
+	for _, m := range mismatches {
+		status := "未处理"
+		fixed := false
+		if *fix {
+			fixed, err = models.FixReconcileMismatch(m)
+			switch {
+			case err != nil:
+				status = "修复失败: " + err.Error()
+			case fixed:
+				status = "已修复"
+			default:
+				status = "需人工核实"
+			}
+		}
+		if !fixed {
+			unresolved++
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", m.Order.OrderNo, m.Kind, m.Detail, status)
+	}
+	fmt.Printf("共对账 %d 笔订单，差异 %d 笔，未解决 %d 笔\n", len(orders), len(mismatches), unresolved)
+	if unresolved > 0 {
+		os.Exit(1)
+	}
+}
@@ -11,6 +11,7 @@ var Resp = utils.Resp
 func InitApi(r *gin.Engine) {
 	r.Use(models.MiddlewareRoute()) //使用中间件来实现对路由信息的统计
 	r.Use(models.InterceptRoute())
+	r.Use(utils.JWTMiddleware()) //登录校验，白名单见 app.public_routes
 	initUser(r)
 	initLogin(r)
 	initStatistics(r)
@@ -20,7 +21,11 @@ func InitApi(r *gin.Engine) {
 	// 新增初始化商标路由
 	initTrademark(r)
 	initRoute(r)
-	initAlipay(r) //支付宝支付
+	initAlipay(r)   //支付宝支付
+	initWorkflow(r) //审批流程配置
+	initRBAC(r)     //角色权限管理
+	initSecurity(r) //登录安全管理
+	initFee(r)      //费用退款减免、费用标准和统计
 	//拿到所有信息 --支持分页查询
 	routes := r.Routes()
 	for _, v := range routes {
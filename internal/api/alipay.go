@@ -3,11 +3,28 @@ package api
 import (
 	"github.com/gin-gonic/gin"
 	"intellectual_property/internal/service"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
 )
 
 func initAlipay(r *gin.Engine) {
 	g := r.Group("/pay")
 
-	//回调函数
+	//支付完成后的同步跳转
 	g.GET("/tosuccess", service.AlipayToSuccess)
+
+	//支付宝异步通知
+	g.POST("/notify", service.AlipayNotify)
+
+	//微信支付异步通知
+	g.POST("/wechat/notify", service.WechatPayNotify)
+
+	//上传线下转账凭证
+	g.POST("/offline/voucher", service.LimitUploadBody(utils.UploadVoucher, 1), service.UploadPaymentVoucher)
+
+	//财务确认线下转账到账
+	g.PUT("/offline/confirm", service.RequirePermission(models.PermFeeManage), service.ConfirmOfflinePayment)
+
+	//财务驳回线下转账凭证
+	g.PUT("/offline/reject", service.RequirePermission(models.PermFeeManage), service.RejectOfflinePayment)
 }
@@ -2,6 +2,8 @@ package api
 
 import (
 	"intellectual_property/internal/service"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,7 +12,7 @@ func initArticle(r *gin.Engine) {
 	group := r.Group("/article")
 
 	//新增申请
-	group.POST("/add", service.CreateArticle)
+	group.POST("/add", service.RequirePermission(models.PermAssetApply), service.LimitUploadBody(models.AssetTypeArticle, utils.MaxUploadFormFiles), service.CreateArticle)
 
 	//获取信息并模糊查询
 	group.GET("/get_articles", service.GetAllArticles)
@@ -21,11 +23,29 @@ func initArticle(r *gin.Engine) {
 	group.DELETE("/del_article", service.DeleteArticle)
 
 	//跟新审核状态
-	group.PUT("/update_article_aduit", service.UpdateArticleStatus)
+	group.PUT("/update_article_aduit", service.RequirePermission(models.PermApprovalReview), service.UpdateArticleStatus)
+
+	// 驳回后重新提交
+	group.PUT("/resubmit", service.RequirePermission(models.PermAssetApply), service.LimitUploadBody(models.AssetTypeArticle, utils.MaxUploadFormFiles), service.ResubmitArticle)
 
 	// 获取所有著作年费
-	group.GET("/get_fee_all", service.GetAllArticleFees)
+	group.GET("/get_fee_all", service.RequirePermission(models.PermFeeView), service.GetAllArticleFees)
+
+	// 著作年费支付宝支付
+	group.POST("/fee/pay", service.PayArticleFee)
+
+	// 申请著作费用退款
+	group.POST("/fee/refund", service.RefundArticleFee)
+
+	// 免除或减免著作费用
+	group.POST("/fee/waive", service.RequirePermission(models.PermFeeManage), service.WaiveArticleFee)
+
+	// 下载著作费用缴费收据
+	group.GET("/fee/:id/receipt", service.GetArticleFeeReceipt)
+
+	// 获取著作审批时间线
+	group.GET("/:id/timeline", service.GetArticleTimeline)
 
-	// 获取本月著作年费统计信息
-	group.GET("/get_monthly_fee_stats", service.GetMonthlyArticleFeeStatsService)
+	// 著作附件
+	initAttachment(group, models.AssetTypeArticle)
 }
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"intellectual_property/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// initAttachment 资产附件路由，专利、著作、商标共用
+func initAttachment(group *gin.RouterGroup, assetType string) {
+	// 查询附件，all=true 时包含历史版本
+	group.GET("/:id/attachments", service.ListAttachments(assetType))
+
+	// 上传单个附件
+	group.POST("/:id/attachments", service.LimitUploadBody(assetType, 1), service.AddAttachment(assetType))
+
+	// 替换附件，旧文件保留为历史版本
+	group.PUT("/:id/attachments/:attachmentId", service.LimitUploadBody(assetType, 1), service.ReplaceAttachment(assetType))
+
+	// 删除附件及历史版本
+	group.DELETE("/:id/attachments/:attachmentId", service.DeleteAttachment(assetType))
+
+	// 下载附件
+	group.GET("/:id/attachments/:attachmentId/download", service.DownloadAttachment(assetType))
+
+	// 分片上传：创建任务、查询已上传的分片、上传分片、合并为附件、取消
+	group.POST("/:id/uploads", service.CreateUpload(assetType))
+	group.GET("/:id/uploads/:uploadId", service.GetUpload(assetType))
+	group.PUT("/:id/uploads/:uploadId/chunks/:index", service.PutUploadChunk(assetType))
+	group.POST("/:id/uploads/:uploadId/complete", service.CompleteUpload(assetType))
+	group.DELETE("/:id/uploads/:uploadId", service.AbortUpload(assetType))
+}
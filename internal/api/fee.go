@@ -0,0 +1,41 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"intellectual_property/internal/service"
+	"intellectual_property/pkg/models"
+)
+
+func initFee(r *gin.Engine) {
+	group := r.Group("/fee")
+
+	// 查询费用调整记录（退款、免除、减免）
+	group.GET("/adjustments", service.RequirePermission(models.PermFeeView), service.GetFeeAdjustments)
+
+	// 审批通过退款申请
+	group.PUT("/adjustments/:id/approve", service.RequirePermission(models.PermFeeManage), service.ApproveFeeRefund)
+
+	// 驳回退款申请
+	group.PUT("/adjustments/:id/reject", service.RequirePermission(models.PermFeeManage), service.RejectFeeRefund)
+
+	// 费用标准
+	group.GET("/tariffs", service.RequirePermission(models.PermFeeView), service.GetFeeTariffs)
+	group.POST("/tariffs", service.RequirePermission(models.PermFeeManage), service.CreateFeeTariff)
+	group.DELETE("/tariffs/:id", service.RequirePermission(models.PermFeeManage), service.DeleteFeeTariff)
+
+	// 费用减缴政策
+	group.GET("/discounts", service.RequirePermission(models.PermFeeView), service.GetFeeDiscounts)
+	group.POST("/discounts", service.RequirePermission(models.PermFeeManage), service.CreateFeeDiscount)
+	group.PUT("/discounts/:id", service.RequirePermission(models.PermFeeManage), service.UpdateFeeDiscount)
+
+	fees := r.Group("/fees")
+
+	// 费用中心：跨专利、著作、商标查询费用
+	fees.GET("", service.RequirePermission(models.PermFeeView), service.ListFees)
+
+	// 我的费用
+	fees.GET("/mine", service.ListMyFees)
+
+	// 按区间统计费用
+	fees.GET("/stats", service.RequirePermission(models.PermFeeView), service.GetFeeStats)
+}
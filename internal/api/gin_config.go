@@ -30,6 +30,10 @@ func NewEngine(envType string) *gin.Engine {
 
 	// 创建引擎
 	engine := gin.New()
+	// 客户端 IP 用于登录失败计数和登录历史，只信任配置的反向代理转发的地址
+	if err := engine.SetTrustedProxies(utils.GinConfig.TrustedProxies); err != nil {
+		logger.Fatal("可信代理配置错误", zap.Error(err))
+	}
 
 	// 添加日志中间件
 	engine.Use(ginZapLogger(logger))   // 替换默认 Logger
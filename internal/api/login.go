@@ -10,4 +10,10 @@ func initLogin(r *gin.Engine) {
 	//登录接口
 	r.POST("/login", service.Login)
 
+	//刷新令牌
+	r.POST("/login/refresh", service.RefreshToken)
+
+	//退出登录
+	r.POST("/logout", service.Logout)
+
 }
@@ -2,6 +2,8 @@ package api
 
 import (
 	"intellectual_property/internal/service"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,7 +12,7 @@ func initPatent(r *gin.Engine) {
 	group := r.Group("/patent")
 
 	// 新建申请
-	group.POST("/add", service.CreatePatent)
+	group.POST("/add", service.RequirePermission(models.PermAssetApply), service.LimitUploadBody(models.AssetTypePatent, utils.MaxUploadFormFiles), service.CreatePatent)
 
 	// 获取信息并模糊查询
 	group.GET("/get_patents", service.GetAllPatents)
@@ -21,11 +23,29 @@ func initPatent(r *gin.Engine) {
 	group.DELETE("/del_patent", service.DeletePatent)
 
 	// 更新审核状态
-	group.PUT("/update_patent_status", service.UpdatePatentStatus)
+	group.PUT("/update_patent_status", service.RequirePermission(models.PermApprovalReview), service.UpdatePatentStatus)
+
+	// 驳回后重新提交
+	group.PUT("/resubmit", service.RequirePermission(models.PermAssetApply), service.LimitUploadBody(models.AssetTypePatent, utils.MaxUploadFormFiles), service.ResubmitPatent)
 
 	// 获取所有专利年费
-	group.GET("/get_fee_all", service.GetAllPatentFees)
+	group.GET("/get_fee_all", service.RequirePermission(models.PermFeeView), service.GetAllPatentFees)
+
+	// 专利年费支付宝支付
+	group.POST("/fee/pay", service.PayPatentFee)
+
+	// 申请专利费用退款
+	group.POST("/fee/refund", service.RefundPatentFee)
+
+	// 免除或减免专利费用
+	group.POST("/fee/waive", service.RequirePermission(models.PermFeeManage), service.WaivePatentFee)
+
+	// 下载专利费用缴费收据
+	group.GET("/fee/:id/receipt", service.GetPatentFeeReceipt)
+
+	// 获取专利审批时间线
+	group.GET("/:id/timeline", service.GetPatentTimeline)
 
-	// 获取本月专利年费统计信息
-	group.GET("/get_monthly_fee_stats", service.GetMonthlyPatentFeeStatsService)
+	// 专利附件
+	initAttachment(group, models.AssetTypePatent)
 }
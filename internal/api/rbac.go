@@ -0,0 +1,29 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"intellectual_property/internal/service"
+	"intellectual_property/pkg/models"
+)
+
+func initRBAC(r *gin.Engine) {
+	group := r.Group("/rbac", service.RequirePermission(models.PermRoleManage))
+
+	// 查询所有角色
+	group.GET("/roles", service.GetAllRoles)
+
+	// 查询所有权限
+	group.GET("/permissions", service.GetAllPermissions)
+
+	// 查询用户的角色
+	group.GET("/user_roles", service.GetUserRoles)
+
+	// 分配角色
+	group.POST("/assign", service.AssignRole)
+
+	// 撤销角色
+	group.DELETE("/revoke", service.RevokeRole)
+
+	// 强制用户下线
+	group.POST("/revoke_tokens", service.RevokeUserTokens)
+}
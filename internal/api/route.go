@@ -3,10 +3,11 @@ package api
 import (
 	"github.com/gin-gonic/gin"
 	"intellectual_property/internal/service"
+	"intellectual_property/pkg/models"
 )
 
 func initRoute(r *gin.Engine) {
-	group := r.Group("/route")
+	group := r.Group("/route", service.RequirePermission(models.PermRouteManage))
 
 	//接口访问信息
 	group.GET("/interface_info", service.StatsHandler)
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"intellectual_property/internal/service"
+	"intellectual_property/pkg/models"
+)
+
+func initSecurity(r *gin.Engine) {
+	group := r.Group("/security", service.RequirePermission(models.PermUserManage))
+
+	// 解除账号登录锁定
+	group.POST("/unlock_account", service.UnlockAccount)
+
+	// 解除IP登录锁定
+	group.POST("/unlock_ip", service.UnlockIP)
+
+	// 查询登录历史
+	group.GET("/login_history", service.GetLoginHistory)
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"intellectual_property/internal/service"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,7 +12,7 @@ func initTrademark(r *gin.Engine) {
 	group := r.Group("/trademark")
 
 	// 新增商标申请
-	group.POST("/add", service.CreateTrademark)
+	group.POST("/add", service.RequirePermission(models.PermAssetApply), service.LimitUploadBody(models.AssetTypeTrademark, utils.MaxUploadFormFiles), service.CreateTrademark)
 
 	// 获取商标信息并模糊查询
 	group.GET("/get_trademarks", service.GetAllTrademarks)
@@ -21,11 +23,29 @@ func initTrademark(r *gin.Engine) {
 	group.DELETE("/del_trademark", service.DeleteTrademark)
 
 	// 更新审核状态
-	group.PUT("/update_trademark_status", service.UpdateTrademarkStatus)
+	group.PUT("/update_trademark_status", service.RequirePermission(models.PermApprovalReview), service.UpdateTrademarkStatus)
+
+	// 驳回后重新提交
+	group.PUT("/resubmit", service.RequirePermission(models.PermAssetApply), service.LimitUploadBody(models.AssetTypeTrademark, utils.MaxUploadFormFiles), service.ResubmitTrademark)
 
 	// 获取所有商标年费
-	group.GET("/get_fee_all", service.GetAllTrademarkFees)
+	group.GET("/get_fee_all", service.RequirePermission(models.PermFeeView), service.GetAllTrademarkFees)
+
+	// 商标年费支付宝支付
+	group.POST("/fee/pay", service.PayTrademarkFee)
+
+	// 申请商标费用退款
+	group.POST("/fee/refund", service.RefundTrademarkFee)
+
+	// 免除或减免商标费用
+	group.POST("/fee/waive", service.RequirePermission(models.PermFeeManage), service.WaiveTrademarkFee)
+
+	// 下载商标费用缴费收据
+	group.GET("/fee/:id/receipt", service.GetTrademarkFeeReceipt)
+
+	// 获取商标审批时间线
+	group.GET("/:id/timeline", service.GetTrademarkTimeline)
 
-	// 获取本月商标年费统计信息
-	group.GET("/get_monthly_fee_stats", service.GetMonthlyTrademarkFeeStatsService)
+	// 商标附件
+	initAttachment(group, models.AssetTypeTrademark)
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"github.com/gin-gonic/gin"
 	"intellectual_property/internal/service"
+	"intellectual_property/pkg/utils"
 )
 
 func initUser(r *gin.Engine) {
@@ -15,11 +16,20 @@ func initUser(r *gin.Engine) {
 	group.GET("/find", service.FindUsersByID)
 
 	//上传头像
-	group.POST("/upload_avatar", service.UploadAvatar)
+	group.POST("/upload_avatar", service.LimitUploadBody(utils.UploadAvatar, 1), service.UploadAvatar)
 	//增加用户
 	group.POST("/add", service.AddUser)
 
 	//修改用户
 	group.PUT("/edit", service.ModifyTheUser)
 
+	//修改密码
+	group.PUT("/password", service.ChangePassword)
+
+	//忘记密码-发送验证码
+	group.POST("/password/forgot", service.ForgotPassword)
+
+	//通过验证码重置密码
+	group.POST("/password/reset", service.ResetPassword)
+
 }
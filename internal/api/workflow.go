@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"intellectual_property/internal/service"
+	"intellectual_property/pkg/models"
+)
+
+func initWorkflow(r *gin.Engine) {
+	group := r.Group("/workflow", service.RequirePermission(models.PermWorkflowManage))
+
+	// 获取所有审批流程
+	group.GET("/get_all", service.GetAllWorkflows)
+
+	// 新增或替换审批流程
+	group.POST("/save", service.SaveWorkflow)
+}
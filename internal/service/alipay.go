@@ -1,13 +1,26 @@
 package service
 
-import "github.com/gin-gonic/gin"
+import (
+	"intellectual_property/pkg/utils"
+	"net/http"
 
-// AlipayToSuccess 回调函数
-func AlipayToSuccess(c *gin.Context) {
-	c.JSON(200, "支付成功")
-}
+	"github.com/gin-gonic/gin"
+)
 
-// AlipayToPatent 专利年费支付
-func AlipayToPatent(c *gin.Context) {
+// AlipayNotify 支付宝异步通知
+func AlipayNotify(c *gin.Context) {
+	paymentNotify(c, utils.PaymentChannelAlipay)
+}
 
+// AlipayToSuccess 支付完成后的同步跳转
+// 只校验签名并展示结果，费用状态以异步通知为准
+func AlipayToSuccess(c *gin.Context) {
+	if err := utils.VerifyAlipayReturn(c.Request.URL.Query()); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusBadRequest, "支付结果校验失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "支付成功", gin.H{
+		"out_trade_no": c.Query("out_trade_no"),
+	})
 }
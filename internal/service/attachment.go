@@ -0,0 +1,233 @@
+package service
+
+import (
+	"errors"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attachmentErrorCode 附件错误对应的 HTTP 状态码
+func attachmentErrorCode(err error) int {
+	switch {
+	case errors.Is(err, models.ErrAttachmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrAttachmentNotCurrent):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrAttachmentExtension):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrUploadSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrUploadCompleted), errors.Is(err, models.ErrUploadIncomplete):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrUploadEmpty), errors.Is(err, models.ErrUploadChecksumFormat),
+		errors.Is(err, models.ErrUploadChunkIndex), errors.Is(err, models.ErrUploadChunkSize),
+		errors.Is(err, models.ErrUploadChunkChecksum), errors.Is(err, models.ErrUploadChecksum):
+		return http.StatusBadRequest
+	}
+	return uploadErrorCode(err)
+}
+
+// uploadErrorCode 上传校验错误对应的 HTTP 状态码
+func uploadErrorCode(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrUploadName), errors.Is(err, utils.ErrUploadImage):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrUploadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, utils.ErrUploadType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, utils.ErrUploadInfected):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, utils.ErrScanFailed):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// stageUploads 校验表单中的全部文件并写入存储，不通过时写入错误响应
+// 文件在写入数据库之前保存，调用方在数据库操作失败时需调用 models.DiscardStagedAttachments
+// 只有请求不是 multipart 表单时才视为没有文件，表单读取失败(上传中断、超过大小)时不能继续创建记录
+func stageUploads(c *gin.Context, assetType string, applicationNumber string, field string) ([]models.StagedAttachment, bool) {
+	form, err := c.MultipartForm()
+	if errors.Is(err, http.ErrNotMultipart) {
+		return nil, true
+	}
+	if err != nil {
+		respFormFileError(c, err, "读取上传文件失败")
+		return nil, false
+	}
+	staged, err := models.StageAttachments(assetType, applicationNumber, form.File[field])
+	if err != nil {
+		respAttachmentError(c, err, "上传相关文件失败")
+		return nil, false
+	}
+	return staged, true
+}
+
+// LimitUploadBody 按类别的大小限制包装请求体，files 为表单中的文件数上限
+// gin 解析表单时会先把整个请求体读入内存或写入临时文件，文件大小校验在此之后才执行，必须先限制请求体
+func LimitUploadBody(category string, files int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := utils.UploadBodyLimit(category, files)
+		if limit > 0 {
+			if c.Request.ContentLength > limit {
+				Resp(c, false, http.StatusRequestEntityTooLarge, utils.ErrUploadTooLarge.Error(), nil)
+				c.Abort()
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+		c.Next()
+	}
+}
+
+// respFormFileError 读取表单文件失败的响应，请求体超出大小限制时返回 413
+func respFormFileError(c *gin.Context, err error, msg string) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		Resp(c, false, http.StatusRequestEntityTooLarge, utils.ErrUploadTooLarge.Error(), nil)
+		return
+	}
+	logger.Warn(msg + ": " + err.Error())
+	Resp(c, false, http.StatusBadRequest, msg, nil)
+}
+
+// respAttachmentError 附件操作失败的响应，校验错误返回原因，其他错误只记录日志
+func respAttachmentError(c *gin.Context, err error, msg string) {
+	code := attachmentErrorCode(err)
+	if code == http.StatusInternalServerError {
+		logger.Error(err.Error())
+		Resp(c, false, code, msg, nil)
+		return
+	}
+	Resp(c, false, code, err.Error(), nil)
+}
+
+// attachmentParams 解析路径参数中的资产ID和附件ID，附件ID不存在时返回0
+func attachmentParams(c *gin.Context) (int, int, bool) {
+	assetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的ID", nil)
+		return 0, 0, false
+	}
+	if c.Param("attachmentId") == "" {
+		return assetID, 0, true
+	}
+	attachmentID, err := strconv.Atoi(c.Param("attachmentId"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的附件ID", nil)
+		return 0, 0, false
+	}
+	return assetID, attachmentID, true
+}
+
+// ListAttachments 查询资产附件，all=true 时包含历史版本
+func ListAttachments(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assetID, _, ok := attachmentParams(c)
+		if !ok || !allowAssetReader(c, assetType, assetID) {
+			return
+		}
+		attachments, err := models.ListAttachments(assetType, assetID, c.Query("all") == "true")
+		if err != nil {
+			logger.Error(err.Error())
+			Resp(c, false, http.StatusInternalServerError, "查询附件失败", nil)
+			return
+		}
+		Resp(c, true, http.StatusOK, "查询成功", attachments)
+	}
+}
+
+// AddAttachment 上传单个附件，表单字段 file；已有同名附件时保存为新版本
+func AddAttachment(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assetID, _, ok := attachmentParams(c)
+		if !ok || !allowAssetOwner(c, assetType, assetID) {
+			return
+		}
+		file, err := c.FormFile("file")
+		if err != nil {
+			respFormFileError(c, err, "请选择上传的文件")
+			return
+		}
+		attachment, err := models.AddAttachment(assetType, assetID, currentUserID(c), file)
+		if err != nil {
+			respAttachmentError(c, err, "上传附件失败")
+			return
+		}
+		attachment.DownloadURL = models.AttachmentDownloadPath(attachment)
+		Resp(c, true, http.StatusCreated, "上传成功", attachment)
+	}
+}
+
+// ReplaceAttachment 替换附件，表单字段 file；原文件作为历史版本保留
+func ReplaceAttachment(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assetID, attachmentID, ok := attachmentParams(c)
+		if !ok || !allowAssetOwner(c, assetType, assetID) {
+			return
+		}
+		file, err := c.FormFile("file")
+		if err != nil {
+			respFormFileError(c, err, "请选择上传的文件")
+			return
+		}
+		attachment, err := models.ReplaceAttachment(assetType, assetID, attachmentID, currentUserID(c), file)
+		if err != nil {
+			respAttachmentError(c, err, "替换附件失败")
+			return
+		}
+		attachment.DownloadURL = models.AttachmentDownloadPath(attachment)
+		Resp(c, true, http.StatusOK, "替换成功", attachment)
+	}
+}
+
+// DeleteAttachment 删除附件及其全部历史版本
+func DeleteAttachment(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assetID, attachmentID, ok := attachmentParams(c)
+		if !ok || !allowAssetOwner(c, assetType, assetID) {
+			return
+		}
+		if err := models.DeleteAttachment(assetType, assetID, attachmentID); err != nil {
+			respAttachmentError(c, err, "删除附件失败")
+			return
+		}
+		Resp(c, true, http.StatusOK, "删除成功", nil)
+	}
+}
+
+// DownloadAttachment 下载附件，经服务端鉴权后从存储中流式读取，不再暴露存储地址
+func DownloadAttachment(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assetID, attachmentID, ok := attachmentParams(c)
+		if !ok || !allowAssetReader(c, assetType, assetID) {
+			return
+		}
+		attachment, err := models.GetAttachment(assetType, assetID, attachmentID)
+		if err != nil {
+			code := attachmentErrorCode(err)
+			if code == http.StatusInternalServerError {
+				logger.Error(err.Error())
+			}
+			Resp(c, false, code, "附件不存在", nil)
+			return
+		}
+		file, err := models.OpenAttachment(attachment)
+		if err != nil {
+			logger.Error(err.Error())
+			Resp(c, false, http.StatusInternalServerError, "读取附件失败", nil)
+			return
+		}
+		defer file.Close()
+		c.DataFromReader(http.StatusOK, attachment.Size, attachment.MimeType, file, map[string]string{
+			"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}),
+			"X-Checksum-Sha256":   attachment.SHA256,
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"intellectual_property/pkg/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUserID 当前登录用户ID，由 JWT 中间件写入上下文
+func currentUserID(c *gin.Context) int {
+	return c.GetInt("userID")
+}
+
+// RequirePermission Gin中间件，校验当前登录用户是否拥有指定权限
+// 需要放在 JWT 中间件之后，从上下文中读取 userID
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID == 0 {
+			Resp(c, false, http.StatusUnauthorized, "未登录", nil)
+			c.Abort()
+			return
+		}
+		ok, err := models.UserHasPermission(userID, permission)
+		if err != nil {
+			logger.Error(err.Error())
+			Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+			c.Abort()
+			return
+		}
+		if !ok {
+			Resp(c, false, http.StatusForbidden, "没有访问权限", nil)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// allowSelfOr 操作对象是自己，或者拥有指定权限时返回 true
+// 无权限时直接写入 403 响应，调用方只需 return
+func allowSelfOr(c *gin.Context, ownerID int, permission string) bool {
+	if ownerID == currentUserID(c) {
+		return true
+	}
+	ok, err := models.UserHasPermission(currentUserID(c), permission)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return false
+	}
+	if !ok {
+		Resp(c, false, http.StatusForbidden, "没有访问权限", nil)
+		return false
+	}
+	return true
+}
+
+// allowAssetOwner 资产的第一作者，或者拥有管理申请权限时返回 true
+func allowAssetOwner(c *gin.Context, assetType string, assetID int) bool {
+	firstAuthorID, err := models.GetAssetFirstAuthorID(assetType, assetID)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusNotFound, "申请不存在", nil)
+		return false
+	}
+	return allowSelfOr(c, firstAuthorID, models.PermAssetManage)
+}
+
+// allowAssetReader 资产的作者，或者拥有管理申请、审批权限时返回 true
+// 用于附件下载等只读操作
+func allowAssetReader(c *gin.Context, assetType string, assetID int) bool {
+	isAuthor, err := models.IsAssetAuthor(assetType, assetID, currentUserID(c))
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return false
+	}
+	if isAuthor {
+		return true
+	}
+	for _, permission := range []string{models.PermAssetManage, models.PermApprovalReview} {
+		ok, err := models.UserHasPermission(currentUserID(c), permission)
+		if err != nil {
+			logger.Error(err.Error())
+			Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+			return false
+		}
+		if ok {
+			return true
+		}
+	}
+	Resp(c, false, http.StatusForbidden, "没有访问权限", nil)
+	return false
+}
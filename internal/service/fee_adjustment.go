@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RefundPatentFee 申请专利费用退款
+func RefundPatentFee(c *gin.Context) {
+	refundFee(c, models.AssetTypePatent)
+}
+
+// RefundArticleFee 申请著作费用退款
+func RefundArticleFee(c *gin.Context) {
+	refundFee(c, models.AssetTypeArticle)
+}
+
+// RefundTrademarkFee 申请商标费用退款
+func RefundTrademarkFee(c *gin.Context) {
+	refundFee(c, models.AssetTypeTrademark)
+}
+
+// WaivePatentFee 免除或减免专利费用
+func WaivePatentFee(c *gin.Context) {
+	waiveFee(c, models.AssetTypePatent)
+}
+
+// WaiveArticleFee 免除或减免著作费用
+func WaiveArticleFee(c *gin.Context) {
+	waiveFee(c, models.AssetTypeArticle)
+}
+
+// WaiveTrademarkFee 免除或减免商标费用
+func WaiveTrademarkFee(c *gin.Context) {
+	waiveFee(c, models.AssetTypeTrademark)
+}
+
+// feeAdjustErrorCode 费用调整错误对应的HTTP状态码
+func feeAdjustErrorCode(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, models.ErrFeeAdjustNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrFeeAdjustState), errors.Is(err, models.ErrFeeAlreadyPaid),
+		errors.Is(err, models.ErrFeeWaived), errors.Is(err, models.ErrFeeLapsed), errors.Is(err, models.ErrFeeNotPaid),
+		errors.Is(err, models.ErrRefundOrderNotFound):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrFeeAdjustAmount), errors.Is(err, models.ErrRefundExceeds),
+		errors.Is(err, models.ErrFeeAdjustInvalidKind), errors.Is(err, models.ErrFeeAdjustReasonNeeded):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// respFeeAdjustError 按错误类型返回费用调整失败的响应
+func respFeeAdjustError(c *gin.Context, err error, message string) {
+	code := feeAdjustErrorCode(err)
+	if code == http.StatusInternalServerError {
+		logger.Error(err.Error())
+		Resp(c, false, code, message, nil)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		Resp(c, false, code, "费用不存在", nil)
+		return
+	}
+	Resp(c, false, code, err.Error(), nil)
+}
+
+// refundFee 申请退款，资产的第一作者或财务人员可以申请，需财务审批后才退款
+// amount 不填时退还全部可退金额；order_no 不填时退还计入费用的订单，重复支付的订单需指定订单号
+func refundFee(c *gin.Context, assetType string) {
+	feeID, err := strconv.Atoi(c.PostForm("fee_id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的费用ID", nil)
+		return
+	}
+	var amount float64
+	if s := c.PostForm("amount"); s != "" {
+		if amount, err = strconv.ParseFloat(s, 64); err != nil {
+			Resp(c, false, http.StatusBadRequest, "无效的退款金额", nil)
+			return
+		}
+	}
+	fee, err := models.GetFeeInfo(assetType, feeID)
+	if err != nil {
+		respFeeAdjustError(c, err, "系统错误")
+		return
+	}
+	firstAuthorID, err := models.GetAssetFirstAuthorID(assetType, fee.AssetID)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if !allowSelfOr(c, firstAuthorID, models.PermFeeManage) {
+		return
+	}
+
+	adj, err := models.CreateRefundRequest(assetType, feeID, c.PostForm("order_no"), amount, c.PostForm("reason"), currentUserID(c))
+	if err != nil {
+		respFeeAdjustError(c, err, "申请退款失败")
+		return
+	}
+	Resp(c, true, http.StatusOK, "退款申请已提交", adj)
+}
+
+// waiveFee 财务人员免除或减免未支付的费用
+// kind 为 waiver 时免除全部费用，为 reduction 时减免 amount
+func waiveFee(c *gin.Context, assetType string) {
+	feeID, err := strconv.Atoi(c.PostForm("fee_id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的费用ID", nil)
+		return
+	}
+	var amount float64
+	if s := c.PostForm("amount"); s != "" {
+		if amount, err = strconv.ParseFloat(s, 64); err != nil {
+			Resp(c, false, http.StatusBadRequest, "无效的减免金额", nil)
+			return
+		}
+	}
+
+	adj, err := models.WaiveFee(assetType, feeID, c.PostForm("kind"), amount, c.PostForm("reason"), currentUserID(c))
+	if err != nil {
+		respFeeAdjustError(c, err, "费用减免失败")
+		return
+	}
+	Resp(c, true, http.StatusOK, "费用减免成功", adj)
+}
+
+// GetFeeAdjustments 分页查询费用调整记录，可按资产类型、调整类型、状态筛选
+func GetFeeAdjustments(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	adjustments, total, err := models.GetFeeAdjustments(c.Query("asset_type"), c.Query("kind"), c.Query("state"), page, pageSize)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "查询费用调整记录失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "查询费用调整记录成功", gin.H{
+		"adjustments": adjustments,
+		"total":       total,
+	})
+}
+
+// ApproveFeeRefund 审批通过退款申请，通过支付渠道原路退回
+func ApproveFeeRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的调整记录ID", nil)
+		return
+	}
+	adj, err := models.ApproveRefund(context.Background(), id, currentUserID(c), c.PostForm("comment"), utils.GetTradeRefunder)
+	if err != nil {
+		respFeeAdjustError(c, err, "退款失败")
+		return
+	}
+	Resp(c, true, http.StatusOK, "退款成功", adj)
+}
+
+// RejectFeeRefund 驳回退款申请
+func RejectFeeRefund(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的调整记录ID", nil)
+		return
+	}
+	adj, err := models.RejectFeeAdjustment(id, currentUserID(c), c.PostForm("comment"))
+	if err != nil {
+		respFeeAdjustError(c, err, "驳回失败")
+		return
+	}
+	Resp(c, true, http.StatusOK, "已驳回", adj)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"intellectual_property/pkg/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListFees 费用中心：跨专利、著作、商标查询费用，财务人员使用
+// 查询参数：asset_type、status、fee_kind、deadline_from、deadline_to(含当天)、first_author_id、dep_id、keyword、
+// sort(payment_deadline/created_at/payment_date/amount/status，amount 按含滞纳金的总额)、order(asc/desc)、page、page_size
+func ListFees(c *gin.Context) {
+	q, ok := parseFeeQuery(c)
+	if !ok {
+		return
+	}
+	if q.FirstAuthorID, ok = queryInt(c, "first_author_id"); !ok {
+		return
+	}
+	if q.DepID, ok = queryInt(c, "dep_id"); !ok {
+		return
+	}
+	listFees(c, q)
+}
+
+// ListMyFees 我的费用：当前用户作为作者的资产的全部费用，查询参数同 ListFees，不支持按第一作者和单位筛选
+func ListMyFees(c *gin.Context) {
+	q, ok := parseFeeQuery(c)
+	if !ok {
+		return
+	}
+	q.AuthorID = currentUserID(c)
+	listFees(c, q)
+}
+
+// listFees 执行查询并返回分页结果
+func listFees(c *gin.Context, q models.FeeQuery) {
+	fees, total, err := models.ListFees(q)
+	if err != nil {
+		if errors.Is(err, models.ErrFeeQuery) {
+			Resp(c, false, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "查询费用失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "查询费用成功", gin.H{
+		"fees":  fees,
+		"total": total,
+	})
+}
+
+// parseFeeQuery 解析费用中心的通用查询参数，参数无效时直接返回400
+func parseFeeQuery(c *gin.Context) (models.FeeQuery, bool) {
+	q := models.FeeQuery{
+		AssetType: c.Query("asset_type"),
+		FeeKind:   c.Query("fee_kind"),
+		Keyword:   c.Query("keyword"),
+		Sort:      c.Query("sort"),
+		Order:     c.Query("order"),
+	}
+	if s := c.Query("status"); s != "" {
+		status, err := strconv.Atoi(s)
+		if err != nil {
+			Resp(c, false, http.StatusBadRequest, "无效的状态参数", nil)
+			return q, false
+		}
+		q.Status = &status
+	}
+	if s := c.Query("deadline_from"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			Resp(c, false, http.StatusBadRequest, "无效的缴费期限", nil)
+			return q, false
+		}
+		q.DeadlineFrom = &t
+	}
+	if s := c.Query("deadline_to"); s != "" {
+		t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			Resp(c, false, http.StatusBadRequest, "无效的缴费期限", nil)
+			return q, false
+		}
+		t = t.AddDate(0, 0, 1)
+		q.DeadlineTo = &t
+	}
+	var ok bool
+	if q.Page, ok = queryInt(c, "page"); !ok {
+		return q, false
+	}
+	if q.PageSize, ok = queryInt(c, "page_size"); !ok {
+		return q, false
+	}
+	return q, true
+}
+
+// queryInt 解析可选的整数查询参数，为空时返回0
+func queryInt(c *gin.Context, key string) (int, bool) {
+	s := c.Query(key)
+	if s == "" {
+		return 0, true
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的参数: "+key, nil)
+		return 0, false
+	}
+	return v, true
+}
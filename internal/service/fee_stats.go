@@ -0,0 +1,38 @@
+package service
+
+import (
+	"errors"
+	"intellectual_property/pkg/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFeeStats 按区间统计费用，按资产类型和费用状态分组
+// 查询参数：
+//   - period: month、quarter、year、custom，默认 month
+//   - value: 区间，例如 2026-10、2026-Q4、2026，默认当前区间
+//   - from、to: period=custom 时的起止日期，例如 2026-01-01
+//   - granularity: day、month、quarter、year，默认按区间长度选择
+//   - date_field: deadline(缴费期限)、created(生成时间)、paid(支付时间)，默认 deadline
+//   - asset_type: patent、article、trademark，为空时统计全部
+func GetFeeStats(c *gin.Context) {
+	period, err := models.ParseStatsPeriod(c.Query("period"), c.Query("value"), c.Query("from"), c.Query("to"),
+		c.Query("granularity"), time.Now())
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	report, err := models.GetFeeStats(period, c.Query("date_field"), c.Query("asset_type"))
+	if err != nil {
+		if errors.Is(err, models.ErrStatsPeriod) || errors.Is(err, models.ErrStatsAssetType) {
+			Resp(c, false, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "获取费用统计失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "获取费用统计成功", report)
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"intellectual_property/pkg/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// feeTariffErrorCode 费用标准和减缴政策错误对应的HTTP状态码
+func feeTariffErrorCode(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrFeeTariffEffective):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrFeeTariffInvalid), errors.Is(err, models.ErrFeeDiscountInvalid):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// respFeeTariffError 按错误类型返回费用标准操作失败的响应
+func respFeeTariffError(c *gin.Context, err error, message string) {
+	code := feeTariffErrorCode(err)
+	switch code {
+	case http.StatusInternalServerError:
+		logger.Error(err.Error())
+		Resp(c, false, code, message, nil)
+	case http.StatusNotFound:
+		Resp(c, false, code, "记录不存在", nil)
+	default:
+		Resp(c, false, code, err.Error(), nil)
+	}
+}
+
+// GetFeeTariffs 查询费用标准，可按资产类型、费用类型筛选
+func GetFeeTariffs(c *gin.Context) {
+	tariffs, err := models.GetFeeTariffs(c.Query("asset_type"), c.Query("fee_kind"))
+	if err != nil {
+		respFeeTariffError(c, err, "查询费用标准失败")
+		return
+	}
+	Resp(c, true, http.StatusOK, "查询费用标准成功", tariffs)
+}
+
+// CreateFeeTariff 新增费用标准
+// 请求体为JSON：{"asset_type":"patent","sub_type":0,"fee_kind":"annuity","year_from":1,"year_to":3,"amount":900,"effective_from":"2027-01-01T00:00:00+08:00"}
+// 调价时新增一条生效日期在未来的标准，到期后自动使用新金额
+func CreateFeeTariff(c *gin.Context) {
+	var tariff models.FeeTariff
+	if err := c.ShouldBindJSON(&tariff); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusBadRequest, "参数格式错误", nil)
+		return
+	}
+	tariff.CreatedBy = currentUserID(c)
+	if err := models.CreateFeeTariff(&tariff); err != nil {
+		respFeeTariffError(c, err, "新增费用标准失败")
+		return
+	}
+	Resp(c, true, http.StatusOK, "新增费用标准成功", tariff)
+}
+
+// DeleteFeeTariff 删除尚未生效的费用标准
+func DeleteFeeTariff(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的费用标准ID", nil)
+		return
+	}
+	if err := models.DeleteFeeTariff(id); err != nil {
+		respFeeTariffError(c, err, "删除费用标准失败")
+		return
+	}
+	Resp(c, true, http.StatusOK, "删除费用标准成功", nil)
+}
+
+// GetFeeDiscounts 查询费用减缴政策
+func GetFeeDiscounts(c *gin.Context) {
+	discounts, err := models.GetFeeDiscounts()
+	if err != nil {
+		respFeeTariffError(c, err, "查询费用减缴政策失败")
+		return
+	}
+	Resp(c, true, http.StatusOK, "查询费用减缴政策成功", discounts)
+}
+
+// CreateFeeDiscount 新增费用减缴政策
+// 请求体为JSON：{"code":"small_entity","name":"小微主体减缴","pay_ratio":0.3,"fee_kinds":"review,annuity","user_ids":"12,15","dep_ids":"3","enabled":true}
+// user_ids 和 dep_ids 至少填写一项，只有名单中的用户和单位可以在申请时使用
+func CreateFeeDiscount(c *gin.Context) {
+	saveFeeDiscount(c, 0)
+}
+
+// UpdateFeeDiscount 修改费用减缴政策，只影响之后生成的费用
+func UpdateFeeDiscount(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		Resp(c, false, http.StatusBadRequest, "无效的减缴政策ID", nil)
+		return
+	}
+	saveFeeDiscount(c, id)
+}
+
+// saveFeeDiscount 新增或修改费用减缴政策
+func saveFeeDiscount(c *gin.Context, id int) {
+	var discount models.FeeDiscount
+	if err := c.ShouldBindJSON(&discount); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusBadRequest, "参数格式错误", nil)
+		return
+	}
+	discount.ID = id
+	if err := models.SaveFeeDiscount(&discount); err != nil {
+		respFeeTariffError(c, err, "保存费用减缴政策失败")
+		return
+	}
+	Resp(c, true, http.StatusOK, "保存费用减缴政策成功", discount)
+}
+
+// checkFeeDiscount 校验申请人填写的减缴代码，不能使用时返回错误响应
+func checkFeeDiscount(c *gin.Context, code string) bool {
+	err := models.CheckFeeDiscount(code, currentUserID(c))
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, models.ErrFeeDiscountNotFound):
+		Resp(c, false, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, models.ErrFeeDiscountDenied):
+		Resp(c, false, http.StatusForbidden, err.Error(), nil)
+	default:
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "校验费用减缴代码失败", nil)
+	}
+	return false
+}
@@ -1,48 +1,179 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"intellectual_property/pkg/models"
 	"intellectual_property/pkg/utils"
 	"net/http"
+	"sync"
+	"time"
+)
+
+// loginFailedMessage 登录失败统一提示，不区分用户不存在和密码错误
+const loginFailedMessage = "用户名或密码错误"
+
+// dummyHash 用户不存在时也做一次密码校验，使响应时间和密码错误时一致
+var (
+	dummyHash     string
+	dummyHashOnce sync.Once
 )
 
 // Login 登录
+// 同一账号或IP连续失败会被临时锁定，每次尝试都记录到登录历史
 func Login(c *gin.Context) {
 	email := c.PostForm("username")    //这里的username 表示的是有邮件地址
 	password := c.PostForm("password") //密码
+	history := models.LoginHistory{
+		Email:     email,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	//是否处于锁定中
+	remaining, err := utils.LoginLockRemaining(email, history.IP)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, SystemError, "系统错误", "")
+		return
+	}
+	if remaining > 0 {
+		history.Reason = models.LoginReasonLocked
+		recordLoginHistory(&history)
+		respLocked(c, remaining)
+		return
+	}
 
 	//eamil作为查询来查询User信息
 	user, err := models.GetUserByEmail(email)
 	if err != nil {
-		utils.Logger.Error(err.Error())
-		Resp(c, false, UserNoExists, "用户不存在", "")
+		logger.Error(err.Error())
+		Resp(c, false, SystemError, "系统错误", "")
+		return
+	}
+
+	//验证密码是否正确
+	stored := user.Password
+	if user.ID == 0 {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = utils.HashPassword("dummy-password")
+		})
+		stored = dummyHash
+	}
+	ok, needsRehash := utils.CheckPassword(password, stored)
+	if user.ID == 0 || !ok {
+		history.UserID = user.ID
+		history.Reason = models.LoginReasonWrongPassword
+		if user.ID == 0 {
+			history.Reason = models.LoginReasonUserNotFound
+		}
+		recordLoginHistory(&history)
+		lock, err := utils.RecordLoginFailure(email, history.IP)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+		if lock > 0 {
+			respLocked(c, lock)
+			return
+		}
+		Resp(c, false, http.StatusUnauthorized, loginFailedMessage, "")
+		return
+	}
+
+	//密码正确
+	if err := utils.ResetLoginFailures(email); err != nil {
+		logger.Error(err.Error())
+	}
+	//旧格式的密码自动升级
+	if needsRehash {
+		if hashed, err := utils.HashPassword(password); err != nil {
+			logger.Error(err.Error())
+		} else if err := models.UpdatePassword(user.ID, hashed); err != nil {
+			logger.Error(err.Error())
+		}
+	}
+	//生成token
+	tokens, err3 := utils.GenerateTokenPair(user.ID, user.Authority, user.UserName)
+	if err3 != nil {
+		logger.Error(err3.Error())
+		Resp(c, false, SystemError, "系统错误", "")
+		return
+	}
+	history.UserID = user.ID
+	history.Success = true
+	history.Reason = models.LoginReasonSuccess
+	recordLoginHistory(&history)
+
+	roles, err4 := models.GetUserRoles(user.ID)
+	if err4 != nil {
+		logger.Error(err4.Error())
+	}
+	Resp(c, true, http.StatusOK, "登录成功", gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"success":       true,
+		"role":          user.Authority,
+		"roles":         roles,
+	})
+}
+
+// respLocked 返回锁定提示，剩余时间向上取整到分钟
+func respLocked(c *gin.Context, remaining time.Duration) {
+	minutes := int((remaining + time.Minute - 1) / time.Minute)
+	Resp(c, false, http.StatusTooManyRequests, fmt.Sprintf("登录失败次数过多，请%d分钟后再试", minutes), "")
+}
+
+// recordLoginHistory 记录登录历史，失败只写日志不影响登录
+func recordLoginHistory(h *models.LoginHistory) {
+	if err := models.RecordLoginHistory(h); err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+// RefreshToken 用刷新令牌换取新的访问令牌
+// 刷新令牌只能使用一次，前端需要保存返回的新刷新令牌
+func RefreshToken(c *gin.Context) {
+	tokens, err := utils.RefreshTokenPair(c.PostForm("refresh_token"))
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidRefreshToken) || errors.Is(err, utils.ErrTokenRevoked) {
+			Resp(c, false, http.StatusUnauthorized, "登录已失效，请重新登录", nil)
+			return
+		}
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
 		return
 	}
-	//表示存在
-	if user.ID != 0 {
-		//验证密码是否正确
-		//拿到研盐值
-		slat := utils.GetSlat(user.Password)
-		if utils.VerifyPassword(password, slat, user.Password) {
-			//密码正确
-			//生成token
-			token, err3 := utils.GenerateToken(user.ID, user.Authority, user.UserName)
-			if err3 != nil {
-				logger.Error(err3.Error())
-				return
-			}
-			Resp(c, true, http.StatusOK, "登录成功", gin.H{
-				"token":   token,
-				"success": true,
-				"role":    user.Authority,
-			})
+	Resp(c, true, http.StatusOK, "刷新成功", tokens)
+}
+
+// Logout 退出登录
+// 注销当前访问令牌和刷新令牌；all=true 时使该用户在所有设备上的令牌失效
+func Logout(c *gin.Context) {
+	// 只能注销自己的刷新令牌
+	if err := utils.RevokeRefreshToken(c.PostForm("refresh_token"), currentUserID(c)); err != nil {
+		if errors.Is(err, utils.ErrInvalidRefreshToken) {
+			Resp(c, false, http.StatusForbidden, "刷新令牌不属于当前用户", nil)
 			return
-		} else {
-			//密码错误
-			Resp(c, false, http.StatusOK, "密码错误", "")
+		}
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if claims, ok := c.Get("claims"); ok {
+		if err := utils.RevokeAccessToken(claims.(*utils.CustomClaims)); err != nil {
+			logger.Error(err.Error())
+			Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+			return
+		}
+	}
+	if c.PostForm("all") == "true" {
+		if err := utils.RevokeUserTokens(currentUserID(c)); err != nil {
+			logger.Error(err.Error())
+			Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
 			return
 		}
 	}
-	Resp(c, false, http.StatusOK, "用户不存在", "")
+	Resp(c, true, http.StatusOK, "已退出登录", nil)
 }
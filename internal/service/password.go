@@ -0,0 +1,128 @@
+package service
+
+import (
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ForgotPassword 找回密码，发送验证码到注册邮箱
+// 无论邮箱是否注册都返回相同的结果，避免被用来探测账号
+func ForgotPassword(c *gin.Context) {
+	email := c.PostForm("email")
+	user, err := models.GetUserByEmail(email)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if user.ID != 0 {
+		// 异步发送，响应时间不随邮箱是否存在而变化
+		go func() {
+			if err := utils.SendPasswordResetCode(email); err != nil {
+				logger.Error(err.Error())
+			}
+		}()
+	}
+	Resp(c, true, http.StatusOK, "如果该邮箱已注册，验证码已发送", nil)
+}
+
+// ResetPassword 通过邮箱验证码重置密码
+// 重置后该用户之前签发的令牌全部失效
+func ResetPassword(c *gin.Context) {
+	email := c.PostForm("email")
+	code := c.PostForm("verificationCode")
+	password := c.PostForm("password")
+	if !checkPasswordFormat(c, password) {
+		return
+	}
+
+	ok, err := utils.VerifyEmailCode(utils.PasswordResetKey(email), code)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if !ok {
+		Resp(c, false, http.StatusBadRequest, "验证码错误或已过期", nil)
+		return
+	}
+
+	user, err := models.GetUserByEmail(email)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if user.ID == 0 {
+		Resp(c, false, http.StatusBadRequest, "验证码错误或已过期", nil)
+		return
+	}
+	if err := setPassword(user.ID, password); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "重置密码失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "密码已重置，请重新登录", nil)
+}
+
+// ChangePassword 修改密码，需要验证原密码
+// 修改后其他设备上的登录全部失效，当前设备返回新的令牌
+func ChangePassword(c *gin.Context) {
+	oldPassword := c.PostForm("old_password")
+	newPassword := c.PostForm("new_password")
+	if !checkPasswordFormat(c, newPassword) {
+		return
+	}
+
+	user, err := models.GetUserByID(currentUserID(c))
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if user.ID == 0 {
+		Resp(c, false, http.StatusUnauthorized, "用户不存在", nil)
+		return
+	}
+	if ok, _ := utils.CheckPassword(oldPassword, user.Password); !ok {
+		Resp(c, false, http.StatusBadRequest, "原密码错误", nil)
+		return
+	}
+	if err := setPassword(user.ID, newPassword); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "修改密码失败", nil)
+		return
+	}
+
+	tokens, err := utils.GenerateTokenPair(user.ID, user.Authority, user.UserName)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, true, http.StatusOK, "密码已修改，请重新登录", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "密码已修改", tokens)
+}
+
+// checkPasswordFormat 按密码策略校验新密码，不符合时直接写入 400 响应
+func checkPasswordFormat(c *gin.Context, password string) bool {
+	if err := utils.ValidatePassword(password); err != nil {
+		Resp(c, false, http.StatusBadRequest, err.Error(), nil)
+		return false
+	}
+	return true
+}
+
+// setPassword 加密保存新密码，并使该用户之前签发的令牌失效
+func setPassword(userID int, password string) error {
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := models.UpdatePassword(userID, hashed); err != nil {
+		return err
+	}
+	return utils.RevokeUserTokens(userID)
+}
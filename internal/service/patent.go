@@ -1,11 +1,11 @@
 package service
 
 import (
+	"errors"
 	"intellectual_property/pkg/models"
 	"intellectual_property/pkg/utils"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,16 +13,6 @@ import (
 
 // CreatePatent 创建专利
 func CreatePatent(c *gin.Context) {
-	// 1. 身份验证
-	authHeader := c.GetHeader("Authorization")
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	_, err := utils.ParseToken(tokenString)
-	if err != nil {
-		utils.Logger.Error("Token解析失败: " + err.Error())
-		Resp(c, false, http.StatusUnauthorized, "无效的访问令牌", nil)
-		return
-	}
-
 	// 2. 解析表单数据
 	patentTypeStr := c.PostForm("patentType")
 	patentType, err := strconv.Atoi(patentTypeStr)
@@ -34,6 +24,13 @@ func CreatePatent(c *gin.Context) {
 	title := c.PostForm("title")
 	abstract := c.PostForm("abstract")
 	firstAuthorID, _ := strconv.Atoi(c.PostForm("firstAuthorId"))
+	if firstAuthorID == 0 {
+		firstAuthorID = currentUserID(c)
+	}
+	//只能以自己为第一作者提交申请，管理申请的人员可以代为提交
+	if !allowSelfOr(c, firstAuthorID, models.PermAssetManage) {
+		return
+	}
 	authorIDs := utils.ConvertStringSliceToInt(c.PostFormArray("authors"))
 
 	// 3. 参数验证
@@ -41,6 +38,11 @@ func CreatePatent(c *gin.Context) {
 		Resp(c, false, http.StatusBadRequest, "必要参数不能为空", nil)
 		return
 	}
+	// 费用减缴代码，可选
+	discountCode := c.PostForm("discountCode")
+	if !checkFeeDiscount(c, discountCode) {
+		return
+	}
 	//生成申请号
 	// 将 time.Now().Year() 的 int 类型转换为 string 类型
 	number, err0 := utils.GenerateApplicationNumber("CN", strconv.Itoa(time.Now().Year()), patentType)
@@ -66,36 +68,21 @@ func CreatePatent(c *gin.Context) {
 		return
 	}
 
-	if err := patent.CreatePatentService(patentFee); err != nil {
-		utils.Logger.Error("数据库操作失败: " + err.Error())
-		Resp(c, false, http.StatusInternalServerError, "创建专利失败", nil)
-		return
-	}
-	//4，文件上传
-	form, err2 := c.MultipartForm()
-	if err2 != nil {
-		utils.Logger.Error(err2.Error())
-		Resp(c, false, http.StatusInternalServerError, "上传相关文件失败", nil)
+	patent.DiscountCode = discountCode
+	//4，文件上传：先校验并写入存储，再和申请记录在同一事务中登记 地址---资产类型/申请号/附件
+	staged, ok := stageUploads(c, models.AssetTypePatent, patent.ApplicationNumber, "files")
+	if !ok {
 		return
 	}
-	Files := form.File["files"] //拿到文件
-	url := utils.NgX.LocationPatent + "/" + patent.ApplicationNumber
-	for _, f := range Files {
-		//保存文件 地址---原始地址/申请号文件/文件
-
-		err = c.SaveUploadedFile(f, url+"/"+f.Filename)
-		if err != nil {
-			utils.Logger.Error(err.Error())
-			Resp(c, false, http.StatusInternalServerError, "上传相关文件失败", nil)
+	patent.AttachmentUrl = models.AssetFilePrefix(models.AssetTypePatent, patent.ApplicationNumber)
+	if err := patent.CreatePatentService(patentFee, currentUserID(c), staged); err != nil {
+		models.DiscardStagedAttachments(staged)
+		if errors.Is(err, models.ErrFeeTariffNotFound) {
+			Resp(c, false, http.StatusConflict, err.Error(), nil)
 			return
 		}
-	}
-	//在更新一下数据库--只跟新文件位置
-	patent.AttachmentUrl = url
-	err3 := patent.UpdatePatentUrl()
-	if err3 != nil {
-		utils.Logger.Error(err3.Error())
-		Resp(c, false, http.StatusInternalServerError, "上传相关文件失败", nil)
+		utils.Logger.Error("数据库操作失败: " + err.Error())
+		Resp(c, false, http.StatusInternalServerError, "创建专利失败", nil)
 		return
 	}
 	// 5. 返回成功响应
@@ -103,7 +90,7 @@ func CreatePatent(c *gin.Context) {
 		"patentId":    patent.ID,
 		"title":       patent.Title,
 		"firstAuthor": patent.FirstAuthorID,
-		"fileCount":   len(Files),
+		"fileCount":   len(staged),
 	})
 }
 
@@ -148,8 +135,15 @@ func GetAllPatents(c *gin.Context) {
 
 // GetPatentFile 获取文件地址
 func GetPatentFile(c *gin.Context) {
-	value := c.Query("id")
-	atoi, _ := strconv.Atoi(value)
+	atoi, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的ID", nil)
+		return
+	}
+	// 附件列表与 /:id/attachments 相同，只有作者和管理、审批人员可以查看
+	if !allowAssetReader(c, models.AssetTypePatent, atoi) {
+		return
+	}
 	file, err := models.GetPatentFile(atoi)
 	if err != nil {
 		utils.Logger.Error(err.Error())
@@ -164,6 +158,10 @@ func DeletePatent(c *gin.Context) {
 	id := c.Query("id")
 
 	atoi, _ := strconv.Atoi(id)
+	// 只有第一作者或管理员可以删除
+	if !allowAssetOwner(c, models.AssetTypePatent, atoi) {
+		return
+	}
 	if err := models.DeletePatent(atoi); err != nil {
 		utils.Logger.Error(err.Error())
 		Resp(c, false, http.StatusInternalServerError, "删除失败", nil)
@@ -173,18 +171,15 @@ func DeletePatent(c *gin.Context) {
 }
 
 // UpdatePatentStatus 更新审核状态
+// 审批人取自访问令牌，不再信任表单中的 reviewer_id
 func UpdatePatentStatus(c *gin.Context) {
 	value := c.PostForm("patent_id")
-	form := c.PostForm("reviewer_id")
 	comment := c.PostForm("comment")
 	v := c.PostForm("status")
 	patent_id, _ := strconv.Atoi(value)
-	reviewer_id, _ := strconv.Atoi(form)
 	status, _ := strconv.Atoi(v)
-	//审核没通过
-	if err := models.UpdatePatentStatus(patent_id, reviewer_id, comment, status); err != nil {
-		utils.Logger.Error(err.Error())
-		Resp(c, false, http.StatusInternalServerError, "更新失败", nil)
+	if err := models.UpdatePatentStatus(patent_id, currentUserID(c), comment, status); err != nil {
+		respondReviewError(c, err)
 		return
 	}
 	Resp(c, true, http.StatusOK, "更新成功", nil)
@@ -238,20 +233,3 @@ func GetAllPatentFees(c *gin.Context) {
 		"total": total,
 	})
 }
-
-// GetMonthlyPatentFeeStatsService 获取本月专利年费统计信息的服务方法
-func GetMonthlyPatentFeeStatsService(c *gin.Context) {
-	pendingCount, paidAmount, overdueCount, totalAmount, err := models.GetMonthlyPatentFeeStats()
-	if err != nil {
-		utils.Logger.Error(err.Error())
-		Resp(c, false, http.StatusInternalServerError, "获取本月专利年费统计信息失败", nil)
-		return
-	}
-
-	Resp(c, true, http.StatusOK, "获取本月专利年费统计信息成功", gin.H{
-		"pending_count": pendingCount,
-		"paid_amount":   paidAmount,
-		"overdue_count": overdueCount,
-		"total_amount":  totalAmount,
-	})
-}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PayPatentFee 专利年费支付
+func PayPatentFee(c *gin.Context) {
+	payFee(c, models.AssetTypePatent)
+}
+
+// PayArticleFee 著作年费支付
+func PayArticleFee(c *gin.Context) {
+	payFee(c, models.AssetTypeArticle)
+}
+
+// PayTrademarkFee 商标年费支付
+func PayTrademarkFee(c *gin.Context) {
+	payFee(c, models.AssetTypeTrademark)
+}
+
+// payFee 按选择的支付渠道创建支付订单，channel 不填时使用支付宝
+// 只有资产的第一作者或财务人员可以发起支付
+func payFee(c *gin.Context, assetType string) {
+	feeID, err := strconv.Atoi(c.PostForm("fee_id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的费用ID", nil)
+		return
+	}
+	provider, err := utils.GetPaymentProvider(c.DefaultPostForm("channel", utils.PaymentChannelAlipay))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	fee, err := models.GetFeeInfo(assetType, feeID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Resp(c, false, http.StatusNotFound, "费用不存在", nil)
+			return
+		}
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if fee.IsPaid {
+		Resp(c, false, http.StatusConflict, models.ErrFeeAlreadyPaid.Error(), nil)
+		return
+	}
+	if fee.Status == models.FeeStatusWaived || fee.Status == models.FeeStatusRefunded {
+		Resp(c, false, http.StatusConflict, "费用已免除或已退款，无需支付", nil)
+		return
+	}
+	if fee.Status == models.FeeStatusLapsed {
+		Resp(c, false, http.StatusConflict, models.ErrFeeLapsed.Error(), nil)
+		return
+	}
+	firstAuthorID, err := models.GetAssetFirstAuthorID(assetType, fee.AssetID)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if !allowSelfOr(c, firstAuthorID, models.PermFeeManage) {
+		return
+	}
+
+	order, err := models.CreatePaymentOrder(fee, provider.Channel(), currentUserID(c))
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "创建支付订单失败", nil)
+		return
+	}
+	prepay, err := provider.CreateOrder(context.Background(), utils.PaymentOrderRequest{
+		OrderNo:  order.OrderNo,
+		Subject:  fee.Subject,
+		Amount:   order.Amount,
+		ExpireAt: order.ExpireAt(),
+	})
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "创建支付订单失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "创建支付订单成功", gin.H{
+		"channel":      order.Channel,
+		"out_trade_no": order.OrderNo,
+		"pay_url":      prepay.PayURL,
+		"instructions": prepay.Instructions,
+	})
+}
+
+// WechatPayNotify 微信支付异步通知
+func WechatPayNotify(c *gin.Context) {
+	paymentNotify(c, utils.PaymentChannelWechat)
+}
+
+// paymentNotify 处理支付渠道的异步通知
+// 校验签名后按订单号更新支付订单和费用，通知原文保存在订单中；重复通知直接应答成功
+func paymentNotify(c *gin.Context, channel string) {
+	provider, err := utils.GetPaymentProvider(channel)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	notify, err := provider.VerifyNotify(c.Request)
+	if err != nil {
+		logger.Error(channel + " 通知校验失败: " + err.Error())
+		provider.AckNotify(c.Writer, false)
+		return
+	}
+
+	switch notify.Status {
+	case utils.TradeStatusPaid:
+	case utils.TradeStatusClosed:
+		// 交易关闭时同步关闭本地订单
+		if err := models.ClosePaymentOrder(notify.OrderNo, notify.Payload); err != nil {
+			logger.Error(channel + " 关闭通知处理失败: " + notify.OrderNo + " " + err.Error())
+		}
+		provider.AckNotify(c.Writer, true)
+		return
+	default:
+		// 其他未支付状态确认收到即可
+		provider.AckNotify(c.Writer, true)
+		return
+	}
+
+	alreadyPaid, err := models.CompletePaymentOrder(notify.OrderNo, notify.Amount, notify.TradeNo, notify.PaidAt, notify.Payload)
+	switch {
+	case errors.Is(err, models.ErrPaymentOrderNotFound):
+		logger.Error(channel + " 通知订单不存在: " + notify.OrderNo)
+		provider.AckNotify(c.Writer, false)
+		return
+	case errors.Is(err, models.ErrFeeAlreadyPaid):
+		// 钱已经收到，订单已记录，不再让支付渠道重试
+		logger.Warn("费用重复支付，需要退款: " + notify.OrderNo)
+	case errors.Is(err, models.ErrPaymentPaidAfterClose):
+		logger.Warn("订单关闭后才支付，需要退款: " + notify.OrderNo)
+	case err != nil:
+		logger.Error(channel + " 通知处理失败: " + notify.OrderNo + " " + err.Error())
+		provider.AckNotify(c.Writer, false)
+		return
+	case alreadyPaid:
+		logger.Info(channel + " 重复通知: " + notify.OrderNo)
+	}
+	provider.AckNotify(c.Writer, true)
+}
+
+// paymentOrderErrorCode 支付订单错误对应的HTTP状态码
+func paymentOrderErrorCode(err error) int {
+	switch {
+	case errors.Is(err, models.ErrPaymentOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrPaymentOrderState), errors.Is(err, models.ErrPaymentNotOffline),
+		errors.Is(err, models.ErrPaymentNoVoucher), errors.Is(err, models.ErrFeeAlreadyPaid),
+		errors.Is(err, models.ErrFeeWaived), errors.Is(err, models.ErrFeeLapsed),
+		errors.Is(err, models.ErrFeeAmountMismatch):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// UploadPaymentVoucher 上传线下转账凭证，发起支付的用户或财务人员可以上传
+func UploadPaymentVoucher(c *gin.Context) {
+	orderNo := c.PostForm("out_trade_no")
+	file, err := c.FormFile("voucher")
+	if err != nil {
+		respFormFileError(c, err, "请上传转账凭证")
+		return
+	}
+	order, err := models.GetPaymentOrder(orderNo)
+	if err != nil {
+		if errors.Is(err, models.ErrPaymentOrderNotFound) {
+			Resp(c, false, http.StatusNotFound, err.Error(), nil)
+			return
+		}
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if !allowSelfOr(c, order.PayerID, models.PermFeeManage) {
+		return
+	}
+
+	checked, err := utils.ValidateUpload(context.Background(), utils.UploadVoucher, file)
+	if err != nil {
+		respAttachmentError(c, err, "上传凭证失败")
+		return
+	}
+	path := "voucher/" + order.OrderNo + "/" + checked.Name
+	if err := utils.PutUploadedFile(context.Background(), utils.Store, path, file); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "上传凭证失败", nil)
+		return
+	}
+	if err := models.AttachPaymentVoucher(order.OrderNo, path); err != nil {
+		code := paymentOrderErrorCode(err)
+		if code == http.StatusInternalServerError {
+			logger.Error(err.Error())
+		}
+		Resp(c, false, code, err.Error(), nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "上传凭证成功，等待财务确认", nil)
+}
+
+// ConfirmOfflinePayment 财务核对到账后确认线下转账，trade_no 填写银行流水号
+func ConfirmOfflinePayment(c *gin.Context) {
+	tradeNo := c.PostForm("trade_no")
+	if tradeNo == "" {
+		Resp(c, false, http.StatusBadRequest, "银行流水号不能为空", nil)
+		return
+	}
+	if err := models.ConfirmOfflinePayment(c.PostForm("out_trade_no"), tradeNo, currentUserID(c)); err != nil {
+		code := paymentOrderErrorCode(err)
+		if code == http.StatusInternalServerError {
+			logger.Error(err.Error())
+			Resp(c, false, code, "确认失败", nil)
+			return
+		}
+		Resp(c, false, code, err.Error(), nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "确认到账成功", nil)
+}
+
+// RejectOfflinePayment 凭证核对不通过，关闭线下转账订单
+func RejectOfflinePayment(c *gin.Context) {
+	if err := models.RejectOfflinePayment(c.PostForm("out_trade_no"), c.PostForm("reason"), currentUserID(c)); err != nil {
+		code := paymentOrderErrorCode(err)
+		if code == http.StatusInternalServerError {
+			logger.Error(err.Error())
+			Resp(c, false, code, "驳回失败", nil)
+			return
+		}
+		Resp(c, false, code, err.Error(), nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "已驳回", nil)
+}
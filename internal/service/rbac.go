@@ -0,0 +1,96 @@
+package service
+
+import (
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAllRoles 获取所有角色及其权限
+func GetAllRoles(c *gin.Context) {
+	roles, err := models.GetAllRoles()
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "获取角色失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "获取角色成功", roles)
+}
+
+// GetAllPermissions 获取所有权限
+func GetAllPermissions(c *gin.Context) {
+	perms, err := models.GetAllPermissions()
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "获取权限失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "获取权限成功", perms)
+}
+
+// GetUserRoles 获取用户的角色
+func GetUserRoles(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Query("user_id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的用户ID", nil)
+		return
+	}
+	roles, err := models.GetUserRoles(userID)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "获取用户角色失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "获取用户角色成功", roles)
+}
+
+// AssignRole 给用户分配角色
+func AssignRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.PostForm("user_id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的用户ID", nil)
+		return
+	}
+	role := c.PostForm("role")
+	if err := models.AssignRole(userID, role); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusBadRequest, "分配角色失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "分配角色成功", nil)
+}
+
+// RevokeRole 撤销用户的角色
+func RevokeRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Query("user_id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的用户ID", nil)
+		return
+	}
+	role := c.Query("role")
+	if err := models.RevokeRole(userID, role); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "撤销角色失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "撤销角色成功", nil)
+}
+
+// RevokeUserTokens 强制用户下线，使其所有访问令牌和刷新令牌失效
+// 用于人员离职、账号被盗等情况
+func RevokeUserTokens(c *gin.Context) {
+	userID, err := strconv.Atoi(c.PostForm("user_id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的用户ID", nil)
+		return
+	}
+	if err := utils.RevokeUserTokens(userID); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "强制下线失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "强制下线成功", nil)
+}
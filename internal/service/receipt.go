@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"intellectual_property/pkg/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetPatentFeeReceipt 下载专利费用的缴费收据
+func GetPatentFeeReceipt(c *gin.Context) {
+	feeReceipt(c, models.AssetTypePatent)
+}
+
+// GetArticleFeeReceipt 下载著作费用的缴费收据
+func GetArticleFeeReceipt(c *gin.Context) {
+	feeReceipt(c, models.AssetTypeArticle)
+}
+
+// GetTrademarkFeeReceipt 下载商标费用的缴费收据
+func GetTrademarkFeeReceipt(c *gin.Context) {
+	feeReceipt(c, models.AssetTypeTrademark)
+}
+
+// feeReceipt 下载缴费收据，首次下载时开具，之后重复下载同一张收据
+// 资产的第一作者或有费用查看权限的人员可以下载，路径参数 id 为费用ID
+func feeReceipt(c *gin.Context, assetType string) {
+	feeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的费用ID", nil)
+		return
+	}
+	fee, err := models.GetFeeInfo(assetType, feeID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Resp(c, false, http.StatusNotFound, "费用不存在", nil)
+			return
+		}
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	firstAuthorID, err := models.GetAssetFirstAuthorID(assetType, fee.AssetID)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+		return
+	}
+	if !allowSelfOr(c, firstAuthorID, models.PermFeeView) {
+		return
+	}
+
+	receipt, err := models.IssueReceipt(assetType, feeID)
+	if err != nil {
+		if errors.Is(err, models.ErrReceiptFeeNotPaid) || errors.Is(err, models.ErrReceiptFeeRefunded) {
+			Resp(c, false, http.StatusConflict, err.Error(), nil)
+			return
+		}
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "开具收据失败", nil)
+		return
+	}
+	file, err := models.OpenReceipt(receipt)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "读取收据失败", nil)
+		return
+	}
+	defer file.Close()
+	c.DataFromReader(http.StatusOK, -1, "application/pdf", file, map[string]string{
+		"Content-Disposition": `attachment; filename="` + receipt.ReceiptNo + `.pdf"`,
+	})
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ResubmitPatent 重新提交被驳回的专利
+func ResubmitPatent(c *gin.Context) {
+	resubmitAsset(c, models.AssetTypePatent, "patent_id")
+}
+
+// ResubmitArticle 重新提交被驳回的著作
+func ResubmitArticle(c *gin.Context) {
+	resubmitAsset(c, models.AssetTypeArticle, "article_id")
+}
+
+// ResubmitTrademark 重新提交被驳回的商标
+func ResubmitTrademark(c *gin.Context) {
+	resubmitAsset(c, models.AssetTypeTrademark, "trademark_id")
+}
+
+// resubmitAsset 重新提交的通用处理
+// 表单字段与新建申请一致：title、abstract、authors、firstAuthorId、files
+func resubmitAsset(c *gin.Context, assetType string, idField string) {
+	// 1. 解析表单数据
+	assetID, err := strconv.Atoi(c.PostForm(idField))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的ID", nil)
+		return
+	}
+	title := c.PostForm("title")
+	abstract := c.PostForm("abstract")
+	firstAuthorID, _ := strconv.Atoi(c.PostForm("firstAuthorId"))
+	authorIDs := utils.ConvertStringSliceToInt(c.PostFormArray("authors"))
+	if title == "" || abstract == "" || len(authorIDs) == 0 {
+		Resp(c, false, http.StatusBadRequest, "必要参数不能为空", nil)
+		return
+	}
+
+	// 2. 新附件先校验并写入原申请号目录，再和记录更新在同一事务中登记
+	applicationNumber, err := models.AssetApplicationNumber(assetType, assetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Resp(c, false, http.StatusNotFound, "申请不存在", nil)
+			return
+		}
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "重新提交失败", nil)
+		return
+	}
+	staged, ok := stageUploads(c, assetType, applicationNumber, "files")
+	if !ok {
+		return
+	}
+
+	// 3. 更新记录并重置审批流程
+	result, err := models.ResubmitAsset(assetType, assetID, currentUserID(c), models.ResubmitInput{
+		Title:         title,
+		Abstract:      abstract,
+		AuthorIDs:     authorIDs,
+		FirstAuthorID: firstAuthorID,
+		Attachments:   staged,
+	})
+	if err != nil {
+		models.DiscardStagedAttachments(staged)
+		switch {
+		case errors.Is(err, models.ErrNotFirstAuthor):
+			Resp(c, false, http.StatusForbidden, err.Error(), nil)
+		case errors.Is(err, models.ErrNotRejected):
+			Resp(c, false, http.StatusConflict, err.Error(), nil)
+		default:
+			logger.Error(err.Error())
+			Resp(c, false, http.StatusInternalServerError, "重新提交失败", nil)
+		}
+		return
+	}
+
+	Resp(c, true, http.StatusOK, "重新提交成功", gin.H{
+		"id":                 assetID,
+		"revision":           result.Revision,
+		"application_number": result.ApplicationNumber,
+		"fileCount":          len(staged),
+	})
+}
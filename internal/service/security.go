@@ -0,0 +1,67 @@
+package service
+
+import (
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockAccount 解除账号的登录锁定
+func UnlockAccount(c *gin.Context) {
+	email := c.PostForm("email")
+	if email == "" {
+		Resp(c, false, http.StatusBadRequest, "邮箱不能为空", nil)
+		return
+	}
+	if err := utils.ResetLoginFailures(email); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "解锁失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "解锁成功", nil)
+}
+
+// UnlockIP 解除IP的登录锁定
+func UnlockIP(c *gin.Context) {
+	ip := c.PostForm("ip")
+	if ip == "" {
+		Resp(c, false, http.StatusBadRequest, "IP不能为空", nil)
+		return
+	}
+	if err := utils.UnlockLoginIP(ip); err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "解锁失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "解锁成功", nil)
+}
+
+// GetLoginHistory 分页查询登录历史，可按用户、邮箱、IP筛选
+func GetLoginHistory(c *gin.Context) {
+	userID, _ := strconv.Atoi(c.Query("user_id"))
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	histories, total, err := models.GetLoginHistory(userID, c.Query("email"), c.Query("ip"), page, pageSize)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "查询登录历史失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "查询登录历史成功", gin.H{
+		"histories": histories,
+		"total":     total,
+	})
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"intellectual_property/pkg/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPatentTimeline 获取专利的审批时间线
+func GetPatentTimeline(c *gin.Context) {
+	assetTimeline(c, models.AssetTypePatent)
+}
+
+// GetArticleTimeline 获取著作的审批时间线
+func GetArticleTimeline(c *gin.Context) {
+	assetTimeline(c, models.AssetTypeArticle)
+}
+
+// GetTrademarkTimeline 获取商标的审批时间线
+func GetTrademarkTimeline(c *gin.Context) {
+	assetTimeline(c, models.AssetTypeTrademark)
+}
+
+// assetTimeline 按资产类型查询时间线，路径参数 id 为资产ID
+// 时间线包含审核意见和重新提交的修改内容，只有作者、资产管理员和审核人可以查看
+func assetTimeline(c *gin.Context, assetType string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的ID", nil)
+		return
+	}
+	if !allowAssetReader(c, assetType, id) {
+		return
+	}
+	events, err := models.GetAssetTimeline(assetType, id)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "获取时间线失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "获取时间线成功", events)
+}
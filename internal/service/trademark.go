@@ -1,11 +1,11 @@
 package service
 
 import (
+	"errors"
 	"intellectual_property/pkg/models"
 	"intellectual_property/pkg/utils"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,21 +14,18 @@ import (
 // CreateTrademark 创建商标
 
 func CreateTrademark(c *gin.Context) {
-	// 1. 身份验证
-	authHeader := c.GetHeader("Authorization")
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	_, err := utils.ParseToken(tokenString)
-	if err != nil {
-		logger.Error("Token解析失败: " + err.Error())
-		Resp(c, false, http.StatusUnauthorized, "无效的访问令牌", nil)
-		return
-	}
-
 	// 2. 解析表单数据
 	trademarkTypeStr := c.PostForm("trademarkType")
 	title := c.PostForm("title")
 	abstract := c.PostForm("abstract")
 	firstAuthorID, _ := strconv.Atoi(c.PostForm("firstAuthorId"))
+	if firstAuthorID == 0 {
+		firstAuthorID = currentUserID(c)
+	}
+	//只能以自己为第一作者提交申请，管理申请的人员可以代为提交
+	if !allowSelfOr(c, firstAuthorID, models.PermAssetManage) {
+		return
+	}
 	authorIDs := utils.ConvertStringSliceToInt(c.PostFormArray("authors"))
 
 	// 3. 参数验证
@@ -36,6 +33,11 @@ func CreateTrademark(c *gin.Context) {
 		Resp(c, false, http.StatusBadRequest, "必要参数不能为空", nil)
 		return
 	}
+	// 费用减缴代码，可选
+	discountCode := c.PostForm("discountCode")
+	if !checkFeeDiscount(c, discountCode) {
+		return
+	}
 
 	// 3. 创建商标记录
 	trademarkType, err := models.ParseTrademarkType(trademarkTypeStr)
@@ -65,36 +67,21 @@ func CreateTrademark(c *gin.Context) {
 		return
 	}
 
-	if err := trademark.CreateTrademarkService(trademarkfee); err != nil {
-		logger.Error("数据库操作失败: " + err.Error())
-		Resp(c, false, http.StatusInternalServerError, "创建商标失败", nil)
-		return
-	}
-	//4，文件上传
-	form, err2 := c.MultipartForm()
-	if err2 != nil {
-		logger.Error(err2.Error())
-		Resp(c, false, http.StatusInternalServerError, "上传相关文件失败", nil)
+	trademark.DiscountCode = discountCode
+	//4，文件上传：先校验并写入存储，再和申请记录在同一事务中登记 地址---资产类型/申请号/附件
+	staged, ok := stageUploads(c, models.AssetTypeTrademark, trademark.ApplicationNumber, "files")
+	if !ok {
 		return
 	}
-	Files := form.File["files"] //拿到文件
-	url := utils.NgX.LocationTrademark + "/" + trademark.ApplicationNumber
-	for _, f := range Files {
-		//保存文件 地址---原始地址/申请号文件/文件
-
-		err = c.SaveUploadedFile(f, url+"/"+f.Filename)
-		if err != nil {
-			logger.Error(err.Error())
-			Resp(c, false, http.StatusInternalServerError, "上传相关文件失败", nil)
+	trademark.AttachmentUrl = models.AssetFilePrefix(models.AssetTypeTrademark, trademark.ApplicationNumber)
+	if err := trademark.CreateTrademarkService(trademarkfee, currentUserID(c), staged); err != nil {
+		models.DiscardStagedAttachments(staged)
+		if errors.Is(err, models.ErrFeeTariffNotFound) {
+			Resp(c, false, http.StatusConflict, err.Error(), nil)
 			return
 		}
-	}
-	//在更新一下数据库--只跟新文件位置
-	trademark.AttachmentUrl = url
-	err3 := trademark.UpdateTrademarkUrl()
-	if err3 != nil {
-		logger.Error(err3.Error())
-		Resp(c, false, http.StatusInternalServerError, "上传相关文件失败", nil)
+		logger.Error("数据库操作失败: " + err.Error())
+		Resp(c, false, http.StatusInternalServerError, "创建商标失败", nil)
 		return
 	}
 	// 5. 返回成功响应
@@ -102,7 +89,7 @@ func CreateTrademark(c *gin.Context) {
 		"trademarkId": trademark.ID,
 		"title":       trademark.Title,
 		"firstAuthor": trademark.FirstAuthorID,
-		"fileCount":   len(Files),
+		"fileCount":   len(staged),
 	})
 }
 
@@ -147,8 +134,15 @@ func GetAllTrademarks(c *gin.Context) {
 
 // GetTrademarkFile 获取文件地址
 func GetTrademarkFile(c *gin.Context) {
-	value := c.Query("id")
-	atoi, _ := strconv.Atoi(value)
+	atoi, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		Resp(c, false, http.StatusBadRequest, "无效的ID", nil)
+		return
+	}
+	// 附件列表与 /:id/attachments 相同，只有作者和管理、审批人员可以查看
+	if !allowAssetReader(c, models.AssetTypeTrademark, atoi) {
+		return
+	}
 	file, err := models.GetTrademarkFile(atoi)
 	if err != nil {
 		logger.Error(err.Error())
@@ -163,6 +157,10 @@ func DeleteTrademark(c *gin.Context) {
 	id := c.Query("id")
 
 	atoi, _ := strconv.Atoi(id)
+	// 只有第一作者或管理员可以删除
+	if !allowAssetOwner(c, models.AssetTypeTrademark, atoi) {
+		return
+	}
 	if err := models.DeleteTrademark(atoi); err != nil {
 		logger.Error(err.Error())
 		Resp(c, false, http.StatusInternalServerError, "删除失败", nil)
@@ -172,18 +170,15 @@ func DeleteTrademark(c *gin.Context) {
 }
 
 // UpdateTrademarkStatus 更新审核状态
+// 审批人取自访问令牌，不再信任表单中的 reviewer_id
 func UpdateTrademarkStatus(c *gin.Context) {
 	value := c.PostForm("trademark_id")
-	form := c.PostForm("reviewer_id")
 	comment := c.PostForm("comment")
 	v := c.PostForm("status")
 	trademark_id, _ := strconv.Atoi(value)
-	reviewer_id, _ := strconv.Atoi(form)
 	status, _ := strconv.Atoi(v)
-	//审核没通过
-	if err := models.UpdateTrademarkStatus(trademark_id, reviewer_id, comment, status); err != nil {
-		logger.Error(err.Error())
-		Resp(c, false, http.StatusInternalServerError, "更新失败", nil)
+	if err := models.UpdateTrademarkStatus(trademark_id, currentUserID(c), comment, status); err != nil {
+		respondReviewError(c, err)
 		return
 	}
 	Resp(c, true, http.StatusOK, "更新成功", nil)
@@ -237,20 +232,3 @@ func GetAllTrademarkFees(c *gin.Context) {
 		"total": total,
 	})
 }
-
-// GetMonthlyTrademarkFeeStatsService 获取本月商标年费统计信息服务方法
-func GetMonthlyTrademarkFeeStatsService(c *gin.Context) {
-	pendingCount, paidAmount, overdueCount, totalAmount, err := models.GetMonthlyTrademarkFeeStats()
-	if err != nil {
-		utils.Logger.Error(err.Error())
-		Resp(c, false, http.StatusInternalServerError, "获取本月商标年费统计信息失败", nil)
-		return
-	}
-
-	Resp(c, true, http.StatusOK, "获取本月商标年费统计信息成功", gin.H{
-		"pending_count": pendingCount,
-		"paid_amount":   paidAmount,
-		"overdue_count": overdueCount,
-		"total_amount":  totalAmount,
-	})
-}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"intellectual_property/pkg/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// createUploadRequest 创建分片上传任务的请求
+type createUploadRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Size   int64  `json:"size" binding:"required"`
+	SHA256 string `json:"sha256"` // 整个文件的 SHA-256，可选，提供时合并后校验
+}
+
+// uploadSession 解析路径参数并查询上传任务，只有资产第一作者或资产管理员可以操作
+func uploadSession(c *gin.Context, assetType string) (*models.UploadSession, bool) {
+	assetID, _, ok := attachmentParams(c)
+	if !ok || !allowAssetOwner(c, assetType, assetID) {
+		return nil, false
+	}
+	session, err := models.GetUploadSession(assetType, assetID, c.Param("uploadId"))
+	if err != nil {
+		respAttachmentError(c, err, "查询上传任务失败")
+		return nil, false
+	}
+	return session, true
+}
+
+// CreateUpload 创建分片上传任务
+// 请求体为JSON：{"name":"设计图.zip","size":524288000,"sha256":"..."}，返回任务ID、分片大小和分片数
+func CreateUpload(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assetID, _, ok := attachmentParams(c)
+		if !ok || !allowAssetOwner(c, assetType, assetID) {
+			return
+		}
+		var req createUploadRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			Resp(c, false, http.StatusBadRequest, "参数格式错误", nil)
+			return
+		}
+		session, err := models.CreateUploadSession(assetType, assetID, currentUserID(c), req.Name, req.Size, req.SHA256)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				Resp(c, false, http.StatusNotFound, "申请不存在", nil)
+				return
+			}
+			respAttachmentError(c, err, "创建上传任务失败")
+			return
+		}
+		Resp(c, true, http.StatusCreated, "创建成功", session)
+	}
+}
+
+// GetUpload 查询上传任务，断点续传时根据 received_chunks 跳过已上传的分片
+func GetUpload(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := uploadSession(c, assetType)
+		if !ok {
+			return
+		}
+		Resp(c, true, http.StatusOK, "查询成功", session)
+	}
+}
+
+// PutUploadChunk 上传一个分片
+// 请求体为分片内容，请求头 X-Chunk-Sha256 为分片的 SHA-256；除最后一片外大小必须等于 chunk_size
+func PutUploadChunk(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := uploadSession(c, assetType)
+		if !ok {
+			return
+		}
+		index, err := strconv.Atoi(c.Param("index"))
+		if err != nil {
+			Resp(c, false, http.StatusBadRequest, "无效的分片序号", nil)
+			return
+		}
+		checksum := c.GetHeader("X-Chunk-Sha256")
+		if checksum == "" {
+			Resp(c, false, http.StatusBadRequest, "缺少分片校验和", nil)
+			return
+		}
+		if c.Request.ContentLength < 0 {
+			Resp(c, false, http.StatusBadRequest, "缺少分片大小", nil)
+			return
+		}
+		if err := models.PutUploadChunk(session, index, c.Request.Body, c.Request.ContentLength, checksum); err != nil {
+			respAttachmentError(c, err, "上传分片失败")
+			return
+		}
+		Resp(c, true, http.StatusOK, "上传成功", gin.H{"index": index})
+	}
+}
+
+// CompleteUpload 合并全部分片并登记为附件，已有同名附件时保存为新版本
+func CompleteUpload(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := uploadSession(c, assetType)
+		if !ok {
+			return
+		}
+		attachment, err := models.CompleteUploadSession(session)
+		if err != nil {
+			respAttachmentError(c, err, "合并文件失败")
+			return
+		}
+		attachment.DownloadURL = models.AttachmentDownloadPath(attachment)
+		Resp(c, true, http.StatusCreated, "上传成功", attachment)
+	}
+}
+
+// AbortUpload 取消上传，删除已上传的分片
+func AbortUpload(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := uploadSession(c, assetType)
+		if !ok {
+			return
+		}
+		if err := models.AbortUploadSession(session); err != nil {
+			respAttachmentError(c, err, "取消上传失败")
+			return
+		}
+		Resp(c, true, http.StatusOK, "已取消", nil)
+	}
+}
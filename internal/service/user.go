@@ -1,12 +1,15 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"intellectual_property/pkg/models"
 	"intellectual_property/pkg/utils"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 )
 
 // 封装返回码
@@ -25,17 +28,8 @@ var Resp = utils.Resp
 
 // GetSimpleUsers 获取所有用户部分数据--以及自己的信息
 func GetSimpleUsers(c *gin.Context) {
-	//解析token
-	authHeader := c.GetHeader("Authorization")
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	claims, err := utils.ParseToken(tokenString)
-	if err != nil {
-		logger.Error("Token解析失败: " + err.Error())
-		Resp(c, false, http.StatusUnauthorized, "无效的访问令牌", nil)
-		return
-	}
-	//拿到本身的信息
-	user, err2 := models.GetSimpleUserByID(claims.UserID)
+	//拿到本身的信息（用户ID由JWT中间件写入上下文）
+	user, err2 := models.GetSimpleUserByID(currentUserID(c))
 	if err2 != nil {
 		logger.Error("查询失败: " + err2.Error())
 		Resp(c, false, http.StatusBadRequest, "查询失败", nil)
@@ -62,6 +56,11 @@ func AddUser(c *gin.Context) {
 	password := c.PostForm("password")
 	idcard := c.PostForm("idCard")
 	gender := c.PostForm("gender")
+	//校验密码策略
+	if err := utils.ValidatePassword(password); err != nil {
+		Resp(c, false, http.StatusBadRequest, err.Error(), "")
+		return
+	}
 	//根据email查询用户是否存在
 	_, err := models.GetUserByEmail(email)
 	if err != nil {
@@ -69,33 +68,19 @@ func AddUser(c *gin.Context) {
 		Resp(c, false, UserExists, "用户存在", "")
 		return
 	}
-	//首先需要验证code是否正确
-	//如果不存在
-	re_code, err := utils.RedisGet(email)
+	//首先需要验证code是否正确，输错次数过多时验证码作废，通过后验证码失效
+	ok, err := utils.VerifyEmailCode(email, code)
 	if err != nil {
 		logger.Error(err.Error())
 		Resp(c, false, SystemError, "系统错误", "")
 		return
 	}
-	if re_code != code {
-		Resp(c, false, http.StatusBadRequest, "验证码错误", "")
-		return
-	}
-	//验证完删除缓存
-	err = utils.RedisDel(email)
-	if err != nil {
-		logger.Error(err.Error())
-		Resp(c, false, SystemError, "系统错误", "")
+	if !ok {
+		Resp(c, false, http.StatusBadRequest, "验证码错误或已过期", "")
 		return
 	}
 	//拿到加密密码
-	salt, err := utils.GenerateSecureSalt()
-	if err != nil {
-		logger.Error(err.Error())
-		Resp(c, false, SystemError, "系统错误", "")
-		return
-	}
-	EnPassword, err1 := utils.SecureHashWithSalt(password, salt)
+	EnPassword, err1 := utils.HashPassword(password)
 	if err1 != nil {
 		logger.Error(err1.Error())
 		Resp(c, false, SystemError, "系统错误", "")
@@ -105,7 +90,7 @@ func AddUser(c *gin.Context) {
 	u := models.User{
 		UserName:  username,
 		Email:     email,
-		Password:  salt + ":" + EnPassword,
+		Password:  EnPassword,
 		IDCard:    idcard,
 		Sex:       gender,
 		Status:    "1",
@@ -119,6 +104,10 @@ func AddUser(c *gin.Context) {
 		Resp(c, false, SystemError, "添加失败", "")
 		return
 	}
+	//新用户默认为申请人
+	if err := models.AssignRole(u.ID, models.RoleApplicant); err != nil {
+		logger.Error(err.Error())
+	}
 
 	//返回成功json-
 	Resp(c, true, http.StatusOK, "注册成功", gin.H{})
@@ -134,6 +123,10 @@ func FindUsersByID(c *gin.Context) {
 		Resp(c, false, SystemError, "系统错误", "")
 		return
 	}
+	//只能查看自己的详细信息，管理员除外
+	if !allowSelfOr(c, ids, models.PermRoleManage) {
+		return
+	}
 	user, err := models.GetUserByID(ids)
 	if err != nil {
 		logger.Error(err.Error())
@@ -162,6 +155,10 @@ func ModifyTheUser(c *gin.Context) {
 		})
 		return
 	}
+	//只能修改自己的信息，管理员除外
+	if !allowSelfOr(c, user_id, models.PermRoleManage) {
+		return
+	}
 	dep_ID, err1 := strconv.Atoi(dep_id)
 	if err1 != nil {
 		logger.Error(err1.Error())
@@ -170,6 +167,25 @@ func ModifyTheUser(c *gin.Context) {
 		})
 		return
 	}
+	//所属部门只能由管理员修改，否则用户可以把自己改到有减缴资格的部门
+	canManage, err := models.UserHasPermission(currentUserID(c), models.PermRoleManage)
+	if err != nil {
+		logger.Error(err.Error())
+		Resp(c, false, SystemError, "系统错误", "")
+		return
+	}
+	if !canManage {
+		old, err := models.GetUserByID(user_id)
+		if err != nil {
+			logger.Error(err.Error())
+			Resp(c, false, SystemError, "系统错误", "")
+			return
+		}
+		if old.DepID != dep_ID {
+			Resp(c, false, http.StatusForbidden, "只有管理员可以修改所属部门", "")
+			return
+		}
+	}
 
 	us := models.User{
 		ID:         user_id,
@@ -183,7 +199,7 @@ func ModifyTheUser(c *gin.Context) {
 		AvatarUrl:  avatarUrl,
 	}
 	//更新用户
-	err2 := models.UpdateUser(&us)
+	err2 := models.UpdateUser(&us, canManage)
 	if err2 != nil {
 		logger.Error(err2.Error())
 		Resp(c, false, http.StatusBadRequest, "修改失败", gin.H{
@@ -200,6 +216,11 @@ func UploadAvatar(c *gin.Context) {
 	//实现头像文件上传并拿到地址
 	file, err4 := c.FormFile("avatar")
 	id := c.PostForm("user_id")
+	if err4 != nil {
+		respFormFileError(c, err4, "修改失败")
+		return
+	}
+	atoi, err4 := strconv.Atoi(id)
 	if err4 != nil {
 		logger.Error(err4.Error())
 		Resp(c, false, http.StatusBadRequest, "修改失败", gin.H{
@@ -207,7 +228,23 @@ func UploadAvatar(c *gin.Context) {
 		})
 		return
 	}
-	err := c.SaveUploadedFile(file, utils.NgX.LocationAvatar+"/"+file.Filename)
+	//只能修改自己的头像，管理员除外
+	if !allowSelfOr(c, atoi, models.PermRoleManage) {
+		return
+	}
+	// 校验并扫描后重新编码为 PNG，文件名由用户ID和时间生成，不使用客户端文件名
+	ctx := context.Background()
+	if _, err := utils.ValidateUpload(ctx, utils.UploadAvatar, file); err != nil {
+		respAttachmentError(c, err, "修改失败")
+		return
+	}
+	data, err := utils.ReencodeAvatar(file)
+	if err != nil {
+		respAttachmentError(c, err, "修改失败")
+		return
+	}
+	key := fmt.Sprintf("avatar/%d-%d.png", atoi, time.Now().UnixNano())
+	err = utils.PublicStore.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "image/png")
 	if err != nil {
 		logger.Error(err.Error())
 		Resp(c, false, http.StatusBadRequest, "修改失败", gin.H{
@@ -216,19 +253,11 @@ func UploadAvatar(c *gin.Context) {
 		return
 	}
 	//拼接地址
-	avatarUrl := utils.NgX.Url + "/" + "avatar" + "/" + file.Filename
+	avatarUrl := utils.PublicStore.URL(key)
 	//上传数据库
-	atoi, err4 := strconv.Atoi(id)
-	if err4 != nil {
-		logger.Error(err.Error())
-		Resp(c, false, http.StatusBadRequest, "修改失败", gin.H{
-			"error": err4.Error(),
-		})
-		return
-	}
 	err4 = models.UploadAvatar(atoi, avatarUrl)
 	if err4 != nil {
-		logger.Error(err.Error())
+		logger.Error(err4.Error())
 		Resp(c, false, http.StatusBadRequest, "修改失败", gin.H{
 			"error": err4.Error(),
 		})
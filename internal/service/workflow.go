@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAllWorkflows 获取所有审批流程定义
+func GetAllWorkflows(c *gin.Context) {
+	workflows, err := models.GetAllWorkflows()
+	if err != nil {
+		utils.Logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "获取审批流程失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "获取审批流程成功", workflows)
+}
+
+// SaveWorkflow 新增或替换审批流程
+// 请求体为JSON：{"asset_type":"patent","sub_type":0,"name":"...","stages":[{"step":1,"name":"...","required_role":"..."}]}
+func SaveWorkflow(c *gin.Context) {
+	var workflow models.WorkflowDefinition
+	if err := c.ShouldBindJSON(&workflow); err != nil {
+		utils.Logger.Error(err.Error())
+		Resp(c, false, http.StatusBadRequest, "参数格式错误", nil)
+		return
+	}
+	if err := workflow.Validate(); err != nil {
+		Resp(c, false, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if err := models.SaveWorkflow(&workflow); err != nil {
+		utils.Logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "保存审批流程失败", nil)
+		return
+	}
+	Resp(c, true, http.StatusOK, "保存审批流程成功", workflow)
+}
+
+// respondReviewError 审批失败时的统一响应
+// 审批人资格不符返回403，流程已结束返回409，其余视为系统错误
+func respondReviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrReviewerRole),
+		errors.Is(err, models.ErrReviewerIsAuthor),
+		errors.Is(err, models.ErrReviewerRepeated):
+		Resp(c, false, http.StatusForbidden, err.Error(), nil)
+	case errors.Is(err, models.ErrApprovalFinished), errors.Is(err, models.ErrFeeTariffNotFound):
+		Resp(c, false, http.StatusConflict, err.Error(), nil)
+	default:
+		utils.Logger.Error(err.Error())
+		Resp(c, false, http.StatusInternalServerError, "更新失败", nil)
+	}
+}
@@ -1,5 +1,15 @@
 package models
 
+import (
+	"errors"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
 // 审批状态常量定义
 // 用于管理著作的审批流程状态
 const (
@@ -8,17 +18,217 @@ const (
 	ApprovalStatusRejected   = 2 // 审批已驳回（任一环节驳回）
 )
 
-// 审批步骤常量定义
-// 表示当前所处的审批阶段
+// ApprovalStepInitial 审批流程的第一个环节序号
+// 具体有几个环节由 WorkflowDefinition 决定
+const ApprovalStepInitial = 1
+
+// 审批结论常量定义
+const (
+	ApprovalDecisionReject = 0 // 驳回
+	ApprovalDecisionPass   = 1 // 通过
+)
+
+// 审批事件类型
 const (
-	ApprovalStepInitial = 1 // 初审阶段（第一个审批环节）
-	ApprovalStepFinal   = 2 // 终审阶段（最终审批环节）
+	ApprovalEventSubmit      = "submit"       // 提交申请
+	ApprovalEventApprove     = "approve"      // 环节审批通过
+	ApprovalEventReject      = "reject"       // 环节审批驳回
+	ApprovalEventResubmit    = "resubmit"     // 驳回后重新提交
+	ApprovalEventFeeCreated  = "fee_created"  // 生成费用
+	ApprovalEventFeePaid     = "fee_paid"     // 费用已支付
+	ApprovalEventFeeRefunded = "fee_refunded" // 费用已退款
+	ApprovalEventFeeWaived   = "fee_waived"   // 费用已免除或减免
+	ApprovalEventFeeOverdue  = "fee_overdue"  // 费用已逾期
+	ApprovalEventLapsed      = "lapsed"       // 费用宽限期满未缴，资产失效
+)
+
+// ApprovalEvent 审批事件
+// 只追加不修改，记录资产从提交、审批、驳回、重新提交到缴费的全过程，用于审计时间线
+type ApprovalEvent struct {
+	ID        int       `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	AssetType string    `json:"asset_type" gorm:"type:varchar(20);index:idx_approval_event_asset;comment:资产类型"`
+	AssetID   int       `json:"asset_id" gorm:"type:bigint;index:idx_approval_event_asset;comment:资产ID"`
+	EventType string    `json:"event_type" gorm:"type:varchar(20);comment:事件类型"`
+	Revision  int       `json:"revision" gorm:"type:int;comment:事件发生时的提交版本号"`
+	Step      int       `json:"step" gorm:"type:int;comment:审批环节序号(审批事件)"`
+	StageName string    `json:"stage_name" gorm:"type:varchar(100);comment:审批环节名称(审批事件)"`
+	ActorID   int       `json:"actor_id" gorm:"type:bigint;comment:操作人ID"`
+	Actor     User      `json:"actor" gorm:"foreignKey:ActorID"`
+	Comment   string    `json:"comment" gorm:"type:text;comment:审批意见或说明"`
+	Detail    string    `json:"detail" gorm:"type:text;comment:事件详情"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:datetime(3);comment:发生时间"`
+}
+
+// ApprovalDB 全局数据库连接实例
+var ApprovalDB *gorm.DB = utils.DB
+
+// 审批相关错误
+var (
+	ErrApprovalFinished = errors.New("审批流程已结束")
+	ErrReviewerRole     = errors.New("没有当前审批环节所需的审批角色")
+	ErrReviewerIsAuthor = errors.New("作者不能审批自己的申请")
+	ErrReviewerRepeated = errors.New("同一申请的不同审批环节必须由不同的人审批")
 )
 
+// ReviewAsset 审批引擎入口
+// 专利、著作、商标的审批都通过这里完成：
+// 1. 锁定资产记录，读取当前环节和子类型
+// 2. 找到对应的审批流程，校验审批人资格
+// 3. 计算下一步，写入审批事件并更新资产的审批状态
+// 4. 审批通过时生成授权后的费用计划
+func ReviewAsset(assetType string, assetID int, reviewerID int, comment string, decision int) error {
+	meta, err := getAssetMeta(assetType)
+	if err != nil {
+		return err
+	}
+
+	return ApprovalDB.Transaction(func(tx *gorm.DB) error {
+		var state assetState
+		if err := tx.Table(meta.Table).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", meta.TypeColumn+" AS sub_type", "current_step", "approval_status", "revision").
+			Where("id = ?", assetID).
+			Take(&state).Error; err != nil {
+			return err
+		}
+		if state.ApprovalStatus != ApprovalStatusInProgress {
+			return ErrApprovalFinished
+		}
+
+		workflow, err := FindWorkflow(tx, assetType, state.SubType)
+		if err != nil {
+			return err
+		}
+		stage, nextStep, status, err := workflow.Advance(state.CurrentStep, decision == ApprovalDecisionPass)
+		if err != nil {
+			return err
+		}
+		if err := checkReviewer(tx, meta, assetType, state, stage, reviewerID); err != nil {
+			return err
+		}
+
+		eventType := ApprovalEventReject
+		if decision == ApprovalDecisionPass {
+			eventType = ApprovalEventApprove
+		}
+		if err := RecordApprovalEvent(tx, &ApprovalEvent{
+			AssetType: assetType,
+			AssetID:   assetID,
+			EventType: eventType,
+			Revision:  state.Revision,
+			Step:      stage.Step,
+			StageName: stage.Name,
+			ActorID:   reviewerID,
+			Comment:   comment,
+		}); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Table(meta.Table).Where("id = ?", assetID).Updates(map[string]interface{}{
+			"current_step":    nextStep,
+			"approval_status": status,
+			"updated_at":      now,
+		}).Error; err != nil {
+			return err
+		}
+
+		// 最后一个环节通过即视为授权，生成授权后的年费或续展费计划
+		if status == ApprovalStatusApproved {
+			return generateFeeSchedule(tx, assetType, assetID, state.SubType, reviewerID, now)
+		}
+		return nil
+	})
+}
+
+// checkReviewer 校验审批人资格
+//   - 审批人必须拥有当前环节要求的角色
+//   - 作者不能审批自己的申请（利益冲突）
+//   - 同一版本的不同环节不能由同一个人审批，例如初审人和终审人必须不同
+func checkReviewer(tx *gorm.DB, meta assetMeta, assetType string, state assetState, stage WorkflowStage, reviewerID int) error {
+	ok, err := userHasRole(tx, reviewerID, stage.RequiredRole)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrReviewerRole
+	}
+
+	var count int64
+	if err := tx.Table(meta.AuthorTable).
+		Where(meta.AuthorKey+" = ? AND user_id = ?", state.ID, reviewerID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrReviewerIsAuthor
+	}
+
+	if err := tx.Model(&ApprovalEvent{}).
+		Where("asset_type = ? AND asset_id = ? AND revision = ? AND event_type = ? AND actor_id = ?",
+			assetType, state.ID, state.Revision, ApprovalEventApprove, reviewerID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrReviewerRepeated
+	}
+	return nil
+}
+
+// RecordApprovalEvent 追加一条审批事件
+// 需要和业务数据在同一事务中写入，调用方传入事务 tx
+func RecordApprovalEvent(tx *gorm.DB, event *ApprovalEvent) error {
+	event.ID = 0
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	return tx.Create(event).Error
+}
+
+// recordSubmitEvents 记录提交申请和生成审核费用两条事件
+func recordSubmitEvents(tx *gorm.DB, assetType string, assetID int, submitterID int, feeID int, amount float64) error {
+	if err := RecordApprovalEvent(tx, &ApprovalEvent{
+		AssetType: assetType,
+		AssetID:   assetID,
+		EventType: ApprovalEventSubmit,
+		Revision:  1,
+		Step:      ApprovalStepInitial,
+		ActorID:   submitterID,
+	}); err != nil {
+		return err
+	}
+	return RecordApprovalEvent(tx, &ApprovalEvent{
+		AssetType: assetType,
+		AssetID:   assetID,
+		EventType: ApprovalEventFeeCreated,
+		Revision:  1,
+		ActorID:   submitterID,
+		Detail:    fmt.Sprintf("费用ID:%d 金额:%.2f", feeID, amount),
+	})
+}
+
+// GetAssetTimeline 查询某个资产的完整事件时间线，按发生顺序排列
+func GetAssetTimeline(assetType string, assetID int) ([]ApprovalEvent, error) {
+	if _, err := getAssetMeta(assetType); err != nil {
+		return nil, err
+	}
+	var events []ApprovalEvent
+	if err := ApprovalDB.Preload("Actor", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "user_name")
+	}).Where("asset_type = ? AND asset_id = ?", assetType, assetID).
+		Order("created_at ASC, id ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
 // 特殊说明：
 // 审批流程逻辑：
-// 1. 新建著作默认状态为 ApprovalStatusInProgress
-// 2. 审批步骤从 ApprovalStepInitial 开始
-// 3. 当初审通过后，进入 ApprovalStepFinal 阶段
+// 1. 新建资产默认状态为 ApprovalStatusInProgress，环节为 ApprovalStepInitial
+// 2. 每个资产类型/子类型对应一个 WorkflowDefinition，定义若干审批环节
+// 3. 当前环节通过后进入下一个环节，最后一个环节通过后状态变为 ApprovalStatusApproved
 // 4. 任一环节驳回即终止流程，状态变为 ApprovalStatusRejected
-// 5. 终审通过后状态变为 ApprovalStatusApproved
+// 5. 提交、审批、驳回、重新提交和缴费都追加到 ApprovalEvent 中，形成审计时间线
+// 6. 审批通过后专利按保护期限生成年费，商标生成续展费，金额见 config/fee.yaml
@@ -4,11 +4,6 @@ import (
 	"errors"
 	"gorm.io/gorm"
 	"intellectual_property/pkg/utils"
-	"log"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -28,20 +23,12 @@ type Article struct {
 	Authors       []ArticleAuthor `json:"authors" gorm:"foreignKey:ArticleID;comment:所有作者关联记录"`
 
 	// 审批流程字段
-	CurrentStep    int `json:"current_step" gorm:"type:int;comment:当前审批步骤(1=初审,2=终审)"`
+	CurrentStep    int `json:"current_step" gorm:"type:int;comment:当前审批步骤(对应审批流程中的环节序号)"`
 	ApprovalStatus int `json:"approval_status" gorm:"type:int;comment:整体审批状态(0=进行中,1=通过,2=驳回)"`
+	Revision       int `json:"revision" gorm:"type:int;default:1;comment:提交版本号(驳回后每次重新提交加1)"`
 
-	// 初审相关字段
-	InitialReviewerID int       `json:"initial_reviewer_id" gorm:"type:bigint;comment:初审人ID"`
-	InitialComment    string    `json:"initial_comment" gorm:"type:text;comment:初审意见"`
-	InitialSubmitTime time.Time `json:"initial_submit_time" gorm:"type:datetime;comment:初审提交时间"`
-	InitialStatus     bool      `json:"initial_status" gorm:"comment:初审状态"`
-
-	// 终审相关字段
-	FinalReviewerID int       `json:"final_reviewer_id" gorm:"type:bigint;comment:终审人ID"`
-	FinalComment    string    `json:"final_comment" gorm:"type:text;comment:终审意见"`
-	FinalSubmitTime time.Time `json:"final_submit_time" gorm:"type:datetime;comment:终审提交时间"`
-	FinalStatus     bool      `json:"final_status" gorm:"comment:终审状态"`
+	// 费用减缴代码，为空表示不减缴
+	DiscountCode string `json:"discount_code" gorm:"type:varchar(50);comment:费用减缴代码"`
 
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
@@ -89,24 +76,14 @@ func NewArticle(
 			IsFirstAuthor: uid == firstAuthorID,
 		})
 	}
-	// 初始化年费对象
-	var reviewFee float64
-	switch articleType {
-	case PracticalInvention:
-		reviewFee = 1
-	case PatentInvention:
-		reviewFee = 2
-	case AppearanceDesign:
-		reviewFee = 1
-	}
-
+	// 初始化年费对象，审核费金额在创建时按费用标准计算
 	patentFee := &ArticleFee{
 		ArticleID:       0, // 后续在创建专利时更新
-		ReviewFee:       reviewFee,
 		IsPaid:          false,
 		CreatedAt:       time.Now(),
 		PaymentDeadline: time.Now().AddDate(0, 1, 0), //一个月
 		Status:          0,
+		FeeKind:         FeeKindReview,
 	}
 	return &Article{
 		ArticleType:    articleType,
@@ -115,8 +92,9 @@ func NewArticle(
 		FirstAuthorID:  firstAuthorID,
 		Authors:        authors,
 		ApplyDate:      time.Now(),
-		CurrentStep:    1,
+		CurrentStep:    ApprovalStepInitial,
 		ApprovalStatus: 0,
+		Revision:       1,
 	}, patentFee, nil
 }
 
@@ -135,8 +113,17 @@ func contains(s []int, e int) bool {
 // 1. 创建主记录
 // 2. 创建作者关联记录
 // 3. 更新第一作者外键
-func (article *Article) CreateArticleService(articlefee *ArticleFee) error {
+// 4. 登记已写入存储的附件
+// 5. 记录提交申请和生成费用事件
+func (article *Article) CreateArticleService(articlefee *ArticleFee, submitterID int, attachments []StagedAttachment) error {
 	return ArticleDB.Transaction(func(tx *gorm.DB) error {
+		// 按当前费用标准和减缴政策计算审核费
+		reviewFee, err := QuoteReviewFee(tx, AssetTypeArticle, article.ArticleType, article.DiscountCode)
+		if err != nil {
+			return err
+		}
+		articlefee.ReviewFee = reviewFee
+
 		// 创建主记录
 		if err := tx.Create(article).Error; err != nil {
 			return err
@@ -169,13 +156,14 @@ func (article *Article) CreateArticleService(articlefee *ArticleFee) error {
 			return err
 		}
 
-		return nil
-	})
-}
+		// 登记已写入存储的附件
+		if _, err := registerAttachments(tx, AssetTypeArticle, article.ID, submitterID, attachments); err != nil {
+			return err
+		}
 
-// UpdateArticleUrl 更新资源url
-func (article *Article) UpdateArticleUrl() error {
-	return ArticleDB.Model(&Article{}).Where("id =?", article.ID).Select("attachment_url").Updates(article).Error
+		// 记录提交申请和生成费用事件
+		return recordSubmitEvents(tx, AssetTypeArticle, article.ID, submitterID, articlefee.ID, articlefee.ReviewFee)
+	})
 }
 
 // GetAllArticles 获取所有著作及其关联信息
@@ -252,21 +240,9 @@ func GetAllArticles(
 	return articles, total, nil
 }
 
-// GetArticleFile 根据第文章id来查询所有文件地址
-func GetArticleFile(id int) ([]string, error) {
-	var ans []string
-	var article Article
-	PatentDB.Model(&Article{}).Select("application_number,attachment_url").Where("id =?", id).Find(&article)
-
-	baseurl := utils.NgX.LocationArticle + "/" + article.ApplicationNumber
-	dir, err := os.ReadDir(baseurl)
-	if err != nil {
-		return nil, err
-	}
-	for _, file := range dir {
-		ans = append(ans, "/article/"+article.ApplicationNumber+"/"+file.Name())
-	}
-	return ans, nil
+// GetArticleFile 查询著作附件的最新版本
+func GetArticleFile(id int) ([]Attachment, error) {
+	return ListAttachments(AssetTypeArticle, id, false)
 }
 
 // DeleteArticle 删除著作及其关联数据
@@ -277,13 +253,19 @@ func GetArticleFile(id int) ([]string, error) {
 //   - error 错误信息
 func DeleteArticle(id int) error {
 	// 开启数据库事务
+	var task *StorageTask
 	err := ArticleDB.Transaction(func(tx *gorm.DB) error {
 		// 1. 删除作者关联记录
 		if err := tx.Where("article_id = ?", id).Delete(&ArticleAuthor{}).Error; err != nil {
 			return err
 		}
+		// 2. 删除附件记录，登记文件删除任务
+		var err error
+		if task, err = deleteAssetFiles(tx, AssetTypeArticle, id); err != nil {
+			return err
+		}
 
-		// 2. 删除主记录（修正后的版本）
+		// 3. 删除主记录（修正后的版本）
 		if err := tx.Where("id = ?", id).Delete(&Article{}).Error; err != nil {
 			return err
 		}
@@ -291,31 +273,14 @@ func DeleteArticle(id int) error {
 		return nil
 	})
 
-	// 3. 清理文件系统（在事务成功后执行）
-	if err == nil {
-		if cleanErr := cleanArticleFiles(id); cleanErr != nil {
-			log.Printf("文件清理失败: %v", cleanErr)
-		}
+	// 4. 清理文件系统（在事务成功后执行，失败的由定时任务重试）
+	if err == nil && task != nil {
+		runStorageTasksNow([]*StorageTask{task})
 	}
 
 	return err
 }
 
-// cleanArticleFiles 清理著作相关文件
-func cleanArticleFiles(id int) error {
-	// 构建安全路径
-	safePath := filepath.Join(utils.NgX.LocationArticle, "/", strconv.Itoa(id))
-
-	// 验证路径是否在允许的根目录下（防止路径遍历攻击）
-	if !strings.HasPrefix(filepath.Clean(safePath)+string(filepath.Separator),
-		filepath.Clean(utils.NgX.LocationArticle)+string(filepath.Separator)) {
-		return errors.New("非法路径")
-	}
-
-	// 删除目录及其内容
-	return os.RemoveAll(safePath)
-}
-
 // GetArticleById 根据id查询Article
 func GetArticleById(id int) (Article, error) {
 	var article Article
@@ -326,74 +291,12 @@ func GetArticleById(id int) (Article, error) {
 }
 
 // UpdateArticleStatus 更新状态
+// 审批流转统一交由审批引擎 ReviewAsset 处理
 func UpdateArticleStatus(
 	articleId int,
 	ReviewerID int,
 	Comment string,
 	Status int, // 0=驳回，1=通过
 ) error {
-	// 查询完整文章信息
-	article, err := GetArticleById(articleId)
-	if err != nil {
-		return err
-	}
-
-	now := time.Now()
-	updates := make(map[string]interface{})
-
-	if article.CurrentStep == ApprovalStepInitial {
-		// 初审逻辑
-		article.InitialReviewerID = ReviewerID
-		article.InitialComment = Comment
-		article.InitialSubmitTime = now
-		article.InitialStatus = Status == 1
-
-		if Status == 1 {
-			// 初审通过，进入终审
-			article.CurrentStep = ApprovalStepFinal
-		} else {
-			// 初审驳回
-			article.ApprovalStatus = ApprovalStatusRejected
-		}
-
-		updates = map[string]interface{}{
-			"current_step":        article.CurrentStep,
-			"approval_status":     article.ApprovalStatus,
-			"initial_reviewer_id": article.InitialReviewerID,
-			"initial_comment":     article.InitialComment,
-			"initial_submit_time": article.InitialSubmitTime,
-			"initial_status":      article.InitialStatus,
-		}
-	} else if article.CurrentStep == ApprovalStepFinal {
-		// 终审逻辑
-		article.FinalReviewerID = ReviewerID
-		article.FinalComment = Comment
-		article.FinalSubmitTime = now
-		article.FinalStatus = (Status == 1)
-
-		if Status == 1 {
-			// 终审通过
-			article.ApprovalStatus = ApprovalStatusApproved
-		} else {
-			// 终审驳回
-			article.ApprovalStatus = ApprovalStatusRejected
-		}
-
-		updates = map[string]interface{}{
-			"approval_status":   article.ApprovalStatus,
-			"final_reviewer_id": article.FinalReviewerID,
-			"final_comment":     article.FinalComment,
-			"final_submit_time": article.FinalSubmitTime,
-			"final_status":      article.FinalStatus,
-		}
-	}
-
-	// 执行数据库更新
-	if err := ArticleDB.Model(&Article{}).
-		Where("id = ?", articleId).
-		Updates(updates).Error; err != nil {
-		return err
-	}
-
-	return nil
+	return ReviewAsset(AssetTypeArticle, articleId, ReviewerID, Comment, Status)
 }
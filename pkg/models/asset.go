@@ -0,0 +1,92 @@
+package models
+
+import "errors"
+
+// 知识产权资产类型
+// 专利、著作、商标三类资产共用审批流程等通用能力时使用
+const (
+	AssetTypePatent    = "patent"    // 专利
+	AssetTypeArticle   = "article"   // 著作
+	AssetTypeTrademark = "trademark" // 商标
+)
+
+// assetMeta 不同资产类型在数据库中的表结构差异
+type assetMeta struct {
+	Table       string // 主表名
+	TypeColumn  string // 子类型字段
+	AuthorTable string // 作者关联表
+	AuthorKey   string // 作者关联表中指向主表的字段
+}
+
+// assetMetas 资产类型与表结构的映射
+var assetMetas = map[string]assetMeta{
+	AssetTypePatent: {
+		Table:       "patents",
+		TypeColumn:  "patent_type",
+		AuthorTable: "patent_authors",
+		AuthorKey:   "patent_id",
+	},
+	AssetTypeArticle: {
+		Table:       "articles",
+		TypeColumn:  "article_type",
+		AuthorTable: "article_authors",
+		AuthorKey:   "article_id",
+	},
+	AssetTypeTrademark: {
+		Table:       "trademarks",
+		TypeColumn:  "trademark_type",
+		AuthorTable: "trademark_authors",
+		AuthorKey:   "trademark_id",
+	},
+}
+
+// assetState 审批引擎需要的资产状态
+type assetState struct {
+	ID             int
+	SubType        int
+	CurrentStep    int
+	ApprovalStatus int
+	Revision       int
+}
+
+// getAssetMeta 根据资产类型获取表结构信息
+func getAssetMeta(assetType string) (assetMeta, error) {
+	meta, ok := assetMetas[assetType]
+	if !ok {
+		return assetMeta{}, errors.New("未知的资产类型：" + assetType)
+	}
+	return meta, nil
+}
+
+// GetAssetFirstAuthorID 查询资产的第一作者，用于判断操作人是否为申请人本人
+func GetAssetFirstAuthorID(assetType string, assetID int) (int, error) {
+	meta, err := getAssetMeta(assetType)
+	if err != nil {
+		return 0, err
+	}
+	var asset struct {
+		FirstAuthorID int
+	}
+	if err := ApprovalDB.Table(meta.Table).
+		Select("first_author_id").
+		Where("id = ?", assetID).
+		Take(&asset).Error; err != nil {
+		return 0, err
+	}
+	return asset.FirstAuthorID, nil
+}
+
+// IsAssetAuthor 判断用户是否为资产的作者之一
+func IsAssetAuthor(assetType string, assetID int, userID int) (bool, error) {
+	meta, err := getAssetMeta(assetType)
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	if err := ApprovalDB.Table(meta.AuthorTable).
+		Where(meta.AuthorKey+" = ? AND user_id = ?", assetID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
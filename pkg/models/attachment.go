@@ -0,0 +1,412 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"io"
+	"mime"
+	"mime/multipart"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Attachment 资产附件
+// 同一资产下同名文件视为同一个附件，每次替换新增一个版本，旧版本保留可下载
+type Attachment struct {
+	ID          int       `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	OwnerType   string    `json:"owner_type" gorm:"type:varchar(20);uniqueIndex:idx_attachment_version,priority:1;comment:所属资产类型"`
+	OwnerID     int       `json:"owner_id" gorm:"type:bigint;uniqueIndex:idx_attachment_version,priority:2;comment:所属资产ID"`
+	Name        string    `json:"name" gorm:"type:varchar(255);uniqueIndex:idx_attachment_version,priority:3;comment:原始文件名"`
+	Version     int       `json:"version" gorm:"type:int;uniqueIndex:idx_attachment_version,priority:4;comment:版本号，从1开始"`
+	IsCurrent   bool      `json:"is_current" gorm:"comment:是否为最新版本"`
+	StorageKey  string    `json:"-" gorm:"type:varchar(512);comment:存储路径"`
+	Size        int64     `json:"size" gorm:"type:bigint;comment:文件大小(字节)"`
+	MimeType    string    `json:"mime_type" gorm:"type:varchar(100);comment:MIME类型"`
+	SHA256      string    `json:"sha256" gorm:"column:sha256;type:char(64);comment:SHA-256校验和"`
+	UploaderID  int       `json:"uploader_id" gorm:"type:bigint;comment:上传人ID，迁移前的附件为0"`
+	CreatedAt   time.Time `json:"created_at"`
+	DownloadURL string    `json:"download_url" gorm:"-"` // 下载接口地址，不保存
+}
+
+// AttachmentDB 全局数据库连接实例
+var AttachmentDB *gorm.DB = utils.DB
+
+// 附件相关错误
+var (
+	ErrAttachmentNotFound   = errors.New("附件不存在")
+	ErrAttachmentNotCurrent = errors.New("只能替换附件的最新版本")
+	ErrAttachmentExtension  = errors.New("替换文件的类型需与原附件一致")
+)
+
+// AssetFilePrefix 资产文件在存储中的目录：资产类型/申请号/
+func AssetFilePrefix(assetType string, applicationNumber string) string {
+	return assetType + "/" + applicationNumber + "/"
+}
+
+// attachmentKey 附件在存储中的路径：资产类型/申请号/attachments/随机目录/文件名
+// 文件在登记记录之前写入，版本号此时未定，用随机目录保证每次上传互不覆盖
+func attachmentKey(ownerType string, applicationNumber string, name string) (string, error) {
+	token, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	return AssetFilePrefix(ownerType, applicationNumber) + "attachments/" + token + "/" + name, nil
+}
+
+// randomHex n 字节的随机数，十六进制编码
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// getAssetApplicationNumber 查询资产的申请号，附件按申请号存放
+func getAssetApplicationNumber(tx *gorm.DB, assetType string, assetID int) (string, error) {
+	meta, err := getAssetMeta(assetType)
+	if err != nil {
+		return "", err
+	}
+	var asset struct {
+		ApplicationNumber string
+	}
+	if err := tx.Table(meta.Table).Select("application_number").Where("id = ?", assetID).Take(&asset).Error; err != nil {
+		return "", err
+	}
+	return asset.ApplicationNumber, nil
+}
+
+// AssetApplicationNumber 查询资产的申请号，附件写入申请号目录前使用
+func AssetApplicationNumber(assetType string, assetID int) (string, error) {
+	return getAssetApplicationNumber(AttachmentDB, assetType, assetID)
+}
+
+// StagedAttachment 已写入存储、尚未登记到数据库的附件
+// 先写文件再在事务中登记记录；事务失败时调用 DiscardStagedAttachments 删除已写入的文件
+type StagedAttachment struct {
+	Name       string
+	MimeType   string
+	Size       int64
+	SHA256     string
+	StorageKey string
+}
+
+// StageAttachments 校验并把上传的附件写入存储
+// 任意一个文件失败时删除本次已写入的文件并返回错误
+func StageAttachments(assetType string, applicationNumber string, files []*multipart.FileHeader) ([]StagedAttachment, error) {
+	staged := make([]StagedAttachment, 0, len(files))
+	for _, fh := range files {
+		checked, err := utils.ValidateUpload(context.Background(), assetType, fh)
+		if err != nil {
+			DiscardStagedAttachments(staged)
+			return nil, fmt.Errorf("%s: %w", fh.Filename, err)
+		}
+		s, err := stageAttachment(assetType, applicationNumber, checked, utils.FileHeaderOpener(fh))
+		if err != nil {
+			DiscardStagedAttachments(staged)
+			return nil, err
+		}
+		staged = append(staged, s)
+	}
+	return staged, nil
+}
+
+// stageAttachment 把单个已校验的附件写入存储，同时计算校验和
+func stageAttachment(assetType string, applicationNumber string, checked utils.CheckedUpload, open utils.OpenFunc) (StagedAttachment, error) {
+	key, err := attachmentKey(assetType, applicationNumber, checked.Name)
+	if err != nil {
+		return StagedAttachment{}, err
+	}
+	f, err := open()
+	if err != nil {
+		return StagedAttachment{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if err := utils.Store.Put(context.Background(), key, io.TeeReader(f, h), checked.Size, checked.MimeType); err != nil {
+		return StagedAttachment{}, err
+	}
+	return StagedAttachment{
+		Name:       checked.Name,
+		MimeType:   checked.MimeType,
+		Size:       checked.Size,
+		SHA256:     hex.EncodeToString(h.Sum(nil)),
+		StorageKey: key,
+	}, nil
+}
+
+// DiscardStagedAttachments 登记失败时删除已写入的文件
+// 删除失败只记录日志，残留文件没有记录引用，由 gc 命令清理
+func DiscardStagedAttachments(staged []StagedAttachment) {
+	for _, s := range staged {
+		if err := utils.Store.Delete(context.Background(), s.StorageKey); err != nil {
+			utils.Logger.Error(fmt.Sprintf("删除未登记的附件文件 %s 失败: %v", s.StorageKey, err))
+		}
+	}
+}
+
+// registerAttachments 在事务中登记已写入存储的附件
+// 同名附件的版本号加1，旧版本不再是最新版本；(资产, 文件名, 版本) 唯一，并发上传同名文件时后提交的失败
+func registerAttachments(tx *gorm.DB, assetType string, assetID int, uploaderID int, staged []StagedAttachment) ([]Attachment, error) {
+	attachments := make([]Attachment, 0, len(staged))
+	for _, s := range staged {
+		var latest struct {
+			Version int
+		}
+		if err := tx.Model(&Attachment{}).Select("COALESCE(MAX(version), 0) AS version").
+			Where("owner_type = ? AND owner_id = ? AND name = ?", assetType, assetID, s.Name).
+			Scan(&latest).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&Attachment{}).
+			Where("owner_type = ? AND owner_id = ? AND name = ? AND is_current = ?", assetType, assetID, s.Name, true).
+			Update("is_current", false).Error; err != nil {
+			return nil, err
+		}
+		attachment := Attachment{
+			OwnerType:  assetType,
+			OwnerID:    assetID,
+			Name:       s.Name,
+			Version:    latest.Version + 1,
+			IsCurrent:  true,
+			StorageKey: s.StorageKey,
+			Size:       s.Size,
+			MimeType:   s.MimeType,
+			SHA256:     s.SHA256,
+			UploaderID: uploaderID,
+		}
+		if err := tx.Create(&attachment).Error; err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// AddAttachment 校验并上传附件，已有同名附件时作为新版本保存
+func AddAttachment(assetType string, assetID int, uploaderID int, fh *multipart.FileHeader) (*Attachment, error) {
+	checked, err := utils.ValidateUpload(context.Background(), assetType, fh)
+	if err != nil {
+		return nil, err
+	}
+	return saveAttachmentVersion(assetType, assetID, uploaderID, checked, utils.FileHeaderOpener(fh))
+}
+
+// ReplaceAttachment 替换附件，新文件作为该附件的新版本保存，文件名沿用原附件
+func ReplaceAttachment(assetType string, assetID int, attachmentID int, uploaderID int, fh *multipart.FileHeader) (*Attachment, error) {
+	current, err := GetAttachment(assetType, assetID, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !current.IsCurrent {
+		return nil, ErrAttachmentNotCurrent
+	}
+	checked, err := utils.ValidateUpload(context.Background(), assetType, fh)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(path.Ext(checked.Name), path.Ext(current.Name)) {
+		return nil, ErrAttachmentExtension
+	}
+	checked.Name = current.Name
+	return saveAttachmentVersion(assetType, assetID, uploaderID, checked, utils.FileHeaderOpener(fh))
+}
+
+// saveAttachmentVersion 写入文件并登记为附件的新版本，登记失败时删除已写入的文件
+func saveAttachmentVersion(assetType string, assetID int, uploaderID int, checked utils.CheckedUpload, open utils.OpenFunc) (*Attachment, error) {
+	applicationNumber, err := getAssetApplicationNumber(AttachmentDB, assetType, assetID)
+	if err != nil {
+		return nil, err
+	}
+	staged, err := stageAttachment(assetType, applicationNumber, checked, open)
+	if err != nil {
+		return nil, err
+	}
+	var attachments []Attachment
+	err = AttachmentDB.Transaction(func(tx *gorm.DB) error {
+		attachments, err = registerAttachments(tx, assetType, assetID, uploaderID, []StagedAttachment{staged})
+		return err
+	})
+	if err != nil {
+		DiscardStagedAttachments([]StagedAttachment{staged})
+		return nil, err
+	}
+	return &attachments[0], nil
+}
+
+// GetAttachment 查询资产下的某个附件(任意版本)
+func GetAttachment(assetType string, assetID int, attachmentID int) (*Attachment, error) {
+	var attachment Attachment
+	err := AttachmentDB.Where("id = ? AND owner_type = ? AND owner_id = ?", attachmentID, assetType, assetID).
+		Take(&attachment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// ListAttachments 查询资产的附件，allVersions 为 false 时只返回最新版本
+// 资产还没有附件记录时先登记迁移前直接保存在申请号目录下的文件
+func ListAttachments(assetType string, assetID int, allVersions bool) ([]Attachment, error) {
+	var count int64
+	if err := AttachmentDB.Model(&Attachment{}).Where("owner_type = ? AND owner_id = ?", assetType, assetID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		if err := importLegacyAttachments(assetType, assetID); err != nil {
+			return nil, err
+		}
+	}
+
+	attachments := []Attachment{}
+	query := AttachmentDB.Where("owner_type = ? AND owner_id = ?", assetType, assetID)
+	if !allVersions {
+		query = query.Where("is_current = ?", true)
+	}
+	if err := query.Order("name, version DESC").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	for i := range attachments {
+		attachments[i].DownloadURL = AttachmentDownloadPath(&attachments[i])
+	}
+	return attachments, nil
+}
+
+// AttachmentDownloadPath 附件下载接口地址
+func AttachmentDownloadPath(a *Attachment) string {
+	return "/" + a.OwnerType + "/" + strconv.Itoa(a.OwnerID) + "/attachments/" + strconv.Itoa(a.ID) + "/download"
+}
+
+// importLegacyAttachments 登记迁移前的附件
+// 旧文件直接保存在 资产类型/申请号/ 下，没有上传人，校验和在登记时计算
+func importLegacyAttachments(assetType string, assetID int) error {
+	applicationNumber, err := getAssetApplicationNumber(AttachmentDB, assetType, assetID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil || applicationNumber == "" {
+		return err
+	}
+	ctx := context.Background()
+	prefix := AssetFilePrefix(assetType, applicationNumber)
+	objects, err := utils.Store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		name := strings.TrimPrefix(obj.Key, prefix)
+		// 子目录(缴费收据、新版本附件)不是旧附件
+		if strings.Contains(name, "/") {
+			continue
+		}
+		sum, err := storageSHA256(ctx, obj.Key)
+		if err != nil {
+			return err
+		}
+		attachment := Attachment{
+			OwnerType:  assetType,
+			OwnerID:    assetID,
+			Name:       name,
+			Version:    1,
+			IsCurrent:  true,
+			StorageKey: obj.Key,
+			Size:       obj.Size,
+			MimeType:   mime.TypeByExtension(path.Ext(name)),
+			SHA256:     sum,
+			CreatedAt:  obj.ModTime,
+		}
+		if attachment.MimeType == "" {
+			attachment.MimeType = "application/octet-stream"
+		}
+		// 并发查询时可能重复登记，以唯一索引去重
+		if err := AttachmentDB.Clauses(clause.OnConflict{DoNothing: true}).Create(&attachment).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storageSHA256 计算存储中文件的 SHA-256
+func storageSHA256(ctx context.Context, key string) (string, error) {
+	rc, err := utils.Store.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// OpenAttachment 读取附件内容，调用方负责关闭
+func OpenAttachment(a *Attachment) (io.ReadCloser, error) {
+	return utils.Store.Get(context.Background(), a.StorageKey)
+}
+
+// DeleteAttachment 删除附件及其全部历史版本
+// 删除记录的同一事务中登记文件删除任务，提交后立即执行，失败的由定时任务重试
+func DeleteAttachment(assetType string, assetID int, attachmentID int) error {
+	attachment, err := GetAttachment(assetType, assetID, attachmentID)
+	if err != nil {
+		return err
+	}
+	var tasks []*StorageTask
+	err = AttachmentDB.Transaction(func(tx *gorm.DB) error {
+		var versions []Attachment
+		if err := tx.Where("owner_type = ? AND owner_id = ? AND name = ?", assetType, assetID, attachment.Name).
+			Find(&versions).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("owner_type = ? AND owner_id = ? AND name = ?", assetType, assetID, attachment.Name).
+			Delete(&Attachment{}).Error; err != nil {
+			return err
+		}
+		for _, v := range versions {
+			task, err := enqueueStorageTask(tx, StorageTaskDelete, v.StorageKey)
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	runStorageTasksNow(tasks)
+	return nil
+}
+
+// deleteAssetFiles 删除资产时在事务中删除全部附件记录，并登记删除申请号目录的任务
+// 附件和收据 PDF 都在申请号目录下，目录整体删除；收据记录保留，PDF 需要时重新生成
+func deleteAssetFiles(tx *gorm.DB, assetType string, assetID int) (*StorageTask, error) {
+	applicationNumber, err := getAssetApplicationNumber(tx, assetType, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("owner_type = ? AND owner_id = ?", assetType, assetID).Delete(&Attachment{}).Error; err != nil {
+		return nil, err
+	}
+	// 没有申请号时前缀会变成整个资产类型目录，不能删除
+	if applicationNumber == "" {
+		return nil, nil
+	}
+	return enqueueStorageTask(tx, StorageTaskDeletePrefix, AssetFilePrefix(assetType, applicationNumber))
+}
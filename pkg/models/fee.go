@@ -0,0 +1,203 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 费用状态
+const (
+	FeeStatusPending  = 0 // 待缴费
+	FeeStatusPaid     = 1 // 已缴费
+	FeeStatusOverdue  = 2 // 已逾期
+	FeeStatusWaived   = 3 // 已免除
+	FeeStatusRefunded = 4 // 已全额退款
+	FeeStatusLapsed   = 5 // 宽限期满未缴，资产失效后作废
+)
+
+// 缴费相关错误
+var (
+	ErrFeeAlreadyPaid    = errors.New("费用已支付")
+	ErrFeeAmountMismatch = errors.New("支付金额与费用金额不一致")
+	ErrFeeWaived         = errors.New("费用已免除")
+	ErrFeeLapsed         = errors.New("宽限期已满，资产已失效，费用无法缴纳")
+)
+
+// feeMeta 不同资产类型的费用表结构
+type feeMeta struct {
+	Table    string // 费用表名
+	AssetKey string // 费用表中指向资产的字段
+	Subject  string // 支付时显示的订单标题
+}
+
+// feeMetas 资产类型与费用表的映射
+var feeMetas = map[string]feeMeta{
+	AssetTypePatent:    {Table: "patent_fees", AssetKey: "patent_id", Subject: "专利年费"},
+	AssetTypeArticle:   {Table: "article_fees", AssetKey: "article_id", Subject: "著作年费"},
+	AssetTypeTrademark: {Table: "trademark_fees", AssetKey: "trademark_id", Subject: "商标年费"},
+}
+
+// FeeInfo 支付需要的费用信息
+type FeeInfo struct {
+	ID        int
+	AssetType string
+	AssetID   int
+	Amount    float64 // 应缴金额，含滞纳金
+	IsPaid    bool
+	Status    int
+	Subject   string
+}
+
+// getFeeMeta 根据资产类型获取费用表结构信息
+func getFeeMeta(assetType string) (feeMeta, error) {
+	meta, ok := feeMetas[assetType]
+	if !ok {
+		return feeMeta{}, errors.New("未知的资产类型：" + assetType)
+	}
+	return meta, nil
+}
+
+// GetFeeInfo 查询费用信息
+func GetFeeInfo(assetType string, feeID int) (FeeInfo, error) {
+	meta, err := getFeeMeta(assetType)
+	if err != nil {
+		return FeeInfo{}, err
+	}
+	var fee struct {
+		ID        int
+		AssetID   int
+		ReviewFee float64
+		Surcharge float64
+		IsPaid    bool
+		Status    int
+	}
+	if err := ApprovalDB.Table(meta.Table).
+		Select("id", meta.AssetKey+" AS asset_id", "review_fee", "surcharge", "is_paid", "status").
+		Where("id = ?", feeID).
+		Take(&fee).Error; err != nil {
+		return FeeInfo{}, err
+	}
+	return FeeInfo{
+		ID:        fee.ID,
+		AssetType: assetType,
+		AssetID:   fee.AssetID,
+		Amount:    float64(toCents(fee.ReviewFee)+toCents(fee.Surcharge)) / 100,
+		IsPaid:    fee.IsPaid,
+		Status:    fee.Status,
+		Subject:   meta.Subject,
+	}, nil
+}
+
+// NewOutTradeNo 生成商户订单号：资产类型_费用ID_时间戳
+// 同一笔费用每次发起支付都生成新的订单号，支付宝要求订单号不能重复使用
+func NewOutTradeNo(assetType string, feeID int) string {
+	return fmt.Sprintf("%s_%d_%d", assetType, feeID, time.Now().UnixMilli())
+}
+
+// FormatFeeAmount 金额格式化为两位小数，与支付宝的 total_amount 格式一致
+func FormatFeeAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// toCents 金额转换为分，避免浮点数直接比较
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// lockedFee 事务中锁定的费用记录
+type lockedFee struct {
+	AssetID         int
+	ReviewFee       float64
+	Surcharge       float64
+	IsPaid          bool
+	Status          int
+	FeeKind         string
+	PaymentDeadline time.Time
+}
+
+// dueCents 应缴金额(分)，费用金额加滞纳金
+func (f lockedFee) dueCents() int64 {
+	return toCents(f.ReviewFee) + toCents(f.Surcharge)
+}
+
+// lockFee 在事务中锁定费用记录，避免并发支付、退款、减免互相覆盖
+func lockFee(tx *gorm.DB, assetType string, feeID int) (lockedFee, feeMeta, error) {
+	meta, err := getFeeMeta(assetType)
+	if err != nil {
+		return lockedFee{}, feeMeta{}, err
+	}
+	var fee lockedFee
+	if err := tx.Table(meta.Table).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select(meta.AssetKey+" AS asset_id", "review_fee", "surcharge", "is_paid", "status", "fee_kind", "payment_deadline").
+		Where("id = ?", feeID).
+		Take(&fee).Error; err != nil {
+		return lockedFee{}, feeMeta{}, err
+	}
+	return fee, meta, nil
+}
+
+// recordFeeEvent 在资产的审批时间线中记录费用事件
+// actorID 为 0 时记为资产的第一作者
+func recordFeeEvent(tx *gorm.DB, assetType string, assetID int, eventType string, actorID int, detail string) error {
+	asset, err := getAssetMeta(assetType)
+	if err != nil {
+		return err
+	}
+	var state struct {
+		Revision      int
+		FirstAuthorID int
+	}
+	if err := tx.Table(asset.Table).Select("revision", "first_author_id").Where("id = ?", assetID).Take(&state).Error; err != nil {
+		return err
+	}
+	if actorID == 0 {
+		actorID = state.FirstAuthorID
+	}
+	return RecordApprovalEvent(tx, &ApprovalEvent{
+		AssetType: assetType,
+		AssetID:   assetID,
+		EventType: eventType,
+		Revision:  state.Revision,
+		ActorID:   actorID,
+		Detail:    detail,
+	})
+}
+
+// markFeePaid 将费用标记为已支付，并记录缴费事件
+// 费用已被其他订单支付时返回 alreadyPaid=true，不重复记录
+// payerID 为发起支付的用户，未知时记为资产的第一作者
+func markFeePaid(tx *gorm.DB, assetType string, feeID int, paidAmount float64, tradeNo string, paidAt time.Time, payerID int) (alreadyPaid bool, err error) {
+	fee, meta, err := lockFee(tx, assetType, feeID)
+	if err != nil {
+		return false, err
+	}
+	if fee.IsPaid {
+		return true, nil
+	}
+	if fee.Status == FeeStatusWaived {
+		return false, ErrFeeWaived
+	}
+	if fee.Status == FeeStatusLapsed {
+		return false, ErrFeeLapsed
+	}
+	if fee.dueCents() != toCents(paidAmount) {
+		return false, ErrFeeAmountMismatch
+	}
+
+	if err := tx.Table(meta.Table).Where("id = ?", feeID).Updates(map[string]interface{}{
+		"is_paid":      true,
+		"payment_date": paidAt,
+		"status":       FeeStatusPaid,
+	}).Error; err != nil {
+		return false, err
+	}
+	return false, recordFeeEvent(tx, assetType, fee.AssetID, ApprovalEventFeePaid, payerID,
+		fmt.Sprintf("费用ID:%d 金额:%.2f 交易号:%s", feeID, paidAmount, tradeNo))
+}
@@ -0,0 +1,414 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 费用调整类型
+const (
+	FeeAdjustRefund    = "refund"    // 退款，针对已支付的费用
+	FeeAdjustWaiver    = "waiver"    // 免除，针对未支付的费用
+	FeeAdjustReduction = "reduction" // 减免部分金额，针对未支付的费用
+)
+
+// 费用调整状态
+// 退款需要财务审批后才向支付渠道发起；免除和减免由财务直接操作，创建即生效
+const (
+	FeeAdjustPending   = "pending"   // 待审批
+	FeeAdjustRefunding = "refunding" // 已审批，正在向支付渠道退款，渠道调用失败时可重新审批重试
+	FeeAdjustApproved  = "approved"  // 已生效
+	FeeAdjustRejected  = "rejected"  // 已驳回
+)
+
+// 费用调整相关错误
+var (
+	ErrFeeNotPaid            = errors.New("费用未支付，不能退款")
+	ErrFeeAdjustAmount       = errors.New("调整金额无效")
+	ErrRefundExceeds         = errors.New("退款金额超过可退金额")
+	ErrFeeAdjustNotFound     = errors.New("费用调整记录不存在")
+	ErrFeeAdjustState        = errors.New("费用调整已处理")
+	ErrRefundOrderNotFound   = errors.New("没有可退款的支付订单")
+	ErrFeeAdjustInvalidKind  = errors.New("无效的费用调整类型")
+	ErrFeeAdjustReasonNeeded = errors.New("调整原因不能为空")
+)
+
+// FeeAdjustment 费用调整记录：退款、免除、减免
+type FeeAdjustment struct {
+	ID          int        `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	AssetType   string     `json:"asset_type" gorm:"type:varchar(20);index:idx_adjust_fee;comment:资产类型(patent/article/trademark)"`
+	FeeID       int        `json:"fee_id" gorm:"type:bigint;index:idx_adjust_fee;comment:费用ID"`
+	Kind        string     `json:"kind" gorm:"type:varchar(20);comment:调整类型(refund/waiver/reduction)"`
+	Amount      float64    `json:"amount" gorm:"type:decimal(10,2);comment:调整金额"`
+	Reason      string     `json:"reason" gorm:"type:varchar(255);comment:调整原因"`
+	State       string     `json:"state" gorm:"type:varchar(20);index;comment:状态(pending/refunding/approved/rejected)"`
+	ApplicantID int        `json:"applicant_id" gorm:"type:bigint;comment:申请人ID"`
+	ApproverID  int        `json:"approver_id" gorm:"type:bigint;comment:审批人ID"`
+	Comment     string     `json:"comment" gorm:"type:varchar(255);comment:审批意见"`
+	OrderNo     string     `json:"order_no" gorm:"type:varchar(64);comment:退款对应的支付订单号"`
+	RefundNo    string     `json:"refund_no" gorm:"type:varchar(64);comment:退款请求号"`
+	ApprovedAt  *time.Time `json:"approved_at" gorm:"comment:审批时间"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// FeeAdjustDB 全局数据库连接实例
+var FeeAdjustDB *gorm.DB = utils.DB
+
+// pendingRefunds 订单上待审批和退款中的金额合计，已完成的退款记在订单的 refunded_amount 中
+func pendingRefunds(tx *gorm.DB, orderNo string) (float64, error) {
+	var sum float64
+	err := tx.Model(&FeeAdjustment{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("order_no = ? AND kind = ? AND state IN ?", orderNo, FeeAdjustRefund,
+			[]string{FeeAdjustPending, FeeAdjustRefunding}).
+		Scan(&sum).Error
+	return sum, err
+}
+
+// ResolveRefundAmount 计算本次退款金额，amount 为 0 时退还订单剩余的全部金额
+// 可退金额为订单金额减去已退款和审批中的金额
+func ResolveRefundAmount(order PaymentOrder, pending float64, amount float64) (float64, error) {
+	remaining := toCents(order.Amount) - toCents(order.RefundedAmount) - toCents(pending)
+	if amount == 0 {
+		amount = float64(remaining) / 100
+	}
+	if toCents(amount) <= 0 || toCents(amount) > remaining {
+		return 0, ErrRefundExceeds
+	}
+	return amount, nil
+}
+
+// RefundOutcome 退款完成后订单和费用的变化
+type RefundOutcome struct {
+	RefundedAmount float64 // 订单累计退款金额
+	OrderState     string  // 订单状态，全额退款后为已退款
+	FeeRefunded    bool    // 费用改为已全额退款，只有计入费用的订单全额退款时才改变费用状态
+}
+
+// PlanRefund 计算订单退款 amount 后的状态
+// 重复支付等需要退款的订单没有计入费用，退款不影响费用状态
+func PlanRefund(order PaymentOrder, amount float64) (RefundOutcome, error) {
+	if toCents(amount) <= 0 {
+		return RefundOutcome{}, ErrFeeAdjustAmount
+	}
+	refunded := toCents(order.RefundedAmount) + toCents(amount)
+	if refunded > toCents(order.Amount) {
+		return RefundOutcome{}, ErrRefundExceeds
+	}
+	outcome := RefundOutcome{RefundedAmount: float64(refunded) / 100, OrderState: order.State}
+	if refunded == toCents(order.Amount) {
+		outcome.OrderState = PaymentStateRefunded
+		outcome.FeeRefunded = !order.NeedsRefund()
+	}
+	return outcome, nil
+}
+
+// lockRefundOrder 在事务中锁定要退款的已支付订单
+// orderNo 为空时取计入费用的订单，重复支付等需要退款的订单须指定订单号
+func lockRefundOrder(tx *gorm.DB, assetType string, feeID int, orderNo string) (*PaymentOrder, error) {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("asset_type = ? AND fee_id = ? AND state = ?", assetType, feeID, PaymentStatePaid)
+	if orderNo != "" {
+		query = query.Where("order_no = ?", orderNo)
+	} else {
+		query = query.Where("remark = ''")
+	}
+	var order PaymentOrder
+	if err := query.Order("paid_at DESC").Take(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefundOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CreateRefundRequest 申请退款，amount 为 0 时退还订单剩余全部金额
+// orderNo 为空时退还计入费用的订单；重复支付、关闭后支付的订单需指定订单号
+func CreateRefundRequest(assetType string, feeID int, orderNo string, amount float64, reason string, applicantID int) (*FeeAdjustment, error) {
+	if reason == "" {
+		return nil, ErrFeeAdjustReasonNeeded
+	}
+	if amount < 0 {
+		return nil, ErrFeeAdjustAmount
+	}
+	adj := &FeeAdjustment{
+		AssetType:   assetType,
+		FeeID:       feeID,
+		Kind:        FeeAdjustRefund,
+		Reason:      reason,
+		State:       FeeAdjustPending,
+		ApplicantID: applicantID,
+	}
+	err := FeeAdjustDB.Transaction(func(tx *gorm.DB) error {
+		fee, _, err := lockFee(tx, assetType, feeID)
+		if err != nil {
+			return err
+		}
+		if orderNo == "" && !fee.IsPaid {
+			return ErrFeeNotPaid
+		}
+		order, err := lockRefundOrder(tx, assetType, feeID, orderNo)
+		if err != nil {
+			return err
+		}
+		pending, err := pendingRefunds(tx, order.OrderNo)
+		if err != nil {
+			return err
+		}
+		if adj.Amount, err = ResolveRefundAmount(*order, pending, amount); err != nil {
+			return err
+		}
+		adj.OrderNo = order.OrderNo
+		return tx.Create(adj).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return adj, nil
+}
+
+// lockFeeAdjustment 在事务中锁定费用调整，状态不在 states 中时返回 ErrFeeAdjustState
+func lockFeeAdjustment(tx *gorm.DB, id int, states ...string) (*FeeAdjustment, error) {
+	var adj FeeAdjustment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Take(&adj).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFeeAdjustNotFound
+		}
+		return nil, err
+	}
+	for _, state := range states {
+		if adj.State == state {
+			return &adj, nil
+		}
+	}
+	return nil, ErrFeeAdjustState
+}
+
+// ApproveRefund 审批通过退款申请，向支付渠道发起退款并更新订单和费用
+// 分三步：先在事务中把申请标记为退款中，再在事务外调用支付渠道，成功后在新事务中更新订单和费用。
+// 退款请求号固定为调整记录ID，渠道调用失败或结果未知时申请保持退款中，重新审批会用同一请求号安全重试
+func ApproveRefund(ctx context.Context, id int, approverID int, comment string, refunders func(channel string) (utils.TradeRefunder, error)) (*FeeAdjustment, error) {
+	var (
+		adj   *FeeAdjustment
+		order *PaymentOrder
+	)
+	err := FeeAdjustDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		adj, err = lockFeeAdjustment(tx, id, FeeAdjustPending, FeeAdjustRefunding)
+		if err != nil {
+			return err
+		}
+		if adj.Kind != FeeAdjustRefund {
+			return ErrFeeAdjustInvalidKind
+		}
+		if order, err = lockRefundOrder(tx, adj.AssetType, adj.FeeID, adj.OrderNo); err != nil {
+			return err
+		}
+		if _, err := PlanRefund(*order, adj.Amount); err != nil {
+			return err
+		}
+		if adj.State == FeeAdjustRefunding {
+			return nil
+		}
+		adj.State = FeeAdjustRefunding
+		adj.ApproverID = approverID
+		adj.Comment = comment
+		adj.OrderNo = order.OrderNo
+		adj.RefundNo = fmt.Sprintf("refund_%d", adj.ID)
+		return tx.Save(adj).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 调用支付渠道时不持有数据库锁
+	refunder, err := refunders(order.Channel)
+	if err != nil {
+		return nil, err
+	}
+	if err := refunder.RefundTrade(ctx, order.OrderNo, adj.RefundNo, adj.Amount, order.Amount, adj.Reason); err != nil {
+		return nil, err
+	}
+
+	err = FeeAdjustDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		adj, err = lockFeeAdjustment(tx, id, FeeAdjustRefunding)
+		if err != nil {
+			return err
+		}
+		fee, meta, err := lockFee(tx, adj.AssetType, adj.FeeID)
+		if err != nil {
+			return err
+		}
+		order, err := lockPaymentOrder(tx, adj.OrderNo)
+		if err != nil {
+			return err
+		}
+		outcome, err := PlanRefund(*order, adj.Amount)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(order).Updates(map[string]interface{}{
+			"refunded_amount": outcome.RefundedAmount,
+			"state":           outcome.OrderState,
+		}).Error; err != nil {
+			return err
+		}
+		if outcome.FeeRefunded {
+			if err := tx.Table(meta.Table).Where("id = ?", adj.FeeID).Updates(map[string]interface{}{
+				"is_paid": false,
+				"status":  FeeStatusRefunded,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		adj.State = FeeAdjustApproved
+		adj.ApprovedAt = &now
+		if err := tx.Save(adj).Error; err != nil {
+			return err
+		}
+		return recordFeeEvent(tx, adj.AssetType, fee.AssetID, ApprovalEventFeeRefunded, adj.ApproverID,
+			fmt.Sprintf("费用ID:%d 订单号:%s 退款金额:%.2f 原因:%s", adj.FeeID, adj.OrderNo, adj.Amount, adj.Reason))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return adj, nil
+}
+
+// RejectFeeAdjustment 驳回待审批的费用调整
+func RejectFeeAdjustment(id int, approverID int, comment string) (*FeeAdjustment, error) {
+	var adj *FeeAdjustment
+	err := FeeAdjustDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		adj, err = lockFeeAdjustment(tx, id, FeeAdjustPending)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		adj.State = FeeAdjustRejected
+		adj.ApproverID = approverID
+		adj.Comment = comment
+		adj.ApprovedAt = &now
+		return tx.Save(adj).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return adj, nil
+}
+
+// PlanWaiver 计算免除或减免的金额，返回调整金额和调整后的评审费
+// 免除为应缴全额（含滞纳金），评审费不变；减免只扣减评审费，且不能减到0
+func PlanWaiver(kind string, reviewFee, surcharge, amount float64) (float64, float64, error) {
+	switch kind {
+	case FeeAdjustWaiver:
+		return float64(toCents(reviewFee)+toCents(surcharge)) / 100, reviewFee, nil
+	case FeeAdjustReduction:
+		if toCents(amount) <= 0 || toCents(amount) >= toCents(reviewFee) {
+			return 0, 0, ErrFeeAdjustAmount
+		}
+		return amount, float64(toCents(reviewFee)-toCents(amount)) / 100, nil
+	}
+	return 0, 0, ErrFeeAdjustInvalidKind
+}
+
+// WaiveFee 免除或减免未支付的费用，由财务直接操作，记录原因和审批人
+// 免除时 amount 忽略，按费用全额记录；减免时 amount 必须小于费用金额
+// 费用金额变化后，之前创建的待支付订单全部关闭，避免按旧金额支付
+func WaiveFee(assetType string, feeID int, kind string, amount float64, reason string, approverID int) (*FeeAdjustment, error) {
+	if kind != FeeAdjustWaiver && kind != FeeAdjustReduction {
+		return nil, ErrFeeAdjustInvalidKind
+	}
+	if reason == "" {
+		return nil, ErrFeeAdjustReasonNeeded
+	}
+	now := time.Now()
+	adj := &FeeAdjustment{
+		AssetType:   assetType,
+		FeeID:       feeID,
+		Kind:        kind,
+		Reason:      reason,
+		State:       FeeAdjustApproved,
+		ApplicantID: approverID,
+		ApproverID:  approverID,
+		ApprovedAt:  &now,
+	}
+	var closing []*PaymentCloseTask
+	err := FeeAdjustDB.Transaction(func(tx *gorm.DB) error {
+		fee, meta, err := lockFee(tx, assetType, feeID)
+		if err != nil {
+			return err
+		}
+		if fee.IsPaid {
+			return ErrFeeAlreadyPaid
+		}
+		if fee.Status == FeeStatusWaived {
+			return ErrFeeWaived
+		}
+		if fee.Status == FeeStatusLapsed {
+			return ErrFeeLapsed
+		}
+
+		waived, reviewFee, err := PlanWaiver(kind, fee.ReviewFee, fee.Surcharge, amount)
+		if err != nil {
+			return err
+		}
+		adj.Amount = waived
+		updates := map[string]interface{}{"review_fee": reviewFee}
+		if kind == FeeAdjustWaiver {
+			updates = map[string]interface{}{"status": FeeStatusWaived}
+		}
+		if err := tx.Table(meta.Table).Where("id = ?", feeID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if closing, err = closeCreatedPaymentOrders(tx, assetType, []int{feeID}, now); err != nil {
+			return err
+		}
+		if err := tx.Create(adj).Error; err != nil {
+			return err
+		}
+		return recordFeeEvent(tx, assetType, fee.AssetID, ApprovalEventFeeWaived, approverID,
+			fmt.Sprintf("费用ID:%d 类型:%s 金额:%.2f 原因:%s", feeID, kind, adj.Amount, reason))
+	})
+	if err != nil {
+		return nil, err
+	}
+	runPaymentCloseTasksNow(closing)
+	return adj, nil
+}
+
+// GetFeeAdjustments 分页查询费用调整记录，assetType、kind、state 为空时不作为条件
+func GetFeeAdjustments(assetType string, kind string, state string, page int, pageSize int) ([]FeeAdjustment, int64, error) {
+	var (
+		adjustments []FeeAdjustment
+		total       int64
+	)
+	query := FeeAdjustDB.Model(&FeeAdjustment{})
+	if assetType != "" {
+		query = query.Where("asset_type = ?", assetType)
+	}
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&adjustments).Error; err != nil {
+		return nil, 0, err
+	}
+	return adjustments, total, nil
+}
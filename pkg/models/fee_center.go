@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// FeeRecord 费用中心的统一费用记录
+// 专利、著作、商标三张费用表结构相同，查询时合并为同一种记录
+type FeeRecord struct {
+	AssetType         string    `json:"asset_type"`
+	ID                int       `json:"id"`
+	AssetID           int       `json:"asset_id"`
+	AssetTitle        string    `json:"asset_title"`
+	ApplicationNumber string    `json:"application_number"`
+	FirstAuthorID     int       `json:"first_author_id"` // 资产的第一作者，实际付款人见支付订单
+	FirstAuthorName   string    `json:"first_author_name"`
+	DepID             int       `json:"dep_id"` // 第一作者所属单位
+	FeeKind           string    `json:"fee_kind"`
+	Year              int       `json:"year"`
+	Amount            float64   `json:"amount"`    // 费用金额，不含滞纳金
+	Surcharge         float64   `json:"surcharge"` // 滞纳金
+	IsPaid            bool      `json:"is_paid"`
+	Status            int       `json:"status"`
+	PaymentDeadline   time.Time `json:"payment_deadline"`
+	PaymentDate       time.Time `json:"payment_date"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// FeeQuery 费用中心的查询条件，零值表示不作为条件
+type FeeQuery struct {
+	AssetType     string     // 资产类型
+	Status        *int       // 费用状态
+	FeeKind       string     // 费用类型
+	DeadlineFrom  *time.Time // 缴费期限起(含)
+	DeadlineTo    *time.Time // 缴费期限止(不含)
+	FirstAuthorID int        // 资产的第一作者
+	DepID         int        // 第一作者所属单位
+	Keyword       string     // 资产名称或申请号
+	AuthorID      int        // 只查询该用户作为作者的资产的费用，"我的费用"使用
+	Sort          string     // 排序字段
+	Order         string     // asc 或 desc
+	Page          int
+	PageSize      int
+}
+
+// feeSortColumns 允许排序的字段及对应的排序表达式，金额按含滞纳金的应缴总额排序
+var feeSortColumns = map[string]string{
+	"payment_deadline": "payment_deadline",
+	"created_at":       "created_at",
+	"payment_date":     "payment_date",
+	"amount":           "amount + surcharge",
+	"status":           "status",
+}
+
+// ErrFeeQuery 费用中心查询参数无效
+var ErrFeeQuery = errors.New("费用查询参数无效")
+
+// Normalize 校验查询条件并补齐默认值：按缴费期限升序，每页10条，最多100条
+func (q *FeeQuery) Normalize() error {
+	if q.AssetType != "" {
+		if _, err := getFeeMeta(q.AssetType); err != nil {
+			return ErrFeeQuery
+		}
+	}
+	if q.Sort == "" {
+		q.Sort = "payment_deadline"
+	}
+	if _, ok := feeSortColumns[q.Sort]; !ok {
+		return ErrFeeQuery
+	}
+	q.Order = strings.ToLower(q.Order)
+	if q.Order == "" {
+		q.Order = "asc"
+	}
+	if q.Order != "asc" && q.Order != "desc" {
+		return ErrFeeQuery
+	}
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 {
+		q.PageSize = 10
+	}
+	if q.PageSize > 100 {
+		q.PageSize = 100
+	}
+	return nil
+}
+
+// feeUnionSQL 按查询条件生成三张费用表合并后的子查询
+func (q *FeeQuery) feeUnionSQL() (string, []interface{}) {
+	assetTypes := []string{AssetTypePatent, AssetTypeArticle, AssetTypeTrademark}
+	if q.AssetType != "" {
+		assetTypes = []string{q.AssetType}
+	}
+	var (
+		parts []string
+		args  []interface{}
+	)
+	for _, t := range assetTypes {
+		fee, _ := getFeeMeta(t)
+		asset, _ := getAssetMeta(t)
+		conds := []string{"1 = 1"}
+		args = append(args, t)
+		if q.Status != nil {
+			conds = append(conds, "f.status = ?")
+			args = append(args, *q.Status)
+		}
+		if q.FeeKind != "" {
+			conds = append(conds, "f.fee_kind = ?")
+			args = append(args, q.FeeKind)
+		}
+		if q.DeadlineFrom != nil {
+			conds = append(conds, "f.payment_deadline >= ?")
+			args = append(args, *q.DeadlineFrom)
+		}
+		if q.DeadlineTo != nil {
+			conds = append(conds, "f.payment_deadline < ?")
+			args = append(args, *q.DeadlineTo)
+		}
+		if q.FirstAuthorID != 0 {
+			conds = append(conds, "a.first_author_id = ?")
+			args = append(args, q.FirstAuthorID)
+		}
+		if q.DepID != 0 {
+			conds = append(conds, "u.dep_id = ?")
+			args = append(args, q.DepID)
+		}
+		if q.Keyword != "" {
+			conds = append(conds, "(a.title LIKE ? OR a.application_number LIKE ?)")
+			args = append(args, "%"+q.Keyword+"%", "%"+q.Keyword+"%")
+		}
+		if q.AuthorID != 0 {
+			conds = append(conds, "EXISTS (SELECT 1 FROM "+asset.AuthorTable+" au WHERE au."+asset.AuthorKey+" = a.id AND au.user_id = ?)")
+			args = append(args, q.AuthorID)
+		}
+		parts = append(parts, "SELECT ? AS asset_type, f.id, f."+fee.AssetKey+" AS asset_id, "+
+			"a.title AS asset_title, a.application_number, a.first_author_id, "+
+			"u.user_name AS first_author_name, u.dep_id, f.fee_kind, f.year, f.review_fee AS amount, f.surcharge, "+
+			"f.is_paid, f.status, f.payment_deadline, f.payment_date, f.created_at "+
+			"FROM "+fee.Table+" f JOIN "+asset.Table+" a ON a.id = f."+fee.AssetKey+" "+
+			"LEFT JOIN users u ON u.id = a.first_author_id "+
+			"WHERE "+strings.Join(conds, " AND "))
+	}
+	return strings.Join(parts, " UNION ALL "), args
+}
+
+// ListFees 费用中心：跨专利、著作、商标分页查询费用
+func ListFees(q FeeQuery) ([]FeeRecord, int64, error) {
+	if err := q.Normalize(); err != nil {
+		return nil, 0, err
+	}
+	union, args := q.feeUnionSQL()
+
+	var total int64
+	if err := ApprovalDB.Raw("SELECT COUNT(*) FROM ("+union+") AS fees", args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	records := []FeeRecord{}
+	if total == 0 {
+		return records, 0, nil
+	}
+	// 排序字段已在 Normalize 中按白名单校验，同值时按资产类型和ID排序保证分页稳定
+	order := " ORDER BY " + feeSortColumns[q.Sort] + " " + q.Order + ", asset_type, id"
+	pageArgs := append(append([]interface{}{}, args...), q.PageSize, (q.Page-1)*q.PageSize)
+	if err := ApprovalDB.Raw("SELECT * FROM ("+union+") AS fees"+order+" LIMIT ? OFFSET ?", pageArgs...).
+		Scan(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OverdueResult 一次逾期费用处理的结果
+type OverdueResult struct {
+	Overdue    int // 新标记为逾期的费用数
+	Surcharged int // 滞纳金有变化的费用数
+	Lapsed     int // 宽限期满而失效的资产数
+}
+
+// ProcessOverdueFees 处理所有超过缴费期限仍未缴纳的费用
+// 1. 待缴费用超过期限后标记为逾期
+// 2. 授权后的年费和续展费在宽限期内按逾期月数加收滞纳金
+// 3. 宽限期满仍未缴纳时资产失效，该资产其余未缴的授权后费用一并作废
+// 审核费逾期只标记状态，不加收滞纳金也不导致失效
+// 每笔费用在单独的事务中处理，重复执行结果不变
+func ProcessOverdueFees(ctx context.Context, policy utils.FeeOverdue, now time.Time) (OverdueResult, error) {
+	var result OverdueResult
+	for _, assetType := range []string{AssetTypePatent, AssetTypeArticle, AssetTypeTrademark} {
+		meta, err := getFeeMeta(assetType)
+		if err != nil {
+			return result, err
+		}
+		var feeIDs []int
+		if err := ApprovalDB.Table(meta.Table).
+			Where("is_paid = ? AND status IN ? AND payment_deadline < ?", false, []int{FeeStatusPending, FeeStatusOverdue}, now).
+			Order("id").
+			Pluck("id", &feeIDs).Error; err != nil {
+			return result, err
+		}
+		for _, feeID := range feeIDs {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			var closing []*PaymentCloseTask
+			err := ApprovalDB.Transaction(func(tx *gorm.DB) error {
+				return processOverdueFee(tx, assetType, feeID, policy, now, &result, &closing)
+			})
+			if err != nil {
+				return result, err
+			}
+			runPaymentCloseTasksNow(closing)
+		}
+	}
+	return result, nil
+}
+
+// processOverdueFee 在事务中处理一笔逾期费用，关闭的待支付订单对应的关闭渠道交易任务追加到 closing
+func processOverdueFee(tx *gorm.DB, assetType string, feeID int, policy utils.FeeOverdue, now time.Time, result *OverdueResult, closing *[]*PaymentCloseTask) error {
+	fee, meta, err := lockFee(tx, assetType, feeID)
+	if err != nil {
+		return err
+	}
+	if fee.IsPaid || (fee.Status != FeeStatusPending && fee.Status != FeeStatusOverdue) || !fee.PaymentDeadline.Before(now) {
+		return nil
+	}
+	postGrant := fee.FeeKind == FeeKindAnnuity || fee.FeeKind == FeeKindRenewal
+
+	if postGrant && !policy.LapseAt(fee.PaymentDeadline).After(now) {
+		lapsed, err := lapseAsset(tx, assetType, fee.AssetID, feeID, now, closing)
+		if err != nil {
+			return err
+		}
+		if lapsed {
+			result.Lapsed++
+		}
+		return nil
+	}
+
+	updates := map[string]interface{}{}
+	if fee.Status == FeeStatusPending {
+		updates["status"] = FeeStatusOverdue
+	}
+	var surcharge float64
+	if postGrant {
+		surcharge = policy.Surcharge(fee.ReviewFee, fee.PaymentDeadline, now)
+	}
+	surchargeChanged := toCents(surcharge) != toCents(fee.Surcharge)
+	if surchargeChanged {
+		updates["surcharge"] = surcharge
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := tx.Table(meta.Table).Where("id = ?", feeID).Updates(updates).Error; err != nil {
+		return err
+	}
+	if surchargeChanged {
+		result.Surcharged++
+		tasks, err := closeCreatedPaymentOrders(tx, assetType, []int{feeID}, now)
+		if err != nil {
+			return err
+		}
+		*closing = append(*closing, tasks...)
+	}
+	if fee.Status != FeeStatusPending {
+		return nil
+	}
+	result.Overdue++
+	return recordFeeEvent(tx, assetType, fee.AssetID, ApprovalEventFeeOverdue, 0,
+		fmt.Sprintf("费用ID:%d 缴费期限:%s 宽限期至:%s", feeID,
+			fee.PaymentDeadline.Format("2006-01-02"), policy.LapseAt(fee.PaymentDeadline).Format("2006-01-02")))
+}
+
+// lapseAsset 宽限期满未缴费，资产失效，该资产其余未缴的授权后费用一并作废
+// 资产已失效时只作废费用，返回 lapsed=false
+func lapseAsset(tx *gorm.DB, assetType string, assetID int, feeID int, now time.Time, closing *[]*PaymentCloseTask) (lapsed bool, err error) {
+	asset, err := getAssetMeta(assetType)
+	if err != nil {
+		return false, err
+	}
+	meta, err := getFeeMeta(assetType)
+	if err != nil {
+		return false, err
+	}
+	res := tx.Table(asset.Table).Where("id = ? AND lapsed_at IS NULL", assetID).Update("lapsed_at", now)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	lapsed = res.RowsAffected > 0
+
+	var feeIDs []int
+	if err := tx.Table(meta.Table).
+		Where(meta.AssetKey+" = ? AND is_paid = ? AND status IN ? AND fee_kind IN ?", assetID, false,
+			[]int{FeeStatusPending, FeeStatusOverdue}, []string{FeeKindAnnuity, FeeKindRenewal}).
+		Pluck("id", &feeIDs).Error; err != nil {
+		return false, err
+	}
+	if len(feeIDs) > 0 {
+		if err := tx.Table(meta.Table).Where("id IN ?", feeIDs).Update("status", FeeStatusLapsed).Error; err != nil {
+			return false, err
+		}
+		tasks, err := closeCreatedPaymentOrders(tx, assetType, feeIDs, now)
+		if err != nil {
+			return false, err
+		}
+		*closing = append(*closing, tasks...)
+	}
+	if !lapsed {
+		return false, nil
+	}
+	return true, recordFeeEvent(tx, assetType, assetID, ApprovalEventLapsed, 0,
+		fmt.Sprintf("费用ID:%d 宽限期满未缴纳，作废未缴费用 %d 项", feeID, len(feeIDs)))
+}
+
+// RunOverdueFeeJob 定时任务入口，按当前配置处理逾期费用并记录结果
+func RunOverdueFeeJob(ctx context.Context) error {
+	result, err := ProcessOverdueFees(ctx, utils.FeeOverdueConfig, time.Now())
+	if result.Overdue > 0 || result.Surcharged > 0 || result.Lapsed > 0 {
+		utils.Logger.Info(fmt.Sprintf("逾期费用处理: 新逾期 %d 项, 滞纳金变化 %d 项, 失效资产 %d 个",
+			result.Overdue, result.Surcharged, result.Lapsed))
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 费用类型
+const (
+	FeeKindReview  = "review"  // 申请时的审核费
+	FeeKindAnnuity = "annuity" // 专利授权后的年费
+	FeeKindRenewal = "renewal" // 商标续展费
+)
+
+// 授权后首笔费用的缴纳期限：自授权之日起一个月内
+const grantFeeDeadline = 1
+
+// ScheduledFee 费用计划中的一笔费用
+type ScheduledFee struct {
+	Kind     string    // 费用类型
+	Year     int       // 年度或续展次数
+	Amount   float64   // 金额
+	Deadline time.Time // 缴费期限
+}
+
+// BuildAnnuitySchedule 生成专利授权后的年费计划
+// 保护期限和年度自申请日起算；授权当年的年费在授权后一个月内缴纳，
+// 之后每一年度的年费在上一年度期满前缴纳，即申请日的对应日
+func BuildAnnuitySchedule(rule utils.AnnuityRule, applyDate time.Time, grantDate time.Time) []ScheduledFee {
+	startYear := 1
+	for startYear < rule.TermYears && !applyDate.AddDate(startYear, 0, 0).After(grantDate) {
+		startYear++
+	}
+
+	var fees []ScheduledFee
+	for year := startYear; year <= rule.TermYears; year++ {
+		deadline := applyDate.AddDate(year-1, 0, 0)
+		if year == startYear {
+			deadline = grantDate.AddDate(0, grantFeeDeadline, 0)
+		}
+		fees = append(fees, ScheduledFee{
+			Kind:     FeeKindAnnuity,
+			Year:     year,
+			Amount:   rule.AmountForYear(year),
+			Deadline: deadline,
+		})
+	}
+	return fees
+}
+
+// BuildRenewalSchedule 生成商标核准注册后的续展费计划
+// 每个有效期自核准注册日起算，续展费在有效期满前缴纳
+func BuildRenewalSchedule(rule utils.RenewalRule, grantDate time.Time) []ScheduledFee {
+	var fees []ScheduledFee
+	for period := 1; period <= rule.Periods; period++ {
+		fees = append(fees, ScheduledFee{
+			Kind:     FeeKindRenewal,
+			Year:     period,
+			Amount:   rule.Amount,
+			Deadline: grantDate.AddDate(rule.PeriodYears*period, 0, 0),
+		})
+	}
+	return fees
+}
+
+// patentAnnuityRule 按专利类型获取年费规则
+func patentAnnuityRule(patentType int) (utils.AnnuityRule, bool) {
+	switch patentType {
+	case PatentInvention:
+		return utils.FeeScheduleConfig.Invention, true
+	case PracticalInvention:
+		return utils.FeeScheduleConfig.Utility, true
+	case AppearanceDesign:
+		return utils.FeeScheduleConfig.Design, true
+	}
+	return utils.AnnuityRule{}, false
+}
+
+// quoteScheduledFees 按授权时的费用标准和减缴政策重新计算费用计划中的金额
+// fee.yaml 只决定期限和年度，金额以费用标准表为准
+func quoteScheduledFees(tx *gorm.DB, fees []ScheduledFee, assetType string, subType int, discountCode string, at time.Time) error {
+	if len(fees) == 0 {
+		return nil
+	}
+	q, err := newFeeQuoter(tx, assetType, subType, fees[0].Kind, discountCode, at)
+	if err != nil {
+		return err
+	}
+	for i := range fees {
+		if fees[i].Amount, err = q.Amount(fees[i].Year); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateFeeSchedule 资产审批通过后生成授权后的费用计划，需要在审批事务中调用
+// 专利生成保护期限内的年费，商标生成续展费，著作没有后续费用
+// 已经生成过的不会重复生成
+func generateFeeSchedule(tx *gorm.DB, assetType string, assetID int, subType int, actorID int, grantDate time.Time) error {
+	var (
+		fees  []ScheduledFee
+		count int64
+	)
+	switch assetType {
+	case AssetTypePatent:
+		rule, ok := patentAnnuityRule(subType)
+		if !ok {
+			return nil
+		}
+		if err := tx.Model(&PatentFee{}).Where("patent_id = ? AND fee_kind = ?", assetID, FeeKindAnnuity).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		var patent Patent
+		if err := tx.Select("id", "apply_date", "discount_code").Where("id = ?", assetID).Take(&patent).Error; err != nil {
+			return err
+		}
+		fees = BuildAnnuitySchedule(rule, patent.ApplyDate, grantDate)
+		if err := quoteScheduledFees(tx, fees, assetType, subType, patent.DiscountCode, grantDate); err != nil {
+			return err
+		}
+		records := make([]PatentFee, len(fees))
+		for i, f := range fees {
+			records[i] = PatentFee{PatentID: assetID, ReviewFee: f.Amount, CreatedAt: grantDate,
+				PaymentDeadline: f.Deadline, Status: FeeStatusPending, FeeKind: f.Kind, Year: f.Year}
+		}
+		if len(records) > 0 {
+			if err := tx.Omit("Patent").Create(&records).Error; err != nil {
+				return err
+			}
+		}
+	case AssetTypeTrademark:
+		if err := tx.Model(&TrademarkFee{}).Where("trademark_id = ? AND fee_kind = ?", assetID, FeeKindRenewal).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		var trademark Trademark
+		if err := tx.Select("id", "discount_code").Where("id = ?", assetID).Take(&trademark).Error; err != nil {
+			return err
+		}
+		fees = BuildRenewalSchedule(utils.FeeScheduleConfig.Trademark, grantDate)
+		if err := quoteScheduledFees(tx, fees, assetType, subType, trademark.DiscountCode, grantDate); err != nil {
+			return err
+		}
+		records := make([]TrademarkFee, len(fees))
+		for i, f := range fees {
+			records[i] = TrademarkFee{TrademarkID: assetID, ReviewFee: f.Amount, CreatedAt: grantDate,
+				PaymentDeadline: f.Deadline, Status: FeeStatusPending, FeeKind: f.Kind, Year: f.Year}
+		}
+		if len(records) > 0 {
+			if err := tx.Omit("Trademark").Create(&records).Error; err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	if len(fees) == 0 {
+		return nil
+	}
+
+	var total float64
+	for _, f := range fees {
+		total += f.Amount
+	}
+	return recordFeeEvent(tx, assetType, assetID, ApprovalEventFeeCreated, actorID,
+		fmt.Sprintf("授权后费用计划 %d 项 合计:%.2f", len(fees), total))
+}
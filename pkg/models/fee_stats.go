@@ -0,0 +1,255 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 统计粒度
+const (
+	StatsGranularityDay     = "day"
+	StatsGranularityMonth   = "month"
+	StatsGranularityQuarter = "quarter"
+	StatsGranularityYear    = "year"
+)
+
+// 单次统计最多的时间分组数，避免按天统计多年数据
+const maxStatsBuckets = 400
+
+// 费用统计参数错误
+var (
+	ErrStatsPeriod    = errors.New("统计区间参数无效")
+	ErrStatsAssetType = errors.New("未知的资产类型")
+)
+
+// feeStatsDateColumns 可用于统计分组的日期字段
+var feeStatsDateColumns = map[string]string{
+	"deadline": "payment_deadline", // 按缴费期限统计应收
+	"created":  "created_at",       // 按费用生成时间统计
+	"paid":     "payment_date",     // 按支付时间统计实收
+}
+
+// StatsPeriod 统计区间 [From, To) 和分组粒度
+type StatsPeriod struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Granularity string    `json:"granularity"`
+}
+
+// ParseStatsPeriod 解析统计区间
+//   - period=month: value 为 2006-01，默认本月，按天分组
+//   - period=quarter: value 为 2006-Q1，默认本季度，按月分组
+//   - period=year: value 为 2006，默认本年，按月分组
+//   - period=custom: from、to 为 2006-01-02，包含 to 当天，跨度不超过两个月按天分组，否则按月分组
+//
+// granularity 不为空时覆盖默认的分组粒度
+func ParseStatsPeriod(period string, value string, from string, to string, granularity string, now time.Time) (StatsPeriod, error) {
+	var p StatsPeriod
+	loc := now.Location()
+	switch period {
+	case "", "month":
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+		if value != "" {
+			t, err := time.ParseInLocation("2006-01", value, loc)
+			if err != nil {
+				return p, ErrStatsPeriod
+			}
+			start = t
+		}
+		p = StatsPeriod{From: start, To: start.AddDate(0, 1, 0), Granularity: StatsGranularityDay}
+	case "quarter":
+		year, quarter := now.Year(), (int(now.Month())-1)/3+1
+		if value != "" {
+			parts := strings.Split(value, "-Q")
+			if len(parts) != 2 {
+				return p, ErrStatsPeriod
+			}
+			var err1, err2 error
+			year, err1 = strconv.Atoi(parts[0])
+			quarter, err2 = strconv.Atoi(parts[1])
+			if err1 != nil || err2 != nil || quarter < 1 || quarter > 4 {
+				return p, ErrStatsPeriod
+			}
+		}
+		start := time.Date(year, time.Month((quarter-1)*3+1), 1, 0, 0, 0, 0, loc)
+		p = StatsPeriod{From: start, To: start.AddDate(0, 3, 0), Granularity: StatsGranularityMonth}
+	case "year":
+		year := now.Year()
+		if value != "" {
+			y, err := strconv.Atoi(value)
+			if err != nil {
+				return p, ErrStatsPeriod
+			}
+			year = y
+		}
+		start := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+		p = StatsPeriod{From: start, To: start.AddDate(1, 0, 0), Granularity: StatsGranularityMonth}
+	case "custom":
+		start, err1 := time.ParseInLocation("2006-01-02", from, loc)
+		end, err2 := time.ParseInLocation("2006-01-02", to, loc)
+		if err1 != nil || err2 != nil || end.Before(start) {
+			return p, ErrStatsPeriod
+		}
+		p = StatsPeriod{From: start, To: end.AddDate(0, 0, 1), Granularity: StatsGranularityDay}
+		if p.To.After(start.AddDate(0, 2, 0)) {
+			p.Granularity = StatsGranularityMonth
+		}
+	default:
+		return p, ErrStatsPeriod
+	}
+
+	if granularity != "" {
+		switch granularity {
+		case StatsGranularityDay, StatsGranularityMonth, StatsGranularityQuarter, StatsGranularityYear:
+			p.Granularity = granularity
+		default:
+			return p, ErrStatsPeriod
+		}
+	}
+	if len(p.Buckets()) > maxStatsBuckets {
+		return p, ErrStatsPeriod
+	}
+	return p, nil
+}
+
+// bucketStart 时间 t 所在分组的起始时间
+func (p StatsPeriod) bucketStart(t time.Time) time.Time {
+	switch p.Granularity {
+	case StatsGranularityDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	case StatsGranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case StatsGranularityQuarter:
+		return time.Date(t.Year(), time.Month((int(t.Month())-1)/3*3+1), 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+}
+
+// bucketLabel 分组名称，与 SQL 中的分组表达式结果一致
+func (p StatsPeriod) bucketLabel(t time.Time) string {
+	switch p.Granularity {
+	case StatsGranularityDay:
+		return t.Format("2006-01-02")
+	case StatsGranularityMonth:
+		return t.Format("2006-01")
+	case StatsGranularityQuarter:
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+	}
+	return t.Format("2006")
+}
+
+// nextBucket 下一个分组的起始时间
+func (p StatsPeriod) nextBucket(t time.Time) time.Time {
+	switch p.Granularity {
+	case StatsGranularityDay:
+		return t.AddDate(0, 0, 1)
+	case StatsGranularityMonth:
+		return t.AddDate(0, 1, 0)
+	case StatsGranularityQuarter:
+		return t.AddDate(0, 3, 0)
+	}
+	return t.AddDate(1, 0, 0)
+}
+
+// Buckets 区间内按粒度划分的全部分组名称，前端据此补齐没有数据的分组
+func (p StatsPeriod) Buckets() []string {
+	var buckets []string
+	for t := p.bucketStart(p.From); t.Before(p.To); t = p.nextBucket(t) {
+		buckets = append(buckets, p.bucketLabel(t))
+		if len(buckets) > maxStatsBuckets {
+			break
+		}
+	}
+	return buckets
+}
+
+// bucketExpr 按粒度生成 MySQL 分组表达式
+func (p StatsPeriod) bucketExpr(column string) string {
+	switch p.Granularity {
+	case StatsGranularityDay:
+		return "DATE_FORMAT(" + column + ", '%Y-%m-%d')"
+	case StatsGranularityMonth:
+		return "DATE_FORMAT(" + column + ", '%Y-%m')"
+	case StatsGranularityQuarter:
+		return "CONCAT(YEAR(" + column + "), '-Q', QUARTER(" + column + "))"
+	}
+	return "DATE_FORMAT(" + column + ", '%Y')"
+}
+
+// FeeStatsRow 某个分组内某类资产某个状态的费用汇总
+type FeeStatsRow struct {
+	Bucket    string  `json:"bucket"`
+	AssetType string  `json:"asset_type"`
+	Status    int     `json:"status"`
+	Count     int     `json:"count"`
+	Amount    float64 `json:"amount"`    // 应缴金额，含滞纳金
+	Surcharge float64 `json:"surcharge"` // 其中的滞纳金
+}
+
+// FeeAdjustStatsRow 区间内审批通过的费用调整汇总
+type FeeAdjustStatsRow struct {
+	AssetType string  `json:"asset_type"`
+	Kind      string  `json:"kind"`
+	Count     int     `json:"count"`
+	Amount    float64 `json:"amount"`
+}
+
+// FeeStatsReport 费用统计结果
+type FeeStatsReport struct {
+	Period      StatsPeriod         `json:"period"`
+	DateField   string              `json:"date_field"`
+	Buckets     []string            `json:"buckets"`
+	Rows        []FeeStatsRow       `json:"rows"`
+	Adjustments []FeeAdjustStatsRow `json:"adjustments"`
+}
+
+// GetFeeStats 按区间和粒度统计费用，按资产类型和费用状态分组
+// dateField 为 deadline、created、paid 之一，决定费用按哪个日期归入区间；assetType 为空时统计全部资产
+// 三张费用表用 UNION ALL 合并后在数据库中汇总，不再把全部费用读入内存
+func GetFeeStats(period StatsPeriod, dateField string, assetType string) (*FeeStatsReport, error) {
+	if dateField == "" {
+		dateField = "deadline"
+	}
+	column, ok := feeStatsDateColumns[dateField]
+	if !ok {
+		return nil, ErrStatsPeriod
+	}
+	assetTypes := []string{AssetTypePatent, AssetTypeArticle, AssetTypeTrademark}
+	if assetType != "" {
+		if _, err := getFeeMeta(assetType); err != nil {
+			return nil, ErrStatsAssetType
+		}
+		assetTypes = []string{assetType}
+	}
+
+	var (
+		parts []string
+		args  []interface{}
+	)
+	for _, t := range assetTypes {
+		meta, _ := getFeeMeta(t)
+		parts = append(parts, "SELECT ? AS asset_type, "+period.bucketExpr(column)+" AS bucket, status, "+
+			"COUNT(*) AS count, COALESCE(SUM(review_fee + surcharge), 0) AS amount, COALESCE(SUM(surcharge), 0) AS surcharge "+
+			"FROM "+meta.Table+" WHERE "+column+" >= ? AND "+column+" < ? GROUP BY bucket, status")
+		args = append(args, t, period.From, period.To)
+	}
+	report := &FeeStatsReport{Period: period, DateField: dateField, Buckets: period.Buckets()}
+	if err := ApprovalDB.Raw("SELECT * FROM ("+strings.Join(parts, " UNION ALL ")+") AS s ORDER BY bucket, asset_type, status", args...).
+		Scan(&report.Rows).Error; err != nil {
+		return nil, err
+	}
+
+	query := FeeAdjustDB.Model(&FeeAdjustment{}).
+		Select("asset_type, kind, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Where("state = ? AND approved_at >= ? AND approved_at < ?", FeeAdjustApproved, period.From, period.To)
+	if assetType != "" {
+		query = query.Where("asset_type = ?", assetType)
+	}
+	if err := query.Group("asset_type, kind").Order("asset_type, kind").Scan(&report.Adjustments).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
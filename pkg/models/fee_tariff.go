@@ -0,0 +1,387 @@
+package models
+
+import (
+	"errors"
+	"intellectual_property/pkg/utils"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FeeTariff 费用标准
+// 按资产类型、子类型、费用类型和年度区间匹配，同一条件存在多条时取已生效的最新一条
+// 调价时新增一条生效日期在未来的记录即可，历史标准保留用于追溯
+type FeeTariff struct {
+	ID            int       `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	AssetType     string    `json:"asset_type" gorm:"type:varchar(20);index:idx_tariff_match;comment:资产类型(patent/article/trademark)"`
+	SubType       int       `json:"sub_type" gorm:"type:int;index:idx_tariff_match;comment:子类型代码(-1=全部子类型)"`
+	FeeKind       string    `json:"fee_kind" gorm:"type:varchar(20);index:idx_tariff_match;comment:费用类型(review/annuity/renewal)"`
+	YearFrom      int       `json:"year_from" gorm:"type:int;comment:起始年度(审核费为0)"`
+	YearTo        int       `json:"year_to" gorm:"type:int;comment:结束年度(含)"`
+	Amount        float64   `json:"amount" gorm:"type:decimal(10,2);comment:金额"`
+	EffectiveFrom time.Time `json:"effective_from" gorm:"type:datetime;comment:生效日期"`
+	Remark        string    `json:"remark" gorm:"type:varchar(255);comment:备注"`
+	CreatedBy     int       `json:"created_by" gorm:"type:bigint;comment:创建人ID"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// FeeDiscount 费用减缴政策，例如小微主体减缴
+// PayRatio 为减缴后的应缴比例，0.3 表示只需缴纳 30%
+// 只有 UserIDs 或 DepIDs 中列出的用户和单位可以在申请时使用，由财务核实资格后维护
+type FeeDiscount struct {
+	ID            int        `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	Code          string     `json:"code" gorm:"type:varchar(50);uniqueIndex;comment:减缴代码"`
+	Name          string     `json:"name" gorm:"type:varchar(100);comment:减缴名称"`
+	AssetType     string     `json:"asset_type" gorm:"type:varchar(20);comment:适用资产类型(为空表示全部)"`
+	FeeKinds      string     `json:"fee_kinds" gorm:"type:varchar(100);comment:适用费用类型,逗号分隔(为空表示全部)"`
+	PayRatio      float64    `json:"pay_ratio" gorm:"type:decimal(5,4);comment:应缴比例(0-1)"`
+	UserIDs       string     `json:"user_ids" gorm:"type:varchar(1000);comment:可使用的用户ID,逗号分隔"`
+	DepIDs        string     `json:"dep_ids" gorm:"type:varchar(255);comment:可使用的单位ID,逗号分隔"`
+	EffectiveFrom time.Time  `json:"effective_from" gorm:"type:datetime;comment:生效日期"`
+	EffectiveTo   *time.Time `json:"effective_to" gorm:"type:datetime;comment:失效日期(为空表示长期有效)"`
+	Enabled       bool       `json:"enabled" gorm:"comment:是否启用"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// FeeTariffDB 全局数据库连接实例
+var FeeTariffDB *gorm.DB = utils.DB
+
+// 费用标准相关错误
+var (
+	ErrFeeTariffInvalid    = errors.New("费用标准参数无效")
+	ErrFeeTariffEffective  = errors.New("已生效的费用标准不能删除，请新增一条标准替代")
+	ErrFeeDiscountNotFound = errors.New("费用减缴政策不存在或未启用")
+	ErrFeeDiscountInvalid  = errors.New("费用减缴参数无效")
+	ErrFeeDiscountDenied   = errors.New("没有使用该费用减缴政策的资格")
+	ErrFeeTariffNotFound   = errors.New("没有适用的费用标准，请联系财务配置")
+)
+
+// defaultReviewTariffs 内置的审核费标准，与原先写死在代码中的金额一致
+var defaultReviewTariffs = []FeeTariff{
+	{AssetType: AssetTypePatent, SubType: PatentInvention, Amount: 2},
+	{AssetType: AssetTypePatent, SubType: PracticalInvention, Amount: 1},
+	{AssetType: AssetTypePatent, SubType: AppearanceDesign, Amount: 1},
+	{AssetType: AssetTypeArticle, SubType: 0, Amount: 2},
+	{AssetType: AssetTypeArticle, SubType: 1, Amount: 1},
+	{AssetType: AssetTypeArticle, SubType: 2, Amount: 1},
+	{AssetType: AssetTypeTrademark, SubType: 0, Amount: 2},
+	{AssetType: AssetTypeTrademark, SubType: 1, Amount: 1},
+	{AssetType: AssetTypeTrademark, SubType: 2, Amount: 1},
+}
+
+// defaultFeeTariffs 内置的费用标准，只在 InitFeeTariffs 时写入空的费用标准表作为初始数据
+// 审核费见 defaultReviewTariffs，年费和续展费来自 config/fee.yaml；之后金额以费用标准表为准
+func defaultFeeTariffs() []FeeTariff {
+	var tariffs []FeeTariff
+	for _, t := range defaultReviewTariffs {
+		t.FeeKind = FeeKindReview
+		tariffs = append(tariffs, t)
+	}
+	for subType, rule := range map[int]utils.AnnuityRule{
+		PatentInvention:    utils.FeeScheduleConfig.Invention,
+		PracticalInvention: utils.FeeScheduleConfig.Utility,
+		AppearanceDesign:   utils.FeeScheduleConfig.Design,
+	} {
+		for _, b := range rule.Tariff {
+			tariffs = append(tariffs, FeeTariff{
+				AssetType: AssetTypePatent,
+				SubType:   subType,
+				FeeKind:   FeeKindAnnuity,
+				YearFrom:  b.From,
+				YearTo:    b.To,
+				Amount:    b.Amount,
+			})
+		}
+	}
+	tariffs = append(tariffs, FeeTariff{
+		AssetType: AssetTypeTrademark,
+		SubType:   WorkflowAnySubType,
+		FeeKind:   FeeKindRenewal,
+		YearFrom:  1,
+		YearTo:    math.MaxInt32,
+		Amount:    utils.FeeScheduleConfig.Trademark.Amount,
+	})
+	sort.SliceStable(tariffs, func(i, j int) bool {
+		if tariffs[i].SubType != tariffs[j].SubType {
+			return tariffs[i].SubType < tariffs[j].SubType
+		}
+		return tariffs[i].YearFrom < tariffs[j].YearFrom
+	})
+	return tariffs
+}
+
+// PickFeeTariff 从候选标准中选出适用的一条
+// 子类型精确匹配优先于全部子类型，同等条件下取 at 时已生效的最新一条
+func PickFeeTariff(tariffs []FeeTariff, subType int, year int, at time.Time) (FeeTariff, bool) {
+	var (
+		found FeeTariff
+		ok    bool
+	)
+	for _, t := range tariffs {
+		if t.SubType != subType && t.SubType != WorkflowAnySubType {
+			continue
+		}
+		if year < t.YearFrom || year > t.YearTo || t.EffectiveFrom.After(at) {
+			continue
+		}
+		if ok {
+			if found.SubType != WorkflowAnySubType && t.SubType == WorkflowAnySubType {
+				continue
+			}
+			if found.SubType == t.SubType && !t.EffectiveFrom.After(found.EffectiveFrom) {
+				continue
+			}
+		}
+		found, ok = t, true
+	}
+	return found, ok
+}
+
+// Applies 减缴政策是否适用于该资产类型和费用类型，并在 at 时有效
+func (d FeeDiscount) Applies(assetType string, feeKind string, at time.Time) bool {
+	if !d.Enabled || d.EffectiveFrom.After(at) || (d.EffectiveTo != nil && !d.EffectiveTo.After(at)) {
+		return false
+	}
+	if d.AssetType != "" && d.AssetType != assetType {
+		return false
+	}
+	return d.FeeKinds == "" || listContains(d.FeeKinds, feeKind)
+}
+
+// Eligible 用户是否可以使用该减缴政策，用户本人或所在单位在名单中即可
+func (d FeeDiscount) Eligible(userID int, depID int) bool {
+	return listContains(d.UserIDs, strconv.Itoa(userID)) ||
+		(depID != 0 && listContains(d.DepIDs, strconv.Itoa(depID)))
+}
+
+// listContains 逗号分隔的列表中是否包含 value
+func listContains(list string, value string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validIDList 校验逗号分隔的ID列表，为空视为有效
+func validIDList(list string) bool {
+	if strings.TrimSpace(list) == "" {
+		return true
+	}
+	for _, item := range strings.Split(list, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(item)); err != nil || id <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyFeeDiscount 按应缴比例计算减缴后的金额，精确到分
+func ApplyFeeDiscount(amount float64, payRatio float64) float64 {
+	return float64(toCents(amount*payRatio)) / 100
+}
+
+// feeQuoter 按费用标准和减缴政策计算某个资产某类费用的金额
+type feeQuoter struct {
+	tariffs  []FeeTariff
+	discount *FeeDiscount
+	subType  int
+	at       time.Time
+}
+
+// newFeeQuoter 读取费用标准和资产的减缴政策，金额只以费用标准表为准
+func newFeeQuoter(db *gorm.DB, assetType string, subType int, feeKind string, discountCode string, at time.Time) (*feeQuoter, error) {
+	q := &feeQuoter{subType: subType, at: at}
+	if err := db.Where("asset_type = ? AND fee_kind = ?", assetType, feeKind).Find(&q.tariffs).Error; err != nil {
+		return nil, err
+	}
+	if discountCode != "" {
+		var discounts []FeeDiscount
+		if err := db.Where("code = ?", discountCode).Limit(1).Find(&discounts).Error; err != nil {
+			return nil, err
+		}
+		if len(discounts) > 0 && discounts[0].Applies(assetType, feeKind, at) {
+			q.discount = &discounts[0]
+		}
+	}
+	return q, nil
+}
+
+// Amount 第 year 年度的金额，审核费 year 为 0；没有匹配的标准时返回 ErrFeeTariffNotFound
+func (q *feeQuoter) Amount(year int) (float64, error) {
+	t, ok := PickFeeTariff(q.tariffs, q.subType, year, q.at)
+	if !ok {
+		return 0, ErrFeeTariffNotFound
+	}
+	if q.discount != nil {
+		return ApplyFeeDiscount(t.Amount, q.discount.PayRatio), nil
+	}
+	return t.Amount, nil
+}
+
+// QuoteReviewFee 按当前费用标准计算审核费，在创建资产的事务中调用
+func QuoteReviewFee(tx *gorm.DB, assetType string, subType int, discountCode string) (float64, error) {
+	q, err := newFeeQuoter(tx, assetType, subType, FeeKindReview, discountCode, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return q.Amount(0)
+}
+
+// GetFeeTariffs 查询费用标准，assetType、feeKind 为空时不作为条件
+func GetFeeTariffs(assetType string, feeKind string) ([]FeeTariff, error) {
+	var tariffs []FeeTariff
+	query := FeeTariffDB.Model(&FeeTariff{})
+	if assetType != "" {
+		query = query.Where("asset_type = ?", assetType)
+	}
+	if feeKind != "" {
+		query = query.Where("fee_kind = ?", feeKind)
+	}
+	if err := query.Order("asset_type, fee_kind, sub_type, year_from, effective_from DESC").Find(&tariffs).Error; err != nil {
+		return nil, err
+	}
+	return tariffs, nil
+}
+
+// validateFeeTariff 校验费用标准
+func validateFeeTariff(t *FeeTariff) error {
+	if _, err := getAssetMeta(t.AssetType); err != nil {
+		return ErrFeeTariffInvalid
+	}
+	switch t.FeeKind {
+	case FeeKindReview:
+		t.YearFrom, t.YearTo = 0, 0
+	case FeeKindAnnuity, FeeKindRenewal:
+		if t.YearFrom < 1 || t.YearTo < t.YearFrom {
+			return ErrFeeTariffInvalid
+		}
+	default:
+		return ErrFeeTariffInvalid
+	}
+	if t.Amount < 0 {
+		return ErrFeeTariffInvalid
+	}
+	return nil
+}
+
+// CreateFeeTariff 新增费用标准，生效日期为空时立即生效
+func CreateFeeTariff(t *FeeTariff) error {
+	if err := validateFeeTariff(t); err != nil {
+		return err
+	}
+	if t.EffectiveFrom.IsZero() {
+		t.EffectiveFrom = time.Now()
+	}
+	t.ID = 0
+	return FeeTariffDB.Create(t).Error
+}
+
+// DeleteFeeTariff 删除尚未生效的费用标准
+func DeleteFeeTariff(id int) error {
+	var t FeeTariff
+	if err := FeeTariffDB.Where("id = ?", id).Take(&t).Error; err != nil {
+		return err
+	}
+	if !t.EffectiveFrom.After(time.Now()) {
+		return ErrFeeTariffEffective
+	}
+	return FeeTariffDB.Delete(&t).Error
+}
+
+// InitFeeTariffs 初始化费用标准
+// 费用标准表为空时写入内置标准，方便财务在此基础上调整
+func InitFeeTariffs() error {
+	var count int64
+	if err := FeeTariffDB.Model(&FeeTariff{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	tariffs := defaultFeeTariffs()
+	for i := range tariffs {
+		tariffs[i].Remark = "初始标准"
+	}
+	return FeeTariffDB.Create(&tariffs).Error
+}
+
+// GetFeeDiscounts 查询所有费用减缴政策
+func GetFeeDiscounts() ([]FeeDiscount, error) {
+	var discounts []FeeDiscount
+	if err := FeeTariffDB.Order("id").Find(&discounts).Error; err != nil {
+		return nil, err
+	}
+	return discounts, nil
+}
+
+// validateFeeDiscount 校验费用减缴政策
+func validateFeeDiscount(d *FeeDiscount) error {
+	if d.Code == "" || d.Name == "" || d.PayRatio < 0 || d.PayRatio > 1 {
+		return ErrFeeDiscountInvalid
+	}
+	// 必须限定可使用的用户或单位，否则任何申请人都能填写减缴代码
+	if strings.TrimSpace(d.UserIDs) == "" && strings.TrimSpace(d.DepIDs) == "" {
+		return ErrFeeDiscountInvalid
+	}
+	if !validIDList(d.UserIDs) || !validIDList(d.DepIDs) {
+		return ErrFeeDiscountInvalid
+	}
+	if d.AssetType != "" {
+		if _, err := getAssetMeta(d.AssetType); err != nil {
+			return ErrFeeDiscountInvalid
+		}
+	}
+	if d.EffectiveFrom.IsZero() {
+		d.EffectiveFrom = time.Now()
+	}
+	if d.EffectiveTo != nil && !d.EffectiveTo.After(d.EffectiveFrom) {
+		return ErrFeeDiscountInvalid
+	}
+	return nil
+}
+
+// SaveFeeDiscount 新增或修改费用减缴政策，ID 为 0 时新增
+// 修改只影响之后生成的费用，已生成的费用金额不变
+func SaveFeeDiscount(d *FeeDiscount) error {
+	if err := validateFeeDiscount(d); err != nil {
+		return err
+	}
+	if d.ID != 0 {
+		var existing FeeDiscount
+		if err := FeeTariffDB.Where("id = ?", d.ID).Take(&existing).Error; err != nil {
+			return err
+		}
+		d.CreatedAt = existing.CreatedAt
+	}
+	return FeeTariffDB.Save(d).Error
+}
+
+// CheckFeeDiscount 校验申请人能否使用申请时填写的减缴代码，为空表示不减缴
+func CheckFeeDiscount(code string, userID int) error {
+	if code == "" {
+		return nil
+	}
+	var discounts []FeeDiscount
+	if err := FeeTariffDB.Where("code = ? AND enabled = ?", code, true).Limit(1).Find(&discounts).Error; err != nil {
+		return err
+	}
+	if len(discounts) == 0 {
+		return ErrFeeDiscountNotFound
+	}
+	var depID int
+	if err := FeeTariffDB.Model(&User{}).Select("dep_id").Where("id = ?", userID).Scan(&depID).Error; err != nil {
+		return err
+	}
+	if !discounts[0].Eligible(userID, depID) {
+		return ErrFeeDiscountDenied
+	}
+	return nil
+}
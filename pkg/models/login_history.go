@@ -0,0 +1,65 @@
+package models
+
+import (
+	"intellectual_property/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 登录结果原因
+const (
+	LoginReasonSuccess       = "success"        // 登录成功
+	LoginReasonUserNotFound  = "user_not_found" // 用户不存在
+	LoginReasonWrongPassword = "wrong_password" // 密码错误
+	LoginReasonLocked        = "locked"         // 账号或IP已锁定
+)
+
+// LoginHistory 登录历史，每次登录尝试都记录一条
+type LoginHistory struct {
+	ID        int       `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	UserID    int       `json:"user_id" gorm:"type:bigint;index;comment:用户ID(用户不存在时为0)"`
+	Email     string    `json:"email" gorm:"type:varchar(100);index;comment:登录时填写的邮箱"`
+	IP        string    `json:"ip" gorm:"type:varchar(64);index;comment:客户端IP"`
+	UserAgent string    `json:"user_agent" gorm:"type:varchar(255);comment:客户端UA"`
+	Success   bool      `json:"success" gorm:"comment:是否登录成功"`
+	Reason    string    `json:"reason" gorm:"type:varchar(30);comment:登录结果原因"`
+	CreatedAt time.Time `json:"created_at" gorm:"index;comment:登录时间"`
+}
+
+// LoginHistoryDB 全局数据库连接实例
+var LoginHistoryDB *gorm.DB = utils.DB
+
+// RecordLoginHistory 记录一次登录尝试，UA 超出字段长度时按字符截断
+func RecordLoginHistory(h *LoginHistory) error {
+	h.UserAgent = utils.TruncateString(h.UserAgent, 255)
+	return LoginHistoryDB.Create(h).Error
+}
+
+// GetLoginHistory 分页查询登录历史，userID 为 0、email 和 ip 为空时不作为条件
+func GetLoginHistory(userID int, email string, ip string, page int, pageSize int) ([]LoginHistory, int64, error) {
+	var (
+		histories []LoginHistory
+		total     int64
+	)
+	query := LoginHistoryDB.Model(&LoginHistory{})
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if email != "" {
+		query = query.Where("email = ?", email)
+	}
+	if ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&histories).Error; err != nil {
+		return nil, 0, err
+	}
+	return histories, total, nil
+}
@@ -3,11 +3,6 @@ package models
 import (
 	"errors"
 	"intellectual_property/pkg/utils"
-	"log"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -37,20 +32,14 @@ type Patent struct {
 	Authors       []PatentAuthor `json:"authors" gorm:"foreignKey:PatentID;comment:所有作者关联记录"`
 
 	// 审批流程字段
-	CurrentStep    int `json:"current_step" gorm:"type:int;comment:当前审批步骤(1=初审,2=终审)"`
+	CurrentStep    int `json:"current_step" gorm:"type:int;comment:当前审批步骤(对应审批流程中的环节序号)"`
 	ApprovalStatus int `json:"approval_status" gorm:"type:int;comment:整体审批状态(0=进行中,1=通过,2=驳回)"`
+	Revision       int `json:"revision" gorm:"type:int;default:1;comment:提交版本号(驳回后每次重新提交加1)"`
 
-	// 初审相关字段
-	InitialReviewerID int       `json:"initial_reviewer_id" gorm:"type:bigint;comment:初审人ID"`
-	InitialComment    string    `json:"initial_comment" gorm:"type:text;comment:初审意见"`
-	InitialSubmitTime time.Time `json:"initial_submit_time" gorm:"type:datetime;comment:初审提交时间"`
-	InitialStatus     bool      `json:"initial_status" gorm:"comment:初审状态"`
-
-	// 终审相关字段
-	FinalReviewerID int       `json:"final_reviewer_id" gorm:"type:bigint;comment:终审人ID"`
-	FinalComment    string    `json:"final_comment" gorm:"type:text;comment:终审意见"`
-	FinalSubmitTime time.Time `json:"final_submit_time" gorm:"type:datetime;comment:终审提交时间"`
-	FinalStatus     bool      `json:"final_status" gorm:"comment:终审状态"`
+	// 费用减缴代码，为空表示不减缴
+	DiscountCode string `json:"discount_code" gorm:"type:varchar(50);comment:费用减缴代码"`
+	// 授权后的费用宽限期满仍未缴纳时失效
+	LapsedAt *time.Time `json:"lapsed_at" gorm:"type:datetime;comment:失效日期(为空表示有效)"`
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -99,24 +88,14 @@ func NewPatent(
 		})
 	}
 
-	// 初始化年费对象
-	var reviewFee float64
-	switch patentType {
-	case PracticalInvention:
-		reviewFee = 1
-	case PatentInvention:
-		reviewFee = 2
-	case AppearanceDesign:
-		reviewFee = 1
-	}
-
+	// 初始化年费对象，审核费金额在创建时按费用标准计算
 	patentFee := &PatentFee{
 		PatentID:        0, // 后续在创建专利时更新
-		ReviewFee:       reviewFee,
 		IsPaid:          false,
 		CreatedAt:       time.Now(),
 		PaymentDeadline: time.Now().AddDate(0, 1, 0), //一个月
 		Status:          0,
+		FeeKind:         FeeKindReview,
 	}
 
 	return &Patent{
@@ -126,8 +105,9 @@ func NewPatent(
 		FirstAuthorID:     firstAuthorID,
 		Authors:           authors,
 		ApplyDate:         time.Now(),
-		CurrentStep:       1,
+		CurrentStep:       ApprovalStepInitial,
 		ApprovalStatus:    0,
+		Revision:          1,
 		ApplicationNumber: applicationNumber, // 初始化申请号字段
 	}, patentFee, nil
 }
@@ -137,8 +117,17 @@ func NewPatent(
 // 1. 创建主记录
 // 2. 创建作者关联记录
 // 3. 更新第一作者外键
-func (patent *Patent) CreatePatentService(patentFee *PatentFee) error {
+// 4. 登记已写入存储的附件
+// 5. 记录提交申请和生成费用事件
+func (patent *Patent) CreatePatentService(patentFee *PatentFee, submitterID int, attachments []StagedAttachment) error {
 	return PatentDB.Transaction(func(tx *gorm.DB) error {
+		// 按当前费用标准和减缴政策计算审核费
+		reviewFee, err := QuoteReviewFee(tx, AssetTypePatent, patent.PatentType, patent.DiscountCode)
+		if err != nil {
+			return err
+		}
+		patentFee.ReviewFee = reviewFee
+
 		// 创建主记录
 		if err := tx.Create(patent).Error; err != nil {
 			return err
@@ -170,13 +159,14 @@ func (patent *Patent) CreatePatentService(patentFee *PatentFee) error {
 			return err
 		}
 
-		return nil
-	})
-}
+		// 登记已写入存储的附件
+		if _, err := registerAttachments(tx, AssetTypePatent, patent.ID, submitterID, attachments); err != nil {
+			return err
+		}
 
-// UpdatePatentUrl 更新资源url
-func (patent *Patent) UpdatePatentUrl() error {
-	return PatentDB.Model(&Patent{}).Where("id =?", patent.ID).Select("attachment_url").Updates(patent).Error
+		// 记录提交申请和生成费用事件
+		return recordSubmitEvents(tx, AssetTypePatent, patent.ID, submitterID, patentFee.ID, patentFee.ReviewFee)
+	})
 }
 
 // GetAllPatents 获取所有专利及其关联信息
@@ -257,22 +247,9 @@ func GetAllPatents(
 	return patents, total, nil
 }
 
-// GetPatentFile 根据专利id来查询所有文件地址
-func GetPatentFile(id int) ([]string, error) {
-	var ans []string
-	var patent Patent
-	PatentDB.Model(&Patent{}).Select("application_number").Where("id =?", id).Find(&patent)
-
-	baseurl := utils.NgX.LocationPatent + "/" + patent.ApplicationNumber
-	dir, err := os.ReadDir(baseurl)
-	if err != nil {
-		return nil, err
-	}
-	for _, file := range dir {
-		ans = append(ans, "/patent/"+patent.ApplicationNumber+"/"+file.Name())
-	}
-
-	return ans, nil
+// GetPatentFile 查询专利附件的最新版本
+func GetPatentFile(id int) ([]Attachment, error) {
+	return ListAttachments(AssetTypePatent, id, false)
 }
 
 // DeletePatent 删除专利及其关联数据
@@ -283,13 +260,19 @@ func GetPatentFile(id int) ([]string, error) {
 //   - error 错误信息
 func DeletePatent(id int) error {
 	// 开启数据库事务
+	var task *StorageTask
 	err := PatentDB.Transaction(func(tx *gorm.DB) error {
 		// 1. 删除作者关联记录
 		if err := tx.Where("patent_id = ?", id).Delete(&PatentAuthor{}).Error; err != nil {
 			return err
 		}
+		// 2. 删除附件记录，登记文件删除任务
+		var err error
+		if task, err = deleteAssetFiles(tx, AssetTypePatent, id); err != nil {
+			return err
+		}
 
-		// 2. 删除主记录（修正后的版本）
+		// 3. 删除主记录（修正后的版本）
 		if err := tx.Where("id = ?", id).Delete(&Patent{}).Error; err != nil {
 			return err
 		}
@@ -297,31 +280,14 @@ func DeletePatent(id int) error {
 		return nil
 	})
 
-	// 3. 清理文件系统（在事务成功后执行）
-	if err == nil {
-		if cleanErr := cleanPatentFiles(id); cleanErr != nil {
-			log.Printf("文件清理失败: %v", cleanErr)
-		}
+	// 4. 清理文件系统（在事务成功后执行，失败的由定时任务重试）
+	if err == nil && task != nil {
+		runStorageTasksNow([]*StorageTask{task})
 	}
 
 	return err
 }
 
-// cleanPatentFiles 清理专利相关文件
-func cleanPatentFiles(id int) error {
-	// 构建安全路径
-	safePath := filepath.Join(utils.NgX.LocationPatent, "/", strconv.Itoa(id))
-
-	// 验证路径是否在允许的根目录下（防止路径遍历攻击）
-	if !strings.HasPrefix(filepath.Clean(safePath)+string(filepath.Separator),
-		filepath.Clean(utils.NgX.LocationPatent)+string(filepath.Separator)) {
-		return errors.New("非法路径")
-	}
-
-	// 删除目录及其内容
-	return os.RemoveAll(safePath)
-}
-
 // GetPatentById 根据id查询Patent
 func GetPatentById(id int) (Patent, error) {
 	var patent Patent
@@ -332,74 +298,12 @@ func GetPatentById(id int) (Patent, error) {
 }
 
 // UpdatePatentStatus 更新状态
+// 审批流转统一交由审批引擎 ReviewAsset 处理
 func UpdatePatentStatus(
 	patentId int,
 	ReviewerID int,
 	Comment string,
 	Status int, // 0=驳回，1=通过
 ) error {
-	// 查询完整专利信息
-	patent, err := GetPatentById(patentId)
-	if err != nil {
-		return err
-	}
-
-	now := time.Now()
-	updates := make(map[string]interface{})
-
-	if patent.CurrentStep == ApprovalStepInitial {
-		// 初审逻辑
-		patent.InitialReviewerID = ReviewerID
-		patent.InitialComment = Comment
-		patent.InitialSubmitTime = now
-		patent.InitialStatus = Status == 1
-
-		if Status == 1 {
-			// 初审通过，进入终审
-			patent.CurrentStep = ApprovalStepFinal
-		} else {
-			// 初审驳回
-			patent.ApprovalStatus = ApprovalStatusRejected
-		}
-
-		updates = map[string]interface{}{
-			"current_step":        patent.CurrentStep,
-			"approval_status":     patent.ApprovalStatus,
-			"initial_reviewer_id": patent.InitialReviewerID,
-			"initial_comment":     patent.InitialComment,
-			"initial_submit_time": patent.InitialSubmitTime,
-			"initial_status":      patent.InitialStatus,
-		}
-	} else if patent.CurrentStep == ApprovalStepFinal {
-		// 终审逻辑
-		patent.FinalReviewerID = ReviewerID
-		patent.FinalComment = Comment
-		patent.FinalSubmitTime = now
-		patent.FinalStatus = Status == 1
-
-		if Status == 1 {
-			// 终审通过
-			patent.ApprovalStatus = ApprovalStatusApproved
-		} else {
-			// 终审驳回
-			patent.ApprovalStatus = ApprovalStatusRejected
-		}
-
-		updates = map[string]interface{}{
-			"approval_status":   patent.ApprovalStatus,
-			"final_reviewer_id": patent.FinalReviewerID,
-			"final_comment":     patent.FinalComment,
-			"final_submit_time": patent.FinalSubmitTime,
-			"final_status":      patent.FinalStatus,
-		}
-	}
-
-	// 执行数据库更新
-	if err := PatentDB.Model(&Patent{}).
-		Where("id = ?", patentId).
-		Updates(updates).Error; err != nil {
-		return err
-	}
-
-	return nil
+	return ReviewAsset(AssetTypePatent, patentId, ReviewerID, Comment, Status)
 }
@@ -17,6 +17,11 @@ type PatentFee struct {
 	PaymentDeadline time.Time `json:"payment_deadline" gorm:"type:datetime;comment:截至缴费日期"`
 	// 新增状态字段，可根据实际情况修改类型和注释
 	Status int `json:"status" gorm:"type:int;comment:费用状态"`
+	// 费用类型和年度：审核费、第 Year 年的年费、第 Year 次续展费
+	FeeKind string `json:"fee_kind" gorm:"type:varchar(20);default:review;comment:费用类型(review/annuity/renewal)"`
+	Year    int    `json:"year" gorm:"type:int;default:0;comment:年度或续展次数(审核费为0)"`
+	// 逾期后加收的滞纳金，应缴金额为 ReviewFee + Surcharge
+	Surcharge float64 `json:"surcharge" gorm:"type:decimal(10,2);default:0;comment:滞纳金"`
 }
 
 // GetAllPatentFees 获取所有专利年费
@@ -62,28 +67,3 @@ func GetAllPatentFees(keyword string, status int, page int, pageSize int) ([]Pat
 
 	return fees, total, nil
 }
-
-// GetMonthlyPatentFeeStats 获取本月专利年费统计信息
-func GetMonthlyPatentFeeStats() (int, float64, int, float64, error) {
-
-	var fees []PatentFee
-	if err := PatentDB.Find(&fees).Error; err != nil {
-		return 0, 0, 0, 0, err
-	}
-
-	var pendingCount, overdueCount int
-	var paidAmount, totalAmount float64
-
-	for _, fee := range fees {
-		totalAmount += fee.ReviewFee
-		if fee.Status == 0 {
-			pendingCount++
-		} else if fee.Status == 1 {
-			paidAmount += fee.ReviewFee
-		} else if fee.Status == 2 {
-			overdueCount++
-		}
-	}
-
-	return pendingCount, paidAmount, overdueCount, totalAmount, nil
-}
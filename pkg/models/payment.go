@@ -0,0 +1,390 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 支付订单状态
+// 状态只能按 paymentTransitions 流转：created -> paid/closed，paid -> refunded
+// 例外是关闭后才收到的支付通知，款项已经收到，订单记为已支付并备注需要退款
+const (
+	PaymentStateCreated  = "created"  // 已创建，等待支付
+	PaymentStatePaid     = "paid"     // 已支付
+	PaymentStateClosed   = "closed"   // 已关闭，未支付
+	PaymentStateRefunded = "refunded" // 已退款
+)
+
+// paymentTransitions 支付订单允许的状态流转
+var paymentTransitions = map[string][]string{
+	PaymentStateCreated: {PaymentStatePaid, PaymentStateClosed},
+	PaymentStatePaid:    {PaymentStateRefunded},
+}
+
+// 支付订单相关错误
+var (
+	ErrPaymentOrderNotFound  = errors.New("支付订单不存在")
+	ErrPaymentOrderState     = errors.New("支付订单状态不允许此操作")
+	ErrPaymentNotOffline     = errors.New("不是线下转账订单")
+	ErrPaymentNoVoucher      = errors.New("尚未上传转账凭证")
+	ErrPaymentPaidAfterClose = errors.New("订单关闭后才支付，需要退款")
+)
+
+// 需要退款的订单备注，订单已支付但款项没有计入费用
+const (
+	PaymentRemarkDuplicate      = "费用已由其他订单支付，需要退款"
+	PaymentRemarkPaidAfterClose = "订单关闭后才支付，需要退款"
+)
+
+// PaymentOrder 支付订单流水
+// 每次发起支付生成一条，订单号即支付渠道的商户订单号，费用表只记录最终的缴费结果
+type PaymentOrder struct {
+	ID             int        `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	OrderNo        string     `json:"order_no" gorm:"type:varchar(64);uniqueIndex;comment:商户订单号"`
+	AssetType      string     `json:"asset_type" gorm:"type:varchar(20);index:idx_payment_fee;comment:资产类型(patent/article/trademark)"`
+	FeeID          int        `json:"fee_id" gorm:"type:bigint;index:idx_payment_fee;comment:费用ID"`
+	Amount         float64    `json:"amount" gorm:"type:decimal(10,2);comment:订单金额"`
+	RefundedAmount float64    `json:"refunded_amount" gorm:"type:decimal(10,2);comment:已退款金额"`
+	Channel        string     `json:"channel" gorm:"type:varchar(20);comment:支付渠道"`
+	State          string     `json:"state" gorm:"type:varchar(20);index;comment:订单状态(created/paid/closed/refunded)"`
+	TradeNo        string     `json:"trade_no" gorm:"type:varchar(64);comment:渠道交易号"`
+	PayerID        int        `json:"payer_id" gorm:"type:bigint;comment:发起支付的用户ID"`
+	NotifyPayload  string     `json:"notify_payload" gorm:"type:text;comment:支付渠道通知原文"`
+	VoucherPath    string     `json:"voucher_path" gorm:"type:varchar(255);comment:线下转账凭证路径"`
+	Remark         string     `json:"remark" gorm:"type:varchar(255);comment:备注"`
+	PaidAt         *time.Time `json:"paid_at" gorm:"comment:支付时间"`
+	ClosedAt       *time.Time `json:"closed_at" gorm:"comment:关闭时间"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// PaymentDB 全局数据库连接实例
+var PaymentDB *gorm.DB = utils.DB
+
+// NeedsRefund 订单已支付但款项没有计入费用，例如费用已由其他订单支付，备注中说明原因
+func (o PaymentOrder) NeedsRefund() bool {
+	return o.Remark != ""
+}
+
+// ExpireAt 渠道订单的支付截止时间，到期后支付渠道关闭交易
+func (o PaymentOrder) ExpireAt() time.Time {
+	return o.CreatedAt.Add(utils.PaymentConfig.OrderExpire)
+}
+
+// CanTransitionPayment 判断支付订单能否从 from 流转到 to
+func CanTransitionPayment(from string, to string) bool {
+	for _, s := range paymentTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatePaymentOrder 为费用创建一条待支付订单
+func CreatePaymentOrder(fee FeeInfo, channel string, payerID int) (*PaymentOrder, error) {
+	order := &PaymentOrder{
+		OrderNo:   NewOutTradeNo(fee.AssetType, fee.ID),
+		AssetType: fee.AssetType,
+		FeeID:     fee.ID,
+		Amount:    fee.Amount,
+		Channel:   channel,
+		State:     PaymentStateCreated,
+		PayerID:   payerID,
+	}
+	if err := PaymentDB.Create(order).Error; err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetPaymentOrder 按订单号查询支付订单
+func GetPaymentOrder(orderNo string) (*PaymentOrder, error) {
+	var order PaymentOrder
+	if err := PaymentDB.Where("order_no = ?", orderNo).Take(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetPaymentOrdersSince 查询某个时间之后创建的支付订单，对账时使用
+func GetPaymentOrdersSince(since time.Time) ([]PaymentOrder, error) {
+	var orders []PaymentOrder
+	if err := PaymentDB.Where("created_at >= ?", since).Order("id ASC").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// lockPaymentOrder 在事务中按订单号锁定支付订单
+func lockPaymentOrder(tx *gorm.DB, orderNo string) (*PaymentOrder, error) {
+	var order PaymentOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_no = ?", orderNo).
+		Take(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentOrderNotFound
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CompletePaymentOrder 支付成功后更新订单和费用
+// 订单已是已支付状态时返回 alreadyPaid=true，重复通知不会重复记账
+// 费用已由其他订单支付时订单仍记为已支付并备注，返回 ErrFeeAlreadyPaid，需要人工退款
+// 订单已在本地关闭（费用变化或已免除）时同样记为已支付并备注，不计入费用，返回 ErrPaymentPaidAfterClose
+func CompletePaymentOrder(orderNo string, amount float64, tradeNo string, paidAt time.Time, payload string) (alreadyPaid bool, err error) {
+	var duplicate, afterClose bool
+	err = PaymentDB.Transaction(func(tx *gorm.DB) error {
+		order, err := lockPaymentOrder(tx, orderNo)
+		if err != nil {
+			return err
+		}
+		if order.State == PaymentStatePaid {
+			alreadyPaid = true
+			return nil
+		}
+		if order.State != PaymentStateClosed && !CanTransitionPayment(order.State, PaymentStatePaid) {
+			return ErrPaymentOrderState
+		}
+		if toCents(order.Amount) != toCents(amount) {
+			return ErrFeeAmountMismatch
+		}
+
+		updates := map[string]interface{}{
+			"state":          PaymentStatePaid,
+			"trade_no":       tradeNo,
+			"paid_at":        paidAt,
+			"notify_payload": payload,
+		}
+		if order.State == PaymentStateClosed {
+			afterClose = true
+			updates["remark"] = PaymentRemarkPaidAfterClose
+			return tx.Model(order).Updates(updates).Error
+		}
+		duplicate, err = markFeePaid(tx, order.AssetType, order.FeeID, amount, tradeNo, paidAt, order.PayerID)
+		if err != nil {
+			return err
+		}
+		if duplicate {
+			updates["remark"] = PaymentRemarkDuplicate
+		}
+		return tx.Model(order).Updates(updates).Error
+	})
+	switch {
+	case err != nil:
+	case duplicate:
+		err = ErrFeeAlreadyPaid
+	case afterClose:
+		err = ErrPaymentPaidAfterClose
+	}
+	return alreadyPaid, err
+}
+
+// closeCreatedPaymentOrders 关闭费用的全部待支付订单，费用金额或状态变化后调用，避免按旧金额支付
+// 同时登记关闭渠道交易的任务，调用方在事务提交后执行 runPaymentCloseTasksNow
+func closeCreatedPaymentOrders(tx *gorm.DB, assetType string, feeIDs []int, now time.Time) ([]*PaymentCloseTask, error) {
+	if len(feeIDs) == 0 {
+		return nil, nil
+	}
+	var orders []PaymentOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("asset_type = ? AND fee_id IN ? AND state = ?", assetType, feeIDs, PaymentStateCreated).
+		Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	ids := make([]int, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	if err := tx.Model(&PaymentOrder{}).Where("id IN ?", ids).
+		Updates(map[string]interface{}{"state": PaymentStateClosed, "closed_at": now}).Error; err != nil {
+		return nil, err
+	}
+	return enqueuePaymentCloseTasks(tx, orders)
+}
+
+// ClosePaymentOrder 关闭未支付的订单，已关闭时直接返回
+func ClosePaymentOrder(orderNo string, payload string) error {
+	return PaymentDB.Transaction(func(tx *gorm.DB) error {
+		order, err := lockPaymentOrder(tx, orderNo)
+		if err != nil {
+			return err
+		}
+		if order.State == PaymentStateClosed {
+			return nil
+		}
+		if !CanTransitionPayment(order.State, PaymentStateClosed) {
+			return ErrPaymentOrderState
+		}
+		updates := map[string]interface{}{
+			"state":     PaymentStateClosed,
+			"closed_at": time.Now(),
+		}
+		if payload != "" {
+			updates["notify_payload"] = payload
+		}
+		return tx.Model(order).Updates(updates).Error
+	})
+}
+
+// AttachPaymentVoucher 线下转账订单上传转账凭证
+func AttachPaymentVoucher(orderNo string, voucherPath string) error {
+	return PaymentDB.Transaction(func(tx *gorm.DB) error {
+		order, err := lockPaymentOrder(tx, orderNo)
+		if err != nil {
+			return err
+		}
+		if order.Channel != utils.PaymentChannelOffline {
+			return ErrPaymentNotOffline
+		}
+		if order.State != PaymentStateCreated {
+			return ErrPaymentOrderState
+		}
+		return tx.Model(order).Update("voucher_path", voucherPath).Error
+	})
+}
+
+// ConfirmOfflinePayment 财务核对到账后确认线下转账订单，tradeNo 为银行流水号
+func ConfirmOfflinePayment(orderNo string, tradeNo string, confirmerID int) error {
+	order, err := GetPaymentOrder(orderNo)
+	if err != nil {
+		return err
+	}
+	if order.Channel != utils.PaymentChannelOffline {
+		return ErrPaymentNotOffline
+	}
+	if order.VoucherPath == "" {
+		return ErrPaymentNoVoucher
+	}
+	// 财务已驳回的线下转账不能再确认
+	if order.State == PaymentStateClosed {
+		return ErrPaymentOrderState
+	}
+	payload := fmt.Sprintf("线下转账 财务确认人ID:%d 银行流水号:%s", confirmerID, tradeNo)
+	_, err = CompletePaymentOrder(orderNo, order.Amount, tradeNo, time.Now(), payload)
+	return err
+}
+
+// RejectOfflinePayment 凭证核对不通过时关闭线下转账订单，申请人可以重新发起支付
+func RejectOfflinePayment(orderNo string, reason string, confirmerID int) error {
+	order, err := GetPaymentOrder(orderNo)
+	if err != nil {
+		return err
+	}
+	if order.Channel != utils.PaymentChannelOffline {
+		return ErrPaymentNotOffline
+	}
+	return ClosePaymentOrder(orderNo, fmt.Sprintf("线下转账 财务驳回人ID:%d 原因:%s", confirmerID, reason))
+}
+
+// 对账差异类型
+const (
+	MismatchMissingNotify = "missing_notify" // 渠道已支付，本地未收到通知
+	MismatchRemoteClosed  = "remote_closed"  // 渠道已关闭，本地仍待支付
+	MismatchRemoteUnpaid  = "remote_unpaid"  // 本地已支付，渠道未支付或不存在
+	MismatchClosedPaid    = "closed_paid"    // 本地已关闭，渠道却已支付
+	MismatchAmount        = "amount"         // 金额不一致
+	MismatchTradeNo       = "trade_no"       // 渠道交易号不一致
+	MismatchQueryFailed   = "query_failed"   // 查询渠道交易失败
+)
+
+// ReconcileMismatch 一条对账差异
+type ReconcileMismatch struct {
+	Order  PaymentOrder
+	Remote utils.RemoteTrade
+	Kind   string
+	Detail string
+}
+
+// ComparePaymentOrder 比较本地订单和渠道交易，一致时返回空字符串
+// 本地待支付而渠道不存在交易属于正常情况（用户未打开支付页面）
+func ComparePaymentOrder(order PaymentOrder, remote utils.RemoteTrade) string {
+	if remote.Exists && toCents(remote.TotalAmount) != toCents(order.Amount) {
+		return MismatchAmount
+	}
+	switch order.State {
+	case PaymentStateCreated:
+		switch {
+		case !remote.Exists:
+			return ""
+		case remote.Status == utils.TradeStatusPaid:
+			return MismatchMissingNotify
+		case remote.Status == utils.TradeStatusClosed:
+			return MismatchRemoteClosed
+		}
+	case PaymentStatePaid:
+		if !remote.Exists || remote.Status != utils.TradeStatusPaid {
+			return MismatchRemoteUnpaid
+		}
+		if remote.TradeNo != order.TradeNo {
+			return MismatchTradeNo
+		}
+	case PaymentStateClosed:
+		if remote.Exists && remote.Status == utils.TradeStatusPaid {
+			return MismatchClosedPaid
+		}
+	}
+	return ""
+}
+
+// ReconcilePaymentOrders 按订单的支付渠道逐笔查询渠道交易并与本地订单比较，返回所有差异
+// queriers 中没有的渠道（如线下转账）不参与对账
+func ReconcilePaymentOrders(ctx context.Context, orders []PaymentOrder, queriers map[string]utils.TradeQuerier) []ReconcileMismatch {
+	var mismatches []ReconcileMismatch
+	for _, order := range orders {
+		querier, ok := queriers[order.Channel]
+		if !ok {
+			continue
+		}
+		remote, err := querier.QueryTrade(ctx, order.OrderNo)
+		if err != nil {
+			mismatches = append(mismatches, ReconcileMismatch{Order: order, Kind: MismatchQueryFailed, Detail: err.Error()})
+			continue
+		}
+		kind := ComparePaymentOrder(order, remote)
+		if kind == "" {
+			continue
+		}
+		mismatches = append(mismatches, ReconcileMismatch{
+			Order:  order,
+			Remote: remote,
+			Kind:   kind,
+			Detail: fmt.Sprintf("本地:%s %.2f %s 渠道:%s %.2f %s", order.State, order.Amount, order.TradeNo, remote.Status, remote.TotalAmount, remote.TradeNo),
+		})
+	}
+	return mismatches
+}
+
+// FixReconcileMismatch 按渠道结果修复可以自动处理的差异
+// 只处理漏掉的支付通知、本地关闭后渠道已支付和渠道已关闭的订单，其他差异需要人工核实，返回 false
+// 本地关闭后渠道已支付的订单记为需要退款
+func FixReconcileMismatch(m ReconcileMismatch) (bool, error) {
+	switch m.Kind {
+	case MismatchMissingNotify, MismatchClosedPaid:
+		_, err := CompletePaymentOrder(m.Order.OrderNo, m.Remote.TotalAmount, m.Remote.TradeNo, time.Now(), "reconcile")
+		if err != nil && !errors.Is(err, ErrPaymentPaidAfterClose) && !errors.Is(err, ErrFeeAlreadyPaid) {
+			return false, err
+		}
+		return true, nil
+	case MismatchRemoteClosed:
+		if err := ClosePaymentOrder(m.Order.OrderNo, ""); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
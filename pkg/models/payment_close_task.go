@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 关闭渠道交易最多重试次数，超过后不再自动执行，订单关闭后收到的支付按需要退款处理
+const maxPaymentCloseAttempts = 20
+
+// PaymentCloseTask 关闭渠道交易的发件箱
+// 本地关闭待支付订单时在同一事务中登记，提交后再调用支付渠道关闭交易；失败的由定时任务重试，
+// 避免本地订单已关闭而支付页面或二维码仍然可以付款
+type PaymentCloseTask struct {
+	ID        int        `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	OrderNo   string     `json:"order_no" gorm:"type:varchar(64);comment:商户订单号"`
+	Channel   string     `json:"channel" gorm:"type:varchar(20);comment:支付渠道"`
+	Attempts  int        `json:"attempts" gorm:"type:int;comment:已执行次数"`
+	LastError string     `json:"last_error" gorm:"type:varchar(500);comment:最近一次失败原因"`
+	DoneAt    *time.Time `json:"done_at" gorm:"type:datetime;index;comment:完成时间"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PaymentCloseTaskDB 全局数据库连接实例
+var PaymentCloseTaskDB *gorm.DB = utils.DB
+
+// enqueuePaymentCloseTasks 在事务中为关闭的订单登记关闭渠道交易的任务，线下转账没有渠道交易，不登记
+func enqueuePaymentCloseTasks(tx *gorm.DB, orders []PaymentOrder) ([]*PaymentCloseTask, error) {
+	var tasks []*PaymentCloseTask
+	for _, order := range orders {
+		if order.Channel == utils.PaymentChannelOffline {
+			continue
+		}
+		task := &PaymentCloseTask{OrderNo: order.OrderNo, Channel: order.Channel}
+		if err := tx.Create(task).Error; err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// runPaymentCloseTask 调用支付渠道关闭交易并记录结果，关闭是幂等的，多个实例重复执行不影响结果
+func runPaymentCloseTask(ctx context.Context, task *PaymentCloseTask) error {
+	closer, err := utils.GetTradeCloser(task.Channel)
+	if err == nil {
+		err = closer.CloseTrade(ctx, task.OrderNo)
+	}
+	updates := map[string]interface{}{"attempts": gorm.Expr("attempts + 1")}
+	if err != nil {
+		msg := err.Error()
+		if len(msg) > 500 {
+			msg = msg[:500]
+		}
+		updates["last_error"] = msg
+	} else {
+		updates["done_at"] = time.Now()
+	}
+	if dbErr := PaymentCloseTaskDB.Model(&PaymentCloseTask{}).Where("id = ? AND done_at IS NULL", task.ID).
+		Updates(updates).Error; dbErr != nil {
+		return dbErr
+	}
+	return err
+}
+
+// runPaymentCloseTasksNow 事务提交后立即关闭本次登记的渠道交易，失败的留给定时任务重试
+func runPaymentCloseTasksNow(tasks []*PaymentCloseTask) {
+	for _, task := range tasks {
+		if err := runPaymentCloseTask(context.Background(), task); err != nil {
+			utils.Logger.Error(fmt.Sprintf("关闭渠道交易 %s 失败，稍后重试: %v", task.OrderNo, err))
+		}
+	}
+}
+
+// RunPaymentCloseJob 定时重试未完成的关闭渠道交易任务
+func RunPaymentCloseJob(ctx context.Context) error {
+	var tasks []PaymentCloseTask
+	if err := PaymentCloseTaskDB.Where("done_at IS NULL AND attempts < ?", maxPaymentCloseAttempts).
+		Order("id").Limit(500).Find(&tasks).Error; err != nil {
+		return err
+	}
+	failed := 0
+	for i := range tasks {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := runPaymentCloseTask(ctx, &tasks[i]); err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		utils.Logger.Warn(fmt.Sprintf("关闭渠道交易: 执行 %d 个, 失败 %d 个", len(tasks), failed))
+	}
+	return nil
+}
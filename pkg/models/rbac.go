@@ -0,0 +1,219 @@
+package models
+
+import (
+	"errors"
+	"intellectual_property/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// 角色代码
+const (
+	RoleApplicant     = "applicant"      // 申请人
+	RoleDeptReviewer  = "dept_reviewer"  // 院系/部门审核人
+	RoleIPOfficer     = "ip_officer"     // 知识产权办公室
+	RoleLegalReviewer = "legal_reviewer" // 法务审核人
+	RoleFinance       = "finance"        // 财务
+	RoleSystemAdmin   = "system_admin"   // 系统管理员
+)
+
+// 权限代码
+const (
+	PermAssetApply     = "asset:apply"     // 提交、重新提交申请
+	PermAssetManage    = "asset:manage"    // 管理他人的申请（删除等）
+	PermApprovalReview = "approval:review" // 审批申请
+	PermWorkflowManage = "workflow:manage" // 配置审批流程
+	PermFeeView        = "fee:view"        // 查看费用及统计
+	PermFeeManage      = "fee:manage"      // 管理费用
+	PermRouteManage    = "route:manage"    // 管理接口状态
+	PermRoleManage     = "role:manage"     // 分配角色
+	PermUserManage     = "user:manage"     // 账号解锁、查看登录历史
+)
+
+// Role 角色表
+type Role struct {
+	ID   int    `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	Code string `json:"code" gorm:"type:varchar(50);uniqueIndex;comment:角色代码"`
+	Name string `json:"name" gorm:"type:varchar(100);comment:角色名称"`
+
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;joinForeignKey:RoleID;joinReferences:PermissionID"`
+}
+
+// Permission 权限表
+type Permission struct {
+	ID   int    `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	Code string `json:"code" gorm:"type:varchar(50);uniqueIndex;comment:权限代码"`
+	Name string `json:"name" gorm:"type:varchar(100);comment:权限名称"`
+}
+
+// RolePermission 角色-权限关联表
+type RolePermission struct {
+	RoleID       int `json:"role_id" gorm:"primaryKey;type:bigint;comment:角色ID"`
+	PermissionID int `json:"permission_id" gorm:"primaryKey;type:bigint;comment:权限ID"`
+}
+
+// UserRole 用户-角色关联表
+type UserRole struct {
+	UserID int `json:"user_id" gorm:"primaryKey;type:bigint;comment:用户ID"`
+	RoleID int `json:"role_id" gorm:"primaryKey;type:bigint;comment:角色ID"`
+}
+
+// RBACDB 全局数据库连接实例
+var RBACDB *gorm.DB = utils.DB
+
+// defaultPermissions 内置权限
+var defaultPermissions = []Permission{
+	{Code: PermAssetApply, Name: "提交申请"},
+	{Code: PermAssetManage, Name: "管理申请"},
+	{Code: PermApprovalReview, Name: "审批申请"},
+	{Code: PermWorkflowManage, Name: "配置审批流程"},
+	{Code: PermFeeView, Name: "查看费用"},
+	{Code: PermFeeManage, Name: "管理费用"},
+	{Code: PermRouteManage, Name: "管理接口"},
+	{Code: PermRoleManage, Name: "分配角色"},
+	{Code: PermUserManage, Name: "管理账号安全"},
+}
+
+// defaultRoles 内置角色及其权限
+var defaultRoles = []struct {
+	Code        string
+	Name        string
+	Permissions []string
+}{
+	{RoleApplicant, "申请人", []string{PermAssetApply}},
+	{RoleDeptReviewer, "部门审核人", []string{PermAssetApply, PermApprovalReview}},
+	{RoleIPOfficer, "知识产权办公室", []string{PermAssetApply, PermApprovalReview, PermFeeView}},
+	{RoleLegalReviewer, "法务审核人", []string{PermAssetApply, PermApprovalReview}},
+	{RoleFinance, "财务", []string{PermFeeView, PermFeeManage}},
+	{RoleSystemAdmin, "系统管理员", []string{
+		PermAssetApply, PermAssetManage, PermApprovalReview, PermWorkflowManage,
+		PermFeeView, PermFeeManage, PermRouteManage, PermRoleManage, PermUserManage,
+	}},
+}
+
+// InitRBAC 初始化角色和权限
+// 1. 补齐缺失的内置权限、角色和角色权限
+// 2. 还没有任何角色的老用户按 Authority 字段迁移：admin -> 系统管理员，其余 -> 申请人
+func InitRBAC() error {
+	return RBACDB.Transaction(func(tx *gorm.DB) error {
+		perms := make(map[string]int)
+		for _, p := range defaultPermissions {
+			perm := p
+			if err := tx.Where("code = ?", perm.Code).FirstOrCreate(&perm).Error; err != nil {
+				return err
+			}
+			perms[perm.Code] = perm.ID
+		}
+
+		for _, r := range defaultRoles {
+			role := Role{Code: r.Code, Name: r.Name}
+			if err := tx.Where("code = ?", role.Code).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+			for _, code := range r.Permissions {
+				rp := RolePermission{RoleID: role.ID, PermissionID: perms[code]}
+				if err := tx.Where(&rp).FirstOrCreate(&rp).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		// 迁移老用户
+		var users []User
+		if err := tx.Select("id", "authority").
+			Where("id NOT IN (?)", tx.Model(&UserRole{}).Select("user_id")).
+			Find(&users).Error; err != nil {
+			return err
+		}
+		for _, u := range users {
+			code := RoleApplicant
+			if u.Authority == "admin" {
+				code = RoleSystemAdmin
+			}
+			if err := assignRole(tx, u.ID, code); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetAllRoles 获取所有角色及其权限
+func GetAllRoles() ([]Role, error) {
+	var roles []Role
+	if err := RBACDB.Preload("Permissions").Order("id ASC").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetAllPermissions 获取所有权限
+func GetAllPermissions() ([]Permission, error) {
+	var perms []Permission
+	if err := RBACDB.Order("id ASC").Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	return perms, nil
+}
+
+// GetUserRoles 获取用户的角色代码
+func GetUserRoles(userID int) ([]string, error) {
+	var codes []string
+	if err := RBACDB.Table("user_roles").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Pluck("roles.code", &codes).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// AssignRole 给用户分配角色
+func AssignRole(userID int, roleCode string) error {
+	return assignRole(RBACDB, userID, roleCode)
+}
+
+// assignRole 给用户分配角色，已分配时不重复写入
+func assignRole(tx *gorm.DB, userID int, roleCode string) error {
+	var role Role
+	if err := tx.Where("code = ?", roleCode).Limit(1).Find(&role).Error; err != nil {
+		return err
+	}
+	if role.ID == 0 {
+		return errors.New("角色不存在：" + roleCode)
+	}
+	ur := UserRole{UserID: userID, RoleID: role.ID}
+	return tx.Where(&ur).FirstOrCreate(&ur).Error
+}
+
+// RevokeRole 撤销用户的角色
+func RevokeRole(userID int, roleCode string) error {
+	return RBACDB.Where("user_id = ? AND role_id IN (?)", userID,
+		RBACDB.Model(&Role{}).Select("id").Where("code = ?", roleCode)).
+		Delete(&UserRole{}).Error
+}
+
+// UserHasPermission 判断用户是否拥有指定权限
+func UserHasPermission(userID int, permission string) (bool, error) {
+	var count int64
+	if err := RBACDB.Table("user_roles").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("user_roles.user_id = ? AND permissions.code = ?", userID, permission).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// userHasRole 判断用户是否拥有指定角色，系统管理员视为拥有所有角色
+func userHasRole(tx *gorm.DB, userID int, role string) (bool, error) {
+	var count int64
+	if err := tx.Table("user_roles").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id = ? AND roles.code IN ?", userID, []string{role, RoleSystemAdmin}).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,306 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"io"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Receipt 缴费收据
+// 每笔已支付的费用对应一张收据，PDF 文件保存在资产附件目录的 receipts 子目录下
+type Receipt struct {
+	ID                int       `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	ReceiptNo         string    `json:"receipt_no" gorm:"type:varchar(32);uniqueIndex;comment:收据编号"`
+	AssetType         string    `json:"asset_type" gorm:"type:varchar(20);uniqueIndex:idx_receipt_fee;comment:资产类型"`
+	FeeID             int       `json:"fee_id" gorm:"type:bigint;uniqueIndex:idx_receipt_fee;comment:费用ID"`
+	AssetID           int       `json:"asset_id" gorm:"type:bigint;comment:资产ID"`
+	OrderNo           string    `json:"order_no" gorm:"type:varchar(64);comment:支付订单号"`
+	PayerID           int       `json:"payer_id" gorm:"type:bigint;comment:缴费人ID"`
+	PayerName         string    `json:"payer_name" gorm:"type:varchar(100);comment:缴费人姓名"`
+	ApplicationNumber string    `json:"application_number" gorm:"type:varchar(255);comment:申请号"`
+	AssetTitle        string    `json:"asset_title" gorm:"type:varchar(255);comment:资产名称"`
+	FeeName           string    `json:"fee_name" gorm:"type:varchar(100);comment:费用名称"`
+	Amount            float64   `json:"amount" gorm:"type:decimal(10,2);comment:金额"`
+	Channel           string    `json:"channel" gorm:"type:varchar(20);comment:支付渠道"`
+	TradeNo           string    `json:"trade_no" gorm:"type:varchar(64);comment:渠道交易号"`
+	PaidAt            time.Time `json:"paid_at" gorm:"type:datetime;comment:支付时间"`
+	FilePath          string    `json:"file_path" gorm:"type:varchar(255);comment:PDF文件存储路径"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ReceiptDB 全局数据库连接实例
+var ReceiptDB *gorm.DB = utils.DB
+
+// 收据相关错误
+var (
+	ErrReceiptFeeNotPaid  = errors.New("费用未支付，无法开具收据")
+	ErrReceiptFeeRefunded = errors.New("费用已退款，无法开具收据")
+)
+
+// 收据标题中的资产类型名称
+var receiptAssetNames = map[string]string{
+	AssetTypePatent:    "专利",
+	AssetTypeArticle:   "著作",
+	AssetTypeTrademark: "商标",
+}
+
+// receiptKey 收据 PDF 在存储中的路径
+func receiptKey(receipt *Receipt) string {
+	return AssetFilePrefix(receipt.AssetType, receipt.ApplicationNumber) + "receipts/" + receipt.ReceiptNo + ".pdf"
+}
+
+// FeeDisplayName 费用名称，例如审核费、第3年年费、第1次续展费
+func FeeDisplayName(feeKind string, year int) string {
+	switch feeKind {
+	case FeeKindAnnuity:
+		return fmt.Sprintf("第%d年年费", year)
+	case FeeKindRenewal:
+		return fmt.Sprintf("第%d次续展费", year)
+	}
+	return "审核费"
+}
+
+// CheckReceiptFee 判断费用能否开具收据：费用必须已支付，且入账的订单没有发生过退款
+// 退款后收据金额与实收不符，已开具的收据也不再提供下载
+func CheckReceiptFee(isPaid bool, status int, refundedAmount float64) error {
+	if status == FeeStatusRefunded || toCents(refundedAmount) > 0 {
+		return ErrReceiptFeeRefunded
+	}
+	if !isPaid || status != FeeStatusPaid {
+		return ErrReceiptFeeNotPaid
+	}
+	return nil
+}
+
+// checkReceiptFee 查询费用当前的支付和退款情况，判断能否开具收据
+func checkReceiptFee(assetType string, feeID int) error {
+	meta, err := getFeeMeta(assetType)
+	if err != nil {
+		return err
+	}
+	var fee struct {
+		IsPaid bool
+		Status int
+	}
+	if err := ReceiptDB.Table(meta.Table).Select("is_paid", "status").Where("id = ?", feeID).Take(&fee).Error; err != nil {
+		return err
+	}
+	var refunded float64
+	if err := ReceiptDB.Model(&PaymentOrder{}).
+		Select("COALESCE(SUM(refunded_amount), 0)").
+		Where("asset_type = ? AND fee_id = ? AND state IN ? AND remark = ''", assetType, feeID,
+			[]string{PaymentStatePaid, PaymentStateRefunded}).
+		Scan(&refunded).Error; err != nil {
+		return err
+	}
+	return CheckReceiptFee(fee.IsPaid, fee.Status, refunded)
+}
+
+// IssueReceipt 为已支付的费用开具收据，已开具时直接返回原收据
+// PDF 文件丢失时按数据库中的记录重新生成，收据编号和内容不变
+// 每次都重新核对费用状态，费用退款后不再返回收据
+func IssueReceipt(assetType string, feeID int) (*Receipt, error) {
+	if err := checkReceiptFee(assetType, feeID); err != nil {
+		return nil, err
+	}
+	var receipt Receipt
+	err := ReceiptDB.Where("asset_type = ? AND fee_id = ?", assetType, feeID).Take(&receipt).Error
+	if err == nil {
+		rc, getErr := utils.Store.Get(context.Background(), receipt.FilePath)
+		if getErr == nil {
+			rc.Close()
+			return &receipt, nil
+		}
+		// 迁移前保存的是本地磁盘路径，重新生成时改为存储路径
+		if key := receiptKey(&receipt); receipt.FilePath != key {
+			receipt.FilePath = key
+			if err := ReceiptDB.Model(&receipt).Update("file_path", key).Error; err != nil {
+				return nil, err
+			}
+		}
+		if err := writeReceiptPDF(&receipt); err != nil {
+			return nil, err
+		}
+		return &receipt, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err := buildReceipt(assetType, feeID, &receipt); err != nil {
+		return nil, err
+	}
+	err = ReceiptDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&receipt).Error; err != nil {
+			return err
+		}
+		// 收据编号：R + 支付日期 + 收据ID，保证唯一且便于按日期查找
+		receipt.ReceiptNo = fmt.Sprintf("R%s%06d", receipt.PaidAt.Format("20060102"), receipt.ID)
+		receipt.FilePath = receiptKey(&receipt)
+		if err := tx.Model(&receipt).Updates(map[string]interface{}{
+			"receipt_no": receipt.ReceiptNo,
+			"file_path":  receipt.FilePath,
+		}).Error; err != nil {
+			return err
+		}
+		return writeReceiptPDF(&receipt)
+	})
+	if isDuplicateKey(ReceiptDB, err) {
+		// 并发开具同一笔费用的收据，另一个请求已经开具，返回已有的收据
+		var existing Receipt
+		if err := ReceiptDB.Where("asset_type = ? AND fee_id = ?", assetType, feeID).Take(&existing).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// isDuplicateKey 是否为违反唯一索引的错误
+func isDuplicateKey(db *gorm.DB, err error) bool {
+	if err == nil {
+		return false
+	}
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// buildReceipt 从费用、支付订单和资产中收集收据内容
+func buildReceipt(assetType string, feeID int, receipt *Receipt) error {
+	meta, err := getFeeMeta(assetType)
+	if err != nil {
+		return err
+	}
+	asset, err := getAssetMeta(assetType)
+	if err != nil {
+		return err
+	}
+	var fee struct {
+		AssetID     int
+		ReviewFee   float64
+		Surcharge   float64
+		FeeKind     string
+		Year        int
+		PaymentDate time.Time
+	}
+	if err := ReceiptDB.Table(meta.Table).
+		Select(meta.AssetKey+" AS asset_id", "review_fee", "surcharge", "fee_kind", "year", "payment_date").
+		Where("id = ?", feeID).
+		Take(&fee).Error; err != nil {
+		return err
+	}
+	var assetInfo struct {
+		ApplicationNumber string
+		Title             string
+		FirstAuthorID     int
+	}
+	if err := ReceiptDB.Table(asset.Table).
+		Select("application_number", "title", "first_author_id").
+		Where("id = ?", fee.AssetID).
+		Take(&assetInfo).Error; err != nil {
+		return err
+	}
+
+	*receipt = Receipt{
+		AssetType:         assetType,
+		FeeID:             feeID,
+		AssetID:           fee.AssetID,
+		PayerID:           assetInfo.FirstAuthorID,
+		ApplicationNumber: assetInfo.ApplicationNumber,
+		AssetTitle:        assetInfo.Title,
+		FeeName:           FeeDisplayName(fee.FeeKind, fee.Year),
+		Amount:            float64(toCents(fee.ReviewFee)+toCents(fee.Surcharge)) / 100,
+		PaidAt:            fee.PaymentDate,
+	}
+	if fee.Surcharge > 0 {
+		receipt.FeeName += fmt.Sprintf("(含滞纳金%s元)", utils.FormatAmount(fee.Surcharge))
+	}
+
+	// 取实际入账的支付订单，重复支付的订单带有备注，不作为收据依据
+	var orders []PaymentOrder
+	if err := ReceiptDB.Where("asset_type = ? AND fee_id = ? AND state = ? AND remark = ''", assetType, feeID, PaymentStatePaid).
+		Order("paid_at DESC").Limit(1).Find(&orders).Error; err != nil {
+		return err
+	}
+	if len(orders) > 0 {
+		order := orders[0]
+		receipt.OrderNo = order.OrderNo
+		receipt.Channel = order.Channel
+		receipt.TradeNo = order.TradeNo
+		receipt.Amount = order.Amount
+		if order.PayerID != 0 {
+			receipt.PayerID = order.PayerID
+		}
+		if order.PaidAt != nil {
+			receipt.PaidAt = *order.PaidAt
+		}
+	}
+	var payer User
+	if err := ReceiptDB.Select("id", "user_name").Where("id = ?", receipt.PayerID).Take(&payer).Error; err != nil {
+		return err
+	}
+	receipt.PayerName = payer.UserName
+	return nil
+}
+
+// writeReceiptPDF 生成收据 PDF 并写入存储
+func writeReceiptPDF(receipt *Receipt) error {
+	data := RenderReceiptPDF(receipt)
+	return utils.Store.Put(context.Background(), receipt.FilePath, bytes.NewReader(data), int64(len(data)), "application/pdf")
+}
+
+// OpenReceipt 读取收据 PDF，调用方负责关闭
+func OpenReceipt(receipt *Receipt) (io.ReadCloser, error) {
+	return utils.Store.Get(context.Background(), receipt.FilePath)
+}
+
+// RenderReceiptPDF 渲染收据 PDF
+func RenderReceiptPDF(receipt *Receipt) []byte {
+	doc := utils.NewPDFDoc()
+	const left, right = 72.0, utils.PDFPageWidth - 72
+	title := receiptAssetNames[receipt.AssetType] + "费用缴费收据"
+	doc.Text((utils.PDFPageWidth-utils.TextWidth(title, 20))/2, 760, 20, title)
+	doc.Text(left, 720, 10, "收据编号："+receipt.ReceiptNo)
+	doc.Line(left, 710, right, 710, 1)
+
+	channel := receipt.Channel
+	switch channel {
+	case utils.PaymentChannelAlipay:
+		channel = "支付宝"
+	case utils.PaymentChannelWechat:
+		channel = "微信支付"
+	case utils.PaymentChannelOffline:
+		channel = "线下转账"
+	}
+	rows := [][2]string{
+		{"缴费人", receipt.PayerName},
+		{"申请号", receipt.ApplicationNumber},
+		{"名称", receipt.AssetTitle},
+		{"费用类型", receipt.FeeName},
+		{"金额(元)", utils.FormatAmount(receipt.Amount)},
+		{"支付时间", receipt.PaidAt.Format("2006-01-02 15:04:05")},
+		{"支付方式", channel},
+		{"交易号", receipt.TradeNo},
+		{"订单号", receipt.OrderNo},
+	}
+	y := 680.0
+	for _, row := range rows {
+		doc.Text(left, y, 12, row[0])
+		doc.Text(left+100, y, 12, row[1])
+		y -= 28
+	}
+	doc.Line(left, y+10, right, y+10, 0.5)
+	doc.Text(left, y-16, 9, "本收据由系统根据支付记录自动生成，可凭收据编号核验。开具时间："+receipt.CreatedAt.Format("2006-01-02 15:04:05"))
+	return doc.Bytes()
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 重新提交相关错误
+var (
+	ErrNotRejected    = errors.New("只有被驳回的申请才能重新提交")
+	ErrNotFirstAuthor = errors.New("只有第一作者才能重新提交")
+)
+
+// ResubmitInput 重新提交时可修改的内容
+type ResubmitInput struct {
+	Title         string
+	Abstract      string
+	AuthorIDs     []int
+	FirstAuthorID int
+	Attachments   []StagedAttachment // 本次新上传、已写入存储的附件，在同一事务中登记
+}
+
+// fieldChange 单个字段的变更前后值
+type fieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// resubmitDetail 重新提交事件的详情，供审核人查看修改内容
+type resubmitDetail struct {
+	Revision    int                    `json:"revision"`
+	Changes     map[string]fieldChange `json:"changes"`
+	Attachments []string               `json:"attachments,omitempty"`
+}
+
+// ResubmitResult 重新提交结果
+type ResubmitResult struct {
+	Revision          int    `json:"revision"`
+	ApplicationNumber string `json:"application_number"`
+	AttachmentUrl     string `json:"attachment_url"`
+}
+
+// ResubmitAsset 重新提交被驳回的资产
+// 在同一事务中：
+// 1. 校验资产处于驳回状态，且操作人是第一作者
+// 2. 更新标题、摘要、作者，申请号保持不变
+// 3. 审批环节重置到第一个环节，版本号加1
+// 4. 登记新上传的附件
+// 5. 记录包含变更内容的重新提交事件
+func ResubmitAsset(assetType string, assetID int, actorID int, input ResubmitInput) (ResubmitResult, error) {
+	meta, err := getAssetMeta(assetType)
+	if err != nil {
+		return ResubmitResult{}, err
+	}
+	if !contains(input.AuthorIDs, input.FirstAuthorID) {
+		return ResubmitResult{}, errors.New("第一作者必须包含在作者列表中")
+	}
+
+	var result ResubmitResult
+	err = ApprovalDB.Transaction(func(tx *gorm.DB) error {
+		var current struct {
+			Title             string
+			Abstract          string
+			FirstAuthorID     int
+			ApprovalStatus    int
+			Revision          int
+			ApplicationNumber string
+			AttachmentUrl     string
+		}
+		if err := tx.Table(meta.Table).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("title", "abstract", "first_author_id", "approval_status", "revision", "application_number", "attachment_url").
+			Where("id = ?", assetID).
+			Take(&current).Error; err != nil {
+			return err
+		}
+		if current.ApprovalStatus != ApprovalStatusRejected {
+			return ErrNotRejected
+		}
+		if current.FirstAuthorID != actorID {
+			return ErrNotFirstAuthor
+		}
+
+		var oldAuthors []int
+		if err := tx.Table(meta.AuthorTable).
+			Where(meta.AuthorKey+" = ?", assetID).
+			Pluck("user_id", &oldAuthors).Error; err != nil {
+			return err
+		}
+
+		// 记录变更内容
+		changes := make(map[string]fieldChange)
+		if current.Title != input.Title {
+			changes["title"] = fieldChange{Old: current.Title, New: input.Title}
+		}
+		if current.Abstract != input.Abstract {
+			changes["abstract"] = fieldChange{Old: current.Abstract, New: input.Abstract}
+		}
+		if current.FirstAuthorID != input.FirstAuthorID {
+			changes["first_author_id"] = fieldChange{Old: current.FirstAuthorID, New: input.FirstAuthorID}
+		}
+		newAuthors := uniqueSorted(input.AuthorIDs)
+		oldAuthors = uniqueSorted(oldAuthors)
+		if !equalInts(oldAuthors, newAuthors) {
+			changes["authors"] = fieldChange{Old: oldAuthors, New: newAuthors}
+		}
+
+		revision := current.Revision + 1
+		if err := tx.Table(meta.Table).Where("id = ?", assetID).Updates(map[string]interface{}{
+			"title":           input.Title,
+			"abstract":        input.Abstract,
+			"first_author_id": input.FirstAuthorID,
+			"current_step":    ApprovalStepInitial,
+			"approval_status": ApprovalStatusInProgress,
+			"revision":        revision,
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		// 作者整体替换
+		if err := tx.Table(meta.AuthorTable).Where(meta.AuthorKey+" = ?", assetID).Delete(map[string]interface{}{}).Error; err != nil {
+			return err
+		}
+		authors := make([]map[string]interface{}, 0, len(newAuthors))
+		for _, uid := range newAuthors {
+			authors = append(authors, map[string]interface{}{
+				meta.AuthorKey:    assetID,
+				"user_id":         uid,
+				"is_first_author": uid == input.FirstAuthorID,
+			})
+		}
+		if err := tx.Table(meta.AuthorTable).Create(authors).Error; err != nil {
+			return err
+		}
+
+		// 新附件保存在原申请号目录下
+		if _, err := registerAttachments(tx, assetType, assetID, actorID, input.Attachments); err != nil {
+			return err
+		}
+		var fileNames []string
+		for _, a := range input.Attachments {
+			fileNames = append(fileNames, a.Name)
+		}
+
+		detail, err := json.Marshal(resubmitDetail{
+			Revision:    revision,
+			Changes:     changes,
+			Attachments: fileNames,
+		})
+		if err != nil {
+			return err
+		}
+		if err := RecordApprovalEvent(tx, &ApprovalEvent{
+			AssetType: assetType,
+			AssetID:   assetID,
+			EventType: ApprovalEventResubmit,
+			Revision:  revision,
+			Step:      ApprovalStepInitial,
+			ActorID:   actorID,
+			Detail:    string(detail),
+		}); err != nil {
+			return err
+		}
+
+		result = ResubmitResult{
+			Revision:          revision,
+			ApplicationNumber: current.ApplicationNumber,
+			AttachmentUrl:     current.AttachmentUrl,
+		}
+		return nil
+	})
+	return result, err
+}
+
+// uniqueSorted 去重并排序，便于比较作者列表
+func uniqueSorted(s []int) []int {
+	seen := make(map[int]bool, len(s))
+	out := make([]int, 0, len(s))
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// equalInts 比较两个整型切片是否相同
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
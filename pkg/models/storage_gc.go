@@ -0,0 +1,167 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"strings"
+	"time"
+)
+
+// 存储清理发现的问题类型
+const (
+	GarbageOrphanDir   = "orphan_dir"   // 申请号目录没有对应的申请
+	GarbageOrphanFile  = "orphan_file"  // 附件或收据目录下没有记录引用的文件
+	GarbageMissingFile = "missing_file" // 附件记录对应的文件不存在
+	GarbageFailedTask  = "failed_task"  // 超过重试次数仍未完成的存储任务
+)
+
+// StorageGarbage 存储与数据库不一致的一项
+type StorageGarbage struct {
+	Kind   string
+	Key    string
+	Detail string
+	Task   *StorageTask // 仅 failed_task 使用
+}
+
+// PlanStorageGC 找出存储中的孤儿目录和孤儿文件
+// objects 为资产类型目录下的全部文件，assetDirs 为现有申请的目录，referenced 为附件和收据记录引用的路径。
+// 修改时间在 minAge 以内的文件不处理，这些文件可能属于正在创建、尚未提交事务的申请；
+// 申请号目录下顶层的旧版附件在查询附件时才会登记，也不处理
+func PlanStorageGC(objects []utils.StorageObject, assetDirs map[string]bool, referenced map[string]bool, minAge time.Duration, now time.Time) []StorageGarbage {
+	type dirStat struct {
+		files  int
+		newest time.Time
+	}
+	var dirs []string
+	stats := make(map[string]*dirStat)
+	var garbage []StorageGarbage
+	for _, obj := range objects {
+		parts := strings.SplitN(obj.Key, "/", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		if _, err := getAssetMeta(parts[0]); err != nil {
+			continue
+		}
+		dir := AssetFilePrefix(parts[0], parts[1])
+		if !assetDirs[dir] {
+			st, ok := stats[dir]
+			if !ok {
+				st = &dirStat{}
+				stats[dir] = st
+				dirs = append(dirs, dir)
+			}
+			st.files++
+			if obj.ModTime.After(st.newest) {
+				st.newest = obj.ModTime
+			}
+			continue
+		}
+		if !strings.HasPrefix(parts[2], "attachments/") && !strings.HasPrefix(parts[2], "receipts/") {
+			continue
+		}
+		if referenced[obj.Key] || now.Sub(obj.ModTime) < minAge {
+			continue
+		}
+		garbage = append(garbage, StorageGarbage{Kind: GarbageOrphanFile, Key: obj.Key, Detail: "没有附件或收据记录引用"})
+	}
+	orphanDirs := make([]StorageGarbage, 0, len(dirs))
+	for _, dir := range dirs {
+		st := stats[dir]
+		if now.Sub(st.newest) < minAge {
+			continue
+		}
+		orphanDirs = append(orphanDirs, StorageGarbage{Kind: GarbageOrphanDir, Key: dir, Detail: fmt.Sprintf("没有对应的申请，%d 个文件", st.files)})
+	}
+	return append(orphanDirs, garbage...)
+}
+
+// CollectStorageGarbage 对比存储和数据库，列出孤儿目录、孤儿文件、缺失的附件文件和失败的存储任务
+// 收据 PDF 缺失时下载会重新生成，不在检查范围内
+func CollectStorageGarbage(ctx context.Context, minAge time.Duration) ([]StorageGarbage, error) {
+	now := time.Now()
+	// 先查询记录再列出文件：文件总是先于记录写入，已查到的记录对应的文件一定在列表中
+	referenced := make(map[string]bool)
+	var attachments []Attachment
+	if err := AttachmentDB.Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		referenced[a.StorageKey] = true
+	}
+	var receiptKeys []string
+	if err := ReceiptDB.Model(&Receipt{}).Where("file_path <> ''").Pluck("file_path", &receiptKeys).Error; err != nil {
+		return nil, err
+	}
+	for _, key := range receiptKeys {
+		referenced[key] = true
+	}
+
+	assetDirs := make(map[string]bool)
+	var objects []utils.StorageObject
+	for _, assetType := range []string{AssetTypePatent, AssetTypeArticle, AssetTypeTrademark} {
+		meta, err := getAssetMeta(assetType)
+		if err != nil {
+			return nil, err
+		}
+		var numbers []string
+		if err := AttachmentDB.Table(meta.Table).Where("application_number <> ''").
+			Pluck("application_number", &numbers).Error; err != nil {
+			return nil, err
+		}
+		for _, n := range numbers {
+			assetDirs[AssetFilePrefix(assetType, n)] = true
+		}
+		list, err := utils.Store.List(ctx, assetType+"/")
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, list...)
+	}
+
+	garbage := PlanStorageGC(objects, assetDirs, referenced, minAge, now)
+
+	existing := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		existing[obj.Key] = true
+	}
+	for _, a := range attachments {
+		if !existing[a.StorageKey] {
+			garbage = append(garbage, StorageGarbage{
+				Kind:   GarbageMissingFile,
+				Key:    a.StorageKey,
+				Detail: fmt.Sprintf("%s %d 的附件 %d %s 第%d版", a.OwnerType, a.OwnerID, a.ID, a.Name, a.Version),
+			})
+		}
+	}
+
+	var tasks []StorageTask
+	if err := StorageTaskDB.Where("done_at IS NULL AND attempts >= ?", maxStorageTaskAttempts).
+		Order("id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		garbage = append(garbage, StorageGarbage{
+			Kind:   GarbageFailedTask,
+			Key:    tasks[i].Key,
+			Detail: fmt.Sprintf("任务 %d 已执行 %d 次: %s", tasks[i].ID, tasks[i].Attempts, tasks[i].LastError),
+			Task:   &tasks[i],
+		})
+	}
+	return garbage, nil
+}
+
+// FixStorageGarbage 删除孤儿目录和孤儿文件，重新执行失败的存储任务
+// 缺失的附件文件无法自动修复，返回 false
+func FixStorageGarbage(ctx context.Context, g StorageGarbage) (bool, error) {
+	switch g.Kind {
+	case GarbageOrphanDir:
+		return true, utils.DeletePrefix(ctx, utils.Store, g.Key)
+	case GarbageOrphanFile:
+		return true, utils.Store.Delete(ctx, g.Key)
+	case GarbageFailedTask:
+		return true, runStorageTask(ctx, g.Task)
+	}
+	return false, nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 存储任务类型
+const (
+	StorageTaskDelete       = "delete"        // 删除单个文件
+	StorageTaskDeletePrefix = "delete_prefix" // 删除目录下的全部文件
+)
+
+// 存储任务最多重试次数，超过后不再自动执行，由 gc 命令报告
+const maxStorageTaskAttempts = 20
+
+// StorageTask 存储操作的发件箱
+// 删除记录时在同一事务中登记要删除的文件，提交后再执行；执行失败的任务由定时任务重试，
+// 保证数据库记录删除后文件最终也会被删除
+type StorageTask struct {
+	ID        int        `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	Action    string     `json:"action" gorm:"type:varchar(20);comment:任务类型"`
+	Key       string     `json:"key" gorm:"type:varchar(512);comment:文件或目录的存储路径"`
+	Attempts  int        `json:"attempts" gorm:"type:int;comment:已执行次数"`
+	LastError string     `json:"last_error" gorm:"type:varchar(500);comment:最近一次失败原因"`
+	DoneAt    *time.Time `json:"done_at" gorm:"type:datetime;index;comment:完成时间"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// StorageTaskDB 全局数据库连接实例
+var StorageTaskDB *gorm.DB = utils.DB
+
+// enqueueStorageTask 在事务中登记存储任务
+func enqueueStorageTask(tx *gorm.DB, action string, key string) (*StorageTask, error) {
+	task := StorageTask{Action: action, Key: key}
+	if err := tx.Create(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// runStorageTask 执行单个存储任务并记录结果，删除是幂等的，多个实例重复执行不影响结果
+func runStorageTask(ctx context.Context, task *StorageTask) error {
+	var err error
+	switch task.Action {
+	case StorageTaskDelete:
+		err = utils.Store.Delete(ctx, task.Key)
+	case StorageTaskDeletePrefix:
+		err = utils.DeletePrefix(ctx, utils.Store, task.Key)
+	default:
+		err = fmt.Errorf("未知的存储任务类型: %s", task.Action)
+	}
+	updates := map[string]interface{}{"attempts": gorm.Expr("attempts + 1")}
+	if err != nil {
+		msg := err.Error()
+		if len(msg) > 500 {
+			msg = msg[:500]
+		}
+		updates["last_error"] = msg
+	} else {
+		updates["done_at"] = time.Now()
+	}
+	if dbErr := StorageTaskDB.Model(&StorageTask{}).Where("id = ? AND done_at IS NULL", task.ID).
+		Updates(updates).Error; dbErr != nil {
+		return dbErr
+	}
+	return err
+}
+
+// runStorageTasksNow 事务提交后立即执行本次登记的任务，失败的留给定时任务重试
+func runStorageTasksNow(tasks []*StorageTask) {
+	for _, task := range tasks {
+		if err := runStorageTask(context.Background(), task); err != nil {
+			utils.Logger.Error(fmt.Sprintf("存储任务 %d 执行失败，稍后重试: %v", task.ID, err))
+		}
+	}
+}
+
+// RunStorageTaskJob 定时重试未完成的存储任务
+func RunStorageTaskJob(ctx context.Context) error {
+	var tasks []StorageTask
+	if err := StorageTaskDB.Where("done_at IS NULL AND attempts < ?", maxStorageTaskAttempts).
+		Order("id").Limit(500).Find(&tasks).Error; err != nil {
+		return err
+	}
+	failed := 0
+	for i := range tasks {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := runStorageTask(ctx, &tasks[i]); err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		utils.Logger.Warn(fmt.Sprintf("存储任务: 执行 %d 个, 失败 %d 个", len(tasks), failed))
+	}
+	return nil
+}
@@ -3,11 +3,6 @@ package models
 import (
 	"errors"
 	"intellectual_property/pkg/utils"
-	"log"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -29,20 +24,14 @@ type Trademark struct {
 	Authors       []TrademarkAuthor `json:"authors" gorm:"foreignKey:TrademarkID;comment:所有作者关联记录"`
 
 	// 审批流程字段
-	CurrentStep    int `json:"current_step" gorm:"type:int;comment:当前审批步骤(1=初审,2=终审)"`
+	CurrentStep    int `json:"current_step" gorm:"type:int;comment:当前审批步骤(对应审批流程中的环节序号)"`
 	ApprovalStatus int `json:"approval_status" gorm:"type:int;comment:整体审批状态(0=进行中,1=通过,2=驳回)"`
+	Revision       int `json:"revision" gorm:"type:int;default:1;comment:提交版本号(驳回后每次重新提交加1)"`
 
-	// 初审相关字段
-	InitialReviewerID int       `json:"initial_reviewer_id" gorm:"type:bigint;comment:初审人ID"`
-	InitialComment    string    `json:"initial_comment" gorm:"type:text;comment:初审意见"`
-	InitialSubmitTime time.Time `json:"initial_submit_time" gorm:"type:datetime;comment:初审提交时间"`
-	InitialStatus     bool      `json:"initial_status" gorm:"comment:初审状态"`
-
-	// 终审相关字段
-	FinalReviewerID int       `json:"final_reviewer_id" gorm:"type:bigint;comment:终审人ID"`
-	FinalComment    string    `json:"final_comment" gorm:"type:text;comment:终审意见"`
-	FinalSubmitTime time.Time `json:"final_submit_time" gorm:"type:datetime;comment:终审提交时间"`
-	FinalStatus     bool      `json:"final_status" gorm:"comment:终审状态"`
+	// 费用减缴代码，为空表示不减缴
+	DiscountCode string `json:"discount_code" gorm:"type:varchar(50);comment:费用减缴代码"`
+	// 授权后的费用宽限期满仍未缴纳时失效
+	LapsedAt *time.Time `json:"lapsed_at" gorm:"type:datetime;comment:失效日期(为空表示有效)"`
 
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
@@ -89,24 +78,14 @@ func NewTrademark(
 			IsFirstAuthor: uid == firstAuthorID,
 		})
 	}
-	// 初始化年费对象
-	var reviewFee float64
-	switch trademarkType {
-	case PracticalInvention:
-		reviewFee = 1
-	case PatentInvention:
-		reviewFee = 2
-	case AppearanceDesign:
-		reviewFee = 1
-	}
-
+	// 初始化年费对象，审核费金额在创建时按费用标准计算
 	patentFee := &TrademarkFee{
 		TrademarkID:     0, // 后续在创建专利时更新
-		ReviewFee:       reviewFee,
 		IsPaid:          false,
 		CreatedAt:       time.Now(),
 		PaymentDeadline: time.Now().AddDate(0, 1, 0), //一个月
 		Status:          0,
+		FeeKind:         FeeKindReview,
 	}
 	return &Trademark{
 		TrademarkType:  trademarkType,
@@ -115,8 +94,9 @@ func NewTrademark(
 		FirstAuthorID:  firstAuthorID,
 		Authors:        authors,
 		ApplyDate:      time.Now(),
-		CurrentStep:    1,
+		CurrentStep:    ApprovalStepInitial,
 		ApprovalStatus: 0,
+		Revision:       1,
 	}, patentFee, nil
 }
 
@@ -125,8 +105,17 @@ func NewTrademark(
 // 1. 创建主记录
 // 2. 创建作者关联记录
 // 3. 更新第一作者外键
-func (trademark *Trademark) CreateTrademarkService(fee *TrademarkFee) error {
+// 4. 登记已写入存储的附件
+// 5. 记录提交申请和生成费用事件
+func (trademark *Trademark) CreateTrademarkService(fee *TrademarkFee, submitterID int, attachments []StagedAttachment) error {
 	return TrademarkDB.Transaction(func(tx *gorm.DB) error {
+		// 按当前费用标准和减缴政策计算审核费
+		reviewFee, err := QuoteReviewFee(tx, AssetTypeTrademark, trademark.TrademarkType, trademark.DiscountCode)
+		if err != nil {
+			return err
+		}
+		fee.ReviewFee = reviewFee
+
 		// 创建主记录
 		if err := tx.Create(trademark).Error; err != nil {
 			return err
@@ -158,13 +147,14 @@ func (trademark *Trademark) CreateTrademarkService(fee *TrademarkFee) error {
 			return err
 		}
 
-		return nil
-	})
-}
+		// 登记已写入存储的附件
+		if _, err := registerAttachments(tx, AssetTypeTrademark, trademark.ID, submitterID, attachments); err != nil {
+			return err
+		}
 
-// UpdateTrademarkUrl 更新资源url
-func (trademark *Trademark) UpdateTrademarkUrl() error {
-	return TrademarkDB.Model(&Trademark{}).Where("id =?", trademark.ID).Select("attachment_url").Updates(trademark).Error
+		// 记录提交申请和生成费用事件
+		return recordSubmitEvents(tx, AssetTypeTrademark, trademark.ID, submitterID, fee.ID, fee.ReviewFee)
+	})
 }
 
 // GetAllTrademarks 获取所有商标及其关联信息
@@ -244,32 +234,9 @@ func GetAllTrademarks(
 	return trademarks, total, nil
 }
 
-// GetTrademarkFile 根据商标id来查询所有文件地址
-func GetTrademarkFile(id int) ([]string, error) {
-	//var ans []string
-	//var directory string
-	//directory = strconv.Itoa(id)
-	//baseurl := utils.NgX.LocationTrademark + "/" + directory
-	//dir, err := os.ReadDir(baseurl)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//for _, file := range dir {
-	//	ans = append(ans, "/trademark/"+directory+"/"+file.Name())
-	//}
-	var ans []string
-	var trademark Trademark
-	PatentDB.Model(&Trademark{}).Select("application_number,attachment_url").Where("id =?", id).Find(&trademark)
-
-	baseurl := utils.NgX.LocationTrademark + "/" + trademark.ApplicationNumber
-	dir, err := os.ReadDir(baseurl)
-	if err != nil {
-		return nil, err
-	}
-	for _, file := range dir {
-		ans = append(ans, "/trademark/"+trademark.ApplicationNumber+"/"+file.Name())
-	}
-	return ans, nil
+// GetTrademarkFile 查询商标附件的最新版本
+func GetTrademarkFile(id int) ([]Attachment, error) {
+	return ListAttachments(AssetTypeTrademark, id, false)
 }
 
 // DeleteTrademark 删除商标及其关联数据
@@ -280,13 +247,19 @@ func GetTrademarkFile(id int) ([]string, error) {
 //   - error 错误信息
 func DeleteTrademark(id int) error {
 	// 开启数据库事务
+	var task *StorageTask
 	err := TrademarkDB.Transaction(func(tx *gorm.DB) error {
 		// 1. 删除作者关联记录
 		if err := tx.Where("trademark_id = ?", id).Delete(&TrademarkAuthor{}).Error; err != nil {
 			return err
 		}
+		// 2. 删除附件记录，登记文件删除任务
+		var err error
+		if task, err = deleteAssetFiles(tx, AssetTypeTrademark, id); err != nil {
+			return err
+		}
 
-		// 2. 删除主记录（修正后的版本）
+		// 3. 删除主记录（修正后的版本）
 		if err := tx.Where("id = ?", id).Delete(&Trademark{}).Error; err != nil {
 			return err
 		}
@@ -294,31 +267,14 @@ func DeleteTrademark(id int) error {
 		return nil
 	})
 
-	// 3. 清理文件系统（在事务成功后执行）
-	if err == nil {
-		if cleanErr := cleanTrademarkFiles(id); cleanErr != nil {
-			log.Printf("文件清理失败: %v", cleanErr)
-		}
+	// 4. 清理文件系统（在事务成功后执行，失败的由定时任务重试）
+	if err == nil && task != nil {
+		runStorageTasksNow([]*StorageTask{task})
 	}
 
 	return err
 }
 
-// cleanTrademarkFiles 清理商标相关文件
-func cleanTrademarkFiles(id int) error {
-	// 构建安全路径
-	safePath := filepath.Join(utils.NgX.LocationTrademark, "/", strconv.Itoa(id))
-
-	// 验证路径是否在允许的根目录下（防止路径遍历攻击）
-	if !strings.HasPrefix(filepath.Clean(safePath)+string(filepath.Separator),
-		filepath.Clean(utils.NgX.LocationTrademark)+string(filepath.Separator)) {
-		return errors.New("非法路径")
-	}
-
-	// 删除目录及其内容
-	return os.RemoveAll(safePath)
-}
-
 // GetTrademarkById 根据id查询Trademark
 func GetTrademarkById(id int) (Trademark, error) {
 	var trademark Trademark
@@ -329,74 +285,12 @@ func GetTrademarkById(id int) (Trademark, error) {
 }
 
 // UpdateTrademarkStatus 更新状态
+// 审批流转统一交由审批引擎 ReviewAsset 处理
 func UpdateTrademarkStatus(
 	trademarkId int,
 	ReviewerID int,
 	Comment string,
 	Status int, // 0=驳回，1=通过
 ) error {
-	// 查询完整商标信息
-	trademark, err := GetTrademarkById(trademarkId)
-	if err != nil {
-		return err
-	}
-
-	now := time.Now()
-	updates := make(map[string]interface{})
-
-	if trademark.CurrentStep == ApprovalStepInitial {
-		// 初审逻辑
-		trademark.InitialReviewerID = ReviewerID
-		trademark.InitialComment = Comment
-		trademark.InitialSubmitTime = now
-		trademark.InitialStatus = Status == 1
-
-		if Status == 1 {
-			// 初审通过，进入终审
-			trademark.CurrentStep = ApprovalStepFinal
-		} else {
-			// 初审驳回
-			trademark.ApprovalStatus = ApprovalStatusRejected
-		}
-
-		updates = map[string]interface{}{
-			"current_step":        trademark.CurrentStep,
-			"approval_status":     trademark.ApprovalStatus,
-			"initial_reviewer_id": trademark.InitialReviewerID,
-			"initial_comment":     trademark.InitialComment,
-			"initial_submit_time": trademark.InitialSubmitTime,
-			"initial_status":      trademark.InitialStatus,
-		}
-	} else if trademark.CurrentStep == ApprovalStepFinal {
-		// 终审逻辑
-		trademark.FinalReviewerID = ReviewerID
-		trademark.FinalComment = Comment
-		trademark.FinalSubmitTime = now
-		trademark.FinalStatus = (Status == 1)
-
-		if Status == 1 {
-			// 终审通过
-			trademark.ApprovalStatus = ApprovalStatusApproved
-		} else {
-			// 终审驳回
-			trademark.ApprovalStatus = ApprovalStatusRejected
-		}
-
-		updates = map[string]interface{}{
-			"approval_status":   trademark.ApprovalStatus,
-			"final_reviewer_id": trademark.FinalReviewerID,
-			"final_comment":     trademark.FinalComment,
-			"final_submit_time": trademark.FinalSubmitTime,
-			"final_status":      trademark.FinalStatus,
-		}
-	}
-
-	// 执行数据库更新
-	if err := TrademarkDB.Model(&Trademark{}).
-		Where("id = ?", trademarkId).
-		Updates(updates).Error; err != nil {
-		return err
-	}
-
-	return nil
+	return ReviewAsset(AssetTypeTrademark, trademarkId, ReviewerID, Comment, Status)
 }
@@ -0,0 +1,384 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"intellectual_property/pkg/utils"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 分片上传相关错误
+var (
+	ErrUploadSessionNotFound = errors.New("上传任务不存在或已过期")
+	ErrUploadCompleted       = errors.New("上传已完成")
+	ErrUploadEmpty           = errors.New("文件不能为空")
+	ErrUploadChecksumFormat  = errors.New("校验和应为64位十六进制的SHA-256")
+	ErrUploadChunkIndex      = errors.New("分片序号无效")
+	ErrUploadChunkSize       = errors.New("分片大小与上传任务不符")
+	ErrUploadChunkChecksum   = errors.New("分片校验和不一致，请重新上传该分片")
+	ErrUploadIncomplete      = errors.New("还有分片未上传")
+	ErrUploadChecksum        = errors.New("合并后的文件校验和不一致")
+)
+
+// 分片在存储中的临时目录，合并后删除
+const uploadStagingPrefix = "uploads/"
+
+// UploadSession 分片上传任务
+// 客户端先创建任务，再按序号上传各分片（可重复上传、可中断后继续），全部上传后合并为资产附件
+type UploadSession struct {
+	ID             string     `json:"upload_id" gorm:"primaryKey;type:varchar(32)"`
+	OwnerType      string     `json:"owner_type" gorm:"type:varchar(20);index:idx_upload_owner;comment:资产类型"`
+	OwnerID        int        `json:"owner_id" gorm:"type:bigint;index:idx_upload_owner;comment:资产ID"`
+	Name           string     `json:"name" gorm:"type:varchar(255);comment:文件名"`
+	Size           int64      `json:"size" gorm:"type:bigint;comment:文件大小"`
+	SHA256         string     `json:"sha256" gorm:"column:sha256;type:varchar(64);comment:客户端声明的文件SHA-256，可为空"`
+	ChunkSize      int64      `json:"chunk_size" gorm:"type:bigint;comment:分片大小"`
+	TotalChunks    int        `json:"total_chunks" gorm:"type:int;comment:分片数"`
+	UploaderID     int        `json:"uploader_id" gorm:"type:bigint;comment:上传人ID"`
+	AttachmentID   int        `json:"attachment_id" gorm:"type:bigint;comment:合并后登记的附件ID"`
+	CompletedAt    *time.Time `json:"completed_at" gorm:"type:datetime;comment:合并完成时间"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"type:datetime;index;comment:过期时间"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ReceivedChunks []int      `json:"received_chunks" gorm:"-"` // 已上传的分片序号，断点续传时跳过
+}
+
+// UploadChunk 已上传的分片
+type UploadChunk struct {
+	ID         int       `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	UploadID   string    `json:"upload_id" gorm:"type:varchar(32);uniqueIndex:idx_upload_chunk"`
+	ChunkIndex int       `json:"chunk_index" gorm:"type:int;uniqueIndex:idx_upload_chunk;comment:分片序号，从0开始"`
+	Size       int64     `json:"size" gorm:"type:bigint"`
+	SHA256     string    `json:"sha256" gorm:"column:sha256;type:char(64)"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// UploadSessionDB 全局数据库连接实例
+var UploadSessionDB *gorm.DB = utils.DB
+
+// UploadChunkCount 按分片大小计算分片数
+func UploadChunkCount(size int64, chunkSize int64) int {
+	if size <= 0 || chunkSize <= 0 {
+		return 0
+	}
+	return int((size + chunkSize - 1) / chunkSize)
+}
+
+// ChunkLength 第 index 个分片的字节数，最后一片为剩余部分，序号无效时返回0
+func (s *UploadSession) ChunkLength(index int) int64 {
+	if index < 0 || index >= s.TotalChunks {
+		return 0
+	}
+	if index == s.TotalChunks-1 {
+		return s.Size - int64(index)*s.ChunkSize
+	}
+	return s.ChunkSize
+}
+
+// uploadChunkKey 分片在存储中的路径
+func uploadChunkKey(uploadID string, index int) string {
+	return uploadStagingPrefix + uploadID + "/" + strconv.Itoa(index)
+}
+
+// normalizeSHA256 校验并统一为小写的 SHA-256 十六进制串
+func normalizeSHA256(checksum string) (string, error) {
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if b, err := hex.DecodeString(checksum); err != nil || len(b) != sha256.Size {
+		return "", ErrUploadChecksumFormat
+	}
+	return checksum, nil
+}
+
+// CreateUploadSession 创建分片上传任务
+// 先按文件名和大小校验，内容类型和病毒扫描在合并后进行
+func CreateUploadSession(assetType string, assetID int, uploaderID int, filename string, size int64, checksum string) (*UploadSession, error) {
+	policy, err := utils.ResumablePolicy(assetType)
+	if err != nil {
+		return nil, err
+	}
+	name := utils.SanitizeFileName(filename)
+	if name == "" {
+		return nil, utils.ErrUploadName
+	}
+	if size <= 0 {
+		return nil, ErrUploadEmpty
+	}
+	if err := policy.CheckName(name, size); err != nil {
+		return nil, err
+	}
+	if checksum != "" {
+		if checksum, err = normalizeSHA256(checksum); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := getAssetApplicationNumber(UploadSessionDB, assetType, assetID); err != nil {
+		return nil, err
+	}
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	settings := utils.UploadConfig.Resumable
+	session := UploadSession{
+		ID:          id,
+		OwnerType:   assetType,
+		OwnerID:     assetID,
+		Name:        name,
+		Size:        size,
+		SHA256:      checksum,
+		ChunkSize:   settings.ChunkSize,
+		TotalChunks: UploadChunkCount(size, settings.ChunkSize),
+		UploaderID:  uploaderID,
+		ExpiresAt:   time.Now().Add(settings.Expire),
+	}
+	if err := UploadSessionDB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	session.ReceivedChunks = []int{}
+	return &session, nil
+}
+
+// GetUploadSession 查询上传任务及已上传的分片
+func GetUploadSession(assetType string, assetID int, uploadID string) (*UploadSession, error) {
+	var session UploadSession
+	err := UploadSessionDB.Where("id = ? AND owner_type = ? AND owner_id = ?", uploadID, assetType, assetID).
+		Take(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUploadSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.CompletedAt == nil && time.Now().After(session.ExpiresAt) {
+		return nil, ErrUploadSessionNotFound
+	}
+	session.ReceivedChunks = []int{}
+	if err := UploadSessionDB.Model(&UploadChunk{}).Where("upload_id = ?", uploadID).
+		Order("chunk_index").Pluck("chunk_index", &session.ReceivedChunks).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// byteCounter 统计写入的字节数
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// PutUploadChunk 保存一个分片，大小和校验和都一致才记录为已上传
+// 同一分片可以重复上传，后上传的覆盖先前的
+func PutUploadChunk(session *UploadSession, index int, r io.Reader, size int64, checksum string) error {
+	if session.CompletedAt != nil {
+		return ErrUploadCompleted
+	}
+	if index < 0 || index >= session.TotalChunks {
+		return ErrUploadChunkIndex
+	}
+	if size != session.ChunkLength(index) {
+		return ErrUploadChunkSize
+	}
+	checksum, err := normalizeSHA256(checksum)
+	if err != nil {
+		return err
+	}
+	key := uploadChunkKey(session.ID, index)
+	h := sha256.New()
+	var n byteCounter
+	body := io.TeeReader(io.LimitReader(r, size), io.MultiWriter(h, &n))
+	if err := utils.Store.Put(context.Background(), key, body, size, "application/octet-stream"); err != nil {
+		return err
+	}
+	if int64(n) != size || hex.EncodeToString(h.Sum(nil)) != checksum {
+		if err := utils.Store.Delete(context.Background(), key); err != nil {
+			utils.Logger.Error("删除校验失败的分片失败: " + err.Error())
+		}
+		if int64(n) != size {
+			return ErrUploadChunkSize
+		}
+		return ErrUploadChunkChecksum
+	}
+	chunk := UploadChunk{UploadID: session.ID, ChunkIndex: index, Size: size, SHA256: checksum}
+	return UploadSessionDB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "upload_id"}, {Name: "chunk_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "sha256"}),
+	}).Create(&chunk).Error
+}
+
+// CompleteUploadSession 合并全部分片，校验和扫描通过后登记为资产附件
+// 分片先合并到本地临时文件，校验整个文件的校验和后按普通附件写入申请号目录；已完成的任务直接返回登记的附件
+func CompleteUploadSession(session *UploadSession) (*Attachment, error) {
+	if session.CompletedAt != nil {
+		return GetAttachment(session.OwnerType, session.OwnerID, session.AttachmentID)
+	}
+	var chunks []UploadChunk
+	if err := UploadSessionDB.Where("upload_id = ?", session.ID).Order("chunk_index").Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	if len(chunks) != session.TotalChunks {
+		return nil, ErrUploadIncomplete
+	}
+
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	err = assembleUploadChunks(session, chunks, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	open := func() (io.ReadCloser, error) {
+		return os.Open(tmp.Name())
+	}
+	checked, err := utils.ValidateResumableUpload(context.Background(), session.OwnerType, session.Name, session.Size, open)
+	if err != nil {
+		return nil, err
+	}
+	applicationNumber, err := getAssetApplicationNumber(UploadSessionDB, session.OwnerType, session.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	staged, err := stageAttachment(session.OwnerType, applicationNumber, checked, open)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []Attachment
+	var task *StorageTask
+	err = UploadSessionDB.Transaction(func(tx *gorm.DB) error {
+		// 锁定任务，并发的合并请求只有一个能登记附件
+		var current UploadSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", session.ID).Take(&current).Error; err != nil {
+			return err
+		}
+		if current.CompletedAt != nil {
+			return ErrUploadCompleted
+		}
+		var err error
+		if attachments, err = registerAttachments(tx, session.OwnerType, session.OwnerID, session.UploaderID, []StagedAttachment{staged}); err != nil {
+			return err
+		}
+		if err := tx.Model(&UploadSession{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+			"completed_at":  time.Now(),
+			"attachment_id": attachments[0].ID,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("upload_id = ?", session.ID).Delete(&UploadChunk{}).Error; err != nil {
+			return err
+		}
+		task, err = enqueueStorageTask(tx, StorageTaskDeletePrefix, uploadStagingPrefix+session.ID+"/")
+		return err
+	})
+	if err != nil {
+		DiscardStagedAttachments([]StagedAttachment{staged})
+		return nil, err
+	}
+	runStorageTasksNow([]*StorageTask{task})
+	return &attachments[0], nil
+}
+
+// assembleUploadChunks 按序号把分片写入 w，同时复核每个分片和整个文件的校验和
+// 分片校验失败时删除该分片的记录，客户端查询任务后重新上传
+func assembleUploadChunks(session *UploadSession, chunks []UploadChunk, w io.Writer) error {
+	whole := sha256.New()
+	for i, chunk := range chunks {
+		if chunk.ChunkIndex != i {
+			return ErrUploadIncomplete
+		}
+		rc, err := utils.Store.Get(context.Background(), uploadChunkKey(session.ID, i))
+		if errors.Is(err, utils.ErrObjectNotFound) {
+			UploadSessionDB.Where("id = ?", chunk.ID).Delete(&UploadChunk{})
+			return ErrUploadIncomplete
+		}
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(w, whole, h), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if n != chunk.Size || hex.EncodeToString(h.Sum(nil)) != chunk.SHA256 {
+			UploadSessionDB.Where("id = ?", chunk.ID).Delete(&UploadChunk{})
+			return fmt.Errorf("分片 %d: %w", i, ErrUploadChunkChecksum)
+		}
+	}
+	if session.SHA256 != "" && hex.EncodeToString(whole.Sum(nil)) != session.SHA256 {
+		return ErrUploadChecksum
+	}
+	return nil
+}
+
+// AbortUploadSession 取消上传，删除任务和已上传的分片
+func AbortUploadSession(session *UploadSession) error {
+	if session.CompletedAt != nil {
+		return ErrUploadCompleted
+	}
+	var task *StorageTask
+	err := UploadSessionDB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		task, err = deleteUploadSession(tx, session)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	runStorageTasksNow([]*StorageTask{task})
+	return nil
+}
+
+// deleteUploadSession 在事务中删除上传任务和分片记录，并登记删除分片文件的任务
+func deleteUploadSession(tx *gorm.DB, session *UploadSession) (*StorageTask, error) {
+	if err := tx.Where("upload_id = ?", session.ID).Delete(&UploadChunk{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("id = ?", session.ID).Delete(&UploadSession{}).Error; err != nil {
+		return nil, err
+	}
+	return enqueueStorageTask(tx, StorageTaskDeletePrefix, uploadStagingPrefix+session.ID+"/")
+}
+
+// RunUploadCleanupJob 定时删除过期的上传任务及其分片
+func RunUploadCleanupJob(ctx context.Context) error {
+	var sessions []UploadSession
+	if err := UploadSessionDB.Where("expires_at < ?", time.Now()).Order("expires_at").Limit(500).
+		Find(&sessions).Error; err != nil {
+		return err
+	}
+	tasks := make([]*StorageTask, 0, len(sessions))
+	for i := range sessions {
+		if ctx.Err() != nil {
+			break
+		}
+		err := UploadSessionDB.Transaction(func(tx *gorm.DB) error {
+			task, err := deleteUploadSession(tx, &sessions[i])
+			if err == nil {
+				tasks = append(tasks, task)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	runStorageTasksNow(tasks)
+	return ctx.Err()
+}
@@ -11,7 +11,7 @@ type User struct {
 	DepID      int    `json:"dep_id" gorm:"dep_id"`                           //单位ID
 	Password   string `json:"password" gorm:"password"`                       //登录密码
 	Email      string `json:"email" gorm:"email"`                             //邮箱
-	Authority  string `json:"authority" gorm:"authority"`                     //权限控制 admin/user--管理员和普通用户
+	Authority  string `json:"authority" gorm:"authority"`                     //旧版权限字段 admin/user，仅用于展示和迁移，实际权限见 user_roles
 	Sex        string `json:"sex" gorm:"sex"`                                 //性别
 	Birth      string `json:"birth" gorm:"birth"`                             //出生日期
 	IDCard     string `json:"id_card" gorm:"id_card"`                         //身份证号码
@@ -63,8 +63,13 @@ func GetUserByID(userID int) (User, error) {
 }
 
 // UpdateUser 更新用户信息
-func UpdateUser(user *User) error {
-	return utils.DB.Model(&User{}).Where("id=?", user.ID).Select("dep_id", "political", "unit", "last_degree", "tech_ip", "cour", "research").Updates(&user).Error
+// 所属部门决定减缴代码等部门范围的资格，只有 withDep 为 true (管理员修改)时才更新
+func UpdateUser(user *User, withDep bool) error {
+	columns := []string{"political", "unit", "last_degree", "tech_ip", "cour", "research"}
+	if withDep {
+		columns = append(columns, "dep_id")
+	}
+	return utils.DB.Model(&User{}).Where("id=?", user.ID).Select(columns).Updates(&user).Error
 }
 
 // DeleteUser 删除用户
@@ -85,3 +90,8 @@ func GetUserByEmail(email string) (*User, error) {
 func UploadAvatar(id int, url string) error {
 	return utils.DB.Model(&User{}).Where("id = ?", id).Update("avatar_url", url).Error
 }
+
+// UpdatePassword 更新用户密码，password 为加密后的密码
+func UpdatePassword(id int, password string) error {
+	return utils.DB.Model(&User{}).Where("id = ?", id).Update("password", password).Error
+}
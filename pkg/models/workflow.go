@@ -0,0 +1,264 @@
+package models
+
+import (
+	"errors"
+	"intellectual_property/pkg/utils"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WorkflowAnySubType 表示流程适用于该资产类型下的所有子类型
+// 专利类型代码从0开始，所以不能用0表示"全部"
+const WorkflowAnySubType = -1
+
+// WorkflowDefinition 审批流程定义
+// 按资产类型和子类型匹配，子类型为 WorkflowAnySubType 时作为该资产类型的默认流程
+type WorkflowDefinition struct {
+	ID        int             `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	AssetType string          `json:"asset_type" gorm:"type:varchar(20);index:idx_workflow_asset;comment:资产类型(patent/article/trademark)"`
+	SubType   int             `json:"sub_type" gorm:"type:int;index:idx_workflow_asset;comment:子类型代码(-1=默认流程)"`
+	Name      string          `json:"name" gorm:"type:varchar(100);comment:流程名称"`
+	Stages    []WorkflowStage `json:"stages" gorm:"foreignKey:WorkflowID"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WorkflowStage 审批环节
+type WorkflowStage struct {
+	ID           int    `json:"id" gorm:"primaryKey;autoIncrement;type:bigint"`
+	WorkflowID   int    `json:"workflow_id" gorm:"type:bigint;index;comment:流程ID"`
+	Step         int    `json:"step" gorm:"type:int;comment:环节序号(从1开始连续编号)"`
+	Name         string `json:"name" gorm:"type:varchar(100);comment:环节名称"`
+	RequiredRole string `json:"required_role" gorm:"type:varchar(50);comment:审批所需角色"`
+}
+
+// WorkflowDB 全局数据库连接实例
+var WorkflowDB *gorm.DB = utils.DB
+
+// defaultWorkflows 内置的默认审批流程
+// 数据库中没有配置时使用，InitWorkflows 也会把它们写入数据库作为初始数据
+var defaultWorkflows = []WorkflowDefinition{
+	{
+		AssetType: AssetTypePatent,
+		SubType:   PatentInvention,
+		Name:      "发明专利审批",
+		Stages: []WorkflowStage{
+			{Step: 1, Name: "部门审核", RequiredRole: RoleDeptReviewer},
+			{Step: 2, Name: "知识产权办公室审核", RequiredRole: RoleIPOfficer},
+			{Step: 3, Name: "法务审核", RequiredRole: RoleLegalReviewer},
+		},
+	},
+	{
+		AssetType: AssetTypePatent,
+		SubType:   WorkflowAnySubType,
+		Name:      "专利审批",
+		Stages: []WorkflowStage{
+			{Step: 1, Name: "初审", RequiredRole: RoleDeptReviewer},
+			{Step: 2, Name: "终审", RequiredRole: RoleIPOfficer},
+		},
+	},
+	{
+		AssetType: AssetTypeArticle,
+		SubType:   int(WebResources),
+		Name:      "网页资源审批",
+		Stages: []WorkflowStage{
+			{Step: 1, Name: "审核", RequiredRole: RoleDeptReviewer},
+		},
+	},
+	{
+		AssetType: AssetTypeArticle,
+		SubType:   WorkflowAnySubType,
+		Name:      "著作审批",
+		Stages: []WorkflowStage{
+			{Step: 1, Name: "初审", RequiredRole: RoleDeptReviewer},
+			{Step: 2, Name: "终审", RequiredRole: RoleIPOfficer},
+		},
+	},
+	{
+		AssetType: AssetTypeTrademark,
+		SubType:   WorkflowAnySubType,
+		Name:      "商标审批",
+		Stages: []WorkflowStage{
+			{Step: 1, Name: "初审", RequiredRole: RoleDeptReviewer},
+			{Step: 2, Name: "终审", RequiredRole: RoleIPOfficer},
+		},
+	},
+}
+
+// DefaultWorkflow 返回内置的默认流程，优先匹配子类型
+// 返回的是副本，调用方可以放心修改
+func DefaultWorkflow(assetType string, subType int) (WorkflowDefinition, bool) {
+	var found *WorkflowDefinition
+	for i := range defaultWorkflows {
+		w := &defaultWorkflows[i]
+		if w.AssetType != assetType {
+			continue
+		}
+		if w.SubType == subType {
+			found = w
+			break
+		}
+		if w.SubType == WorkflowAnySubType {
+			found = w
+		}
+	}
+	if found == nil {
+		return WorkflowDefinition{}, false
+	}
+	w := *found
+	w.Stages = append([]WorkflowStage(nil), found.Stages...)
+	return w, true
+}
+
+// Validate 校验流程定义
+// 环节序号必须从 ApprovalStepInitial 开始连续编号，保证新建和重置流程时都能落在第一个环节
+func (w *WorkflowDefinition) Validate() error {
+	if _, err := getAssetMeta(w.AssetType); err != nil {
+		return err
+	}
+	if len(w.Stages) == 0 {
+		return errors.New("审批流程至少需要一个环节")
+	}
+	w.sortStages()
+	for i, s := range w.Stages {
+		if s.Step != ApprovalStepInitial+i {
+			return errors.New("审批环节序号必须从1开始连续编号")
+		}
+		if s.Name == "" || s.RequiredRole == "" {
+			return errors.New("审批环节名称和所需角色不能为空")
+		}
+	}
+	return nil
+}
+
+// Stage 根据环节序号查找环节
+func (w *WorkflowDefinition) Stage(step int) (WorkflowStage, bool) {
+	for _, s := range w.Stages {
+		if s.Step == step {
+			return s, true
+		}
+	}
+	return WorkflowStage{}, false
+}
+
+// Advance 根据当前环节和审批结论计算流程走向
+// 返回：
+//   - stage 当前审批的环节
+//   - nextStep 审批后资产所处的环节
+//   - status 审批后资产的整体审批状态
+func (w *WorkflowDefinition) Advance(currentStep int, pass bool) (stage WorkflowStage, nextStep int, status int, err error) {
+	w.sortStages()
+	for i, s := range w.Stages {
+		if s.Step != currentStep {
+			continue
+		}
+		if !pass {
+			return s, currentStep, ApprovalStatusRejected, nil
+		}
+		if i == len(w.Stages)-1 {
+			return s, currentStep, ApprovalStatusApproved, nil
+		}
+		return s, w.Stages[i+1].Step, ApprovalStatusInProgress, nil
+	}
+	return WorkflowStage{}, 0, 0, errors.New("当前审批环节不存在于审批流程中")
+}
+
+// sortStages 按环节序号排序
+func (w *WorkflowDefinition) sortStages() {
+	sort.Slice(w.Stages, func(i, j int) bool {
+		return w.Stages[i].Step < w.Stages[j].Step
+	})
+}
+
+// FindWorkflow 查找资产适用的审批流程
+// 匹配顺序：数据库中的子类型流程 -> 数据库中的默认流程 -> 内置默认流程
+func FindWorkflow(db *gorm.DB, assetType string, subType int) (WorkflowDefinition, error) {
+	for _, st := range []int{subType, WorkflowAnySubType} {
+		var workflows []WorkflowDefinition
+		if err := db.Preload("Stages").
+			Where("asset_type = ? AND sub_type = ?", assetType, st).
+			Limit(1).
+			Find(&workflows).Error; err != nil {
+			return WorkflowDefinition{}, err
+		}
+		if len(workflows) > 0 {
+			workflows[0].sortStages()
+			return workflows[0], nil
+		}
+	}
+
+	if w, ok := DefaultWorkflow(assetType, subType); ok {
+		return w, nil
+	}
+	return WorkflowDefinition{}, errors.New("未配置审批流程：" + assetType)
+}
+
+// GetAllWorkflows 获取所有审批流程定义
+func GetAllWorkflows() ([]WorkflowDefinition, error) {
+	var workflows []WorkflowDefinition
+	if err := WorkflowDB.Preload("Stages", func(db *gorm.DB) *gorm.DB {
+		return db.Order("step ASC")
+	}).Order("asset_type, sub_type").Find(&workflows).Error; err != nil {
+		return nil, err
+	}
+	return workflows, nil
+}
+
+// SaveWorkflow 新增或替换审批流程
+// 同一资产类型和子类型只保留一个流程，环节整体替换
+func SaveWorkflow(w *WorkflowDefinition) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	return WorkflowDB.Transaction(func(tx *gorm.DB) error {
+		var existing WorkflowDefinition
+		if err := tx.Where("asset_type = ? AND sub_type = ?", w.AssetType, w.SubType).
+			Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		stages := w.Stages
+		w.Stages = nil
+		if existing.ID != 0 {
+			w.ID = existing.ID
+			w.CreatedAt = existing.CreatedAt
+			if err := tx.Where("workflow_id = ?", w.ID).Delete(&WorkflowStage{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(w).Error; err != nil {
+			return err
+		}
+
+		for i := range stages {
+			stages[i].ID = 0
+			stages[i].WorkflowID = w.ID
+		}
+		if err := tx.Create(&stages).Error; err != nil {
+			return err
+		}
+		w.Stages = stages
+		return nil
+	})
+}
+
+// InitWorkflows 初始化审批流程
+// 流程表为空时写入内置的默认流程，方便管理员在此基础上调整
+func InitWorkflows() error {
+	var count int64
+	if err := WorkflowDB.Model(&WorkflowDefinition{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	for _, d := range defaultWorkflows {
+		w, _ := DefaultWorkflow(d.AssetType, d.SubType)
+		if err := SaveWorkflow(&w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
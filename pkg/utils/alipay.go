@@ -1,21 +1,43 @@
 package utils
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
 	"github.com/smartwalle/alipay/v3"
 	"github.com/spf13/viper"
 )
 
 type AliPay struct {
-	AppID       string `json:"app_id"`
-	PrivateKey  string `json:"private_key"`
-	PublicKey   string `json:"public_key"`
-	NotifyUrl   string `json:"notify_url"`
-	ReturnUrl   string `json:"return_url"`
-	ProductCode string `json:"product_code"`
+	AppID        string `json:"app_id"`
+	PrivateKey   string `json:"private_key"`
+	PublicKey    string `json:"public_key"`
+	NotifyUrl    string `json:"notify_url"`
+	ReturnUrl    string `json:"return_url"`
+	ProductCode  string `json:"product_code"`
+	IsProduction bool   `json:"is_production"` // 是否正式环境，false 为沙箱
+	Gateway      string `json:"gateway"`       // 自定义网关地址，为空时使用官方网关，测试时可指向本地模拟网关
 }
 
-// getAlipayConfig 读取Email配置文件
+var (
+	alipayClient *alipay.Client
+	alipayConfig AliPay
+	alipayMu     sync.RWMutex
+)
+
+// 支付宝相关错误
+var (
+	ErrAlipayNotInit  = errors.New("支付宝客户端未初始化")
+	ErrAlipayAppID    = errors.New("支付宝通知的 app_id 不匹配")
+	ErrAlipaySignFail = errors.New("支付宝签名校验失败")
+)
+
+// getAlipayConfig 读取支付宝配置文件
 func getAlipayConfig() AliPay {
 	m := AliPay{}
 	viper.SetConfigName("alipay")
@@ -30,51 +52,245 @@ func getAlipayConfig() AliPay {
 	m.NotifyUrl = viper.GetString("alipay.notify_url")
 	m.ReturnUrl = viper.GetString("alipay.return_url")
 	m.ProductCode = viper.GetString("alipay.product_code")
+	m.IsProduction = viper.GetBool("alipay.is_production")
+	m.Gateway = viper.GetString("alipay.gateway")
 	return m
 }
 
-// PaymentOrderCreation 支付订单创建
-func PaymentOrderCreation(Subject, OutTradeNo, TotalAmount string) string {
-	alipayConfig := getAlipayConfig()
-	fmt.Println("alipayConfig:", alipayConfig)
-	client, err := alipay.New(alipayConfig.AppID, alipayConfig.PrivateKey, false)
+// InitAlipay 按配置创建支付宝客户端
+// 启动时调用一次，测试时可以传入本地生成的密钥和模拟网关
+func InitAlipay(config AliPay) error {
+	var opts []alipay.OptionFunc
+	if config.Gateway != "" {
+		opts = append(opts, alipay.WithProductionGateway(config.Gateway), alipay.WithSandboxGateway(config.Gateway))
+	}
+	client, err := alipay.New(config.AppID, config.PrivateKey, config.IsProduction, opts...)
 	if err != nil {
-		Logger.Error(err.Error())
-		return ""
+		return err
+	}
+	//加载支付宝公钥
+	if err := client.LoadAliPayPublicKey(config.PublicKey); err != nil {
+		return err
+	}
+
+	alipayMu.Lock()
+	alipayClient = client
+	alipayConfig = config
+	alipayMu.Unlock()
+
+	RegisterPaymentProvider(AlipayProvider{})
+	return nil
+}
+
+// getAlipayClient 获取支付宝客户端
+func getAlipayClient() (*alipay.Client, AliPay, error) {
+	alipayMu.RLock()
+	defer alipayMu.RUnlock()
+	if alipayClient == nil {
+		return nil, AliPay{}, ErrAlipayNotInit
 	}
-	//加载阿里云公钥证书
-	if err := client.LoadAliPayPublicKey(alipayConfig.PublicKey); err != nil {
-		Logger.Error(err.Error())
-		return ""
+	return alipayClient, alipayConfig, nil
+}
+
+// PaymentOrderCreation 支付订单创建，返回手机网站支付的跳转地址
+func PaymentOrderCreation(subject, outTradeNo, totalAmount string) (string, error) {
+	return alipayWapPay(subject, outTradeNo, totalAmount, "")
+}
+
+// alipayWapPay 创建手机网站支付订单，timeExpire 为绝对超时时间(yyyy-MM-dd HH:mm)，为空时不设置
+func alipayWapPay(subject, outTradeNo, totalAmount, timeExpire string) (string, error) {
+	client, config, err := getAlipayClient()
+	if err != nil {
+		return "", err
 	}
 	//创建交易支付订单
 	var pay = alipay.TradeWapPay{}
-	pay.NotifyURL = alipayConfig.NotifyUrl
-	pay.ReturnURL = alipayConfig.ReturnUrl
-	pay.Subject = Subject
-	pay.OutTradeNo = OutTradeNo
-	pay.TotalAmount = TotalAmount
-	pay.ProductCode = alipayConfig.ProductCode
+	pay.NotifyURL = config.NotifyUrl
+	pay.ReturnURL = config.ReturnUrl
+	pay.Subject = subject
+	pay.OutTradeNo = outTradeNo
+	pay.TotalAmount = totalAmount
+	pay.ProductCode = config.ProductCode
+	pay.TimeExpire = timeExpire
+
+	payURL, err := client.TradeWapPay(pay)
+	if err != nil {
+		return "", err
+	}
+	return payURL.String(), nil
+}
+
+// VerifyAlipayNotify 校验支付宝异步通知的签名和 app_id，返回解析后的通知内容
+func VerifyAlipayNotify(values url.Values) (*alipay.Notification, error) {
+	client, config, err := getAlipayClient()
+	if err != nil {
+		return nil, err
+	}
+	notification, err := client.DecodeNotification(values)
+	if err != nil {
+		return nil, ErrAlipaySignFail
+	}
+	if notification.AppId != config.AppID {
+		return nil, ErrAlipayAppID
+	}
+	return notification, nil
+}
+
+// VerifyAlipayReturn 校验支付完成后同步跳转带回的参数签名
+func VerifyAlipayReturn(values url.Values) error {
+	client, _, err := getAlipayClient()
+	if err != nil {
+		return err
+	}
+	if err := client.VerifySign(values); err != nil {
+		return ErrAlipaySignFail
+	}
+	return nil
+}
+
+// IsAlipayTradePaid 交易状态是否表示已付款
+func IsAlipayTradePaid(status alipay.TradeStatus) bool {
+	return status == alipay.TradeStatusSuccess || status == alipay.TradeStatusFinished
+}
 
-	url, err := client.TradeWapPay(pay)
+// AlipayProvider 支付宝支付渠道
+type AlipayProvider struct{}
+
+// Channel 渠道名称
+func (AlipayProvider) Channel() string {
+	return PaymentChannelAlipay
+}
+
+// CreateOrder 创建手机网站支付订单，返回支付跳转地址
+func (AlipayProvider) CreateOrder(ctx context.Context, req PaymentOrderRequest) (PaymentPrepay, error) {
+	var timeExpire string
+	if !req.ExpireAt.IsZero() {
+		timeExpire = req.ExpireAt.Format("2006-01-02 15:04")
+	}
+	payURL, err := alipayWapPay(req.Subject, req.OrderNo, FormatAmount(req.Amount), timeExpire)
+	if err != nil {
+		return PaymentPrepay{}, err
+	}
+	return PaymentPrepay{PayURL: payURL}, nil
+}
+
+// VerifyNotify 校验支付宝异步通知，通知原文为表单编码
+func (AlipayProvider) VerifyNotify(r *http.Request) (PaymentNotify, error) {
+	if err := r.ParseForm(); err != nil {
+		return PaymentNotify{}, err
+	}
+	notification, err := VerifyAlipayNotify(r.PostForm)
 	if err != nil {
-		Logger.Error(err.Error())
-		return ""
+		return PaymentNotify{}, err
 	}
-	return url.String()
+	amount, err := strconv.ParseFloat(notification.TotalAmount, 64)
+	if err != nil {
+		return PaymentNotify{}, errors.New("支付宝通知金额无效: " + notification.TotalAmount)
+	}
+	paidAt, err := time.ParseInLocation(time.DateTime, notification.GmtPayment, time.Local)
+	if err != nil {
+		paidAt = time.Now()
+	}
+	return PaymentNotify{
+		OrderNo: notification.OutTradeNo,
+		TradeNo: notification.TradeNo,
+		Status:  alipayTradeStatus(notification.TradeStatus),
+		Amount:  amount,
+		PaidAt:  paidAt,
+		Payload: r.PostForm.Encode(),
+	}, nil
 }
 
-// PaymentOrderCreationPatent 专利支付订单创建
-func PaymentOrderCreationPatent(ApplicationNumber, TotalAmount string) string {
-	return PaymentOrderCreation("专利年费", ApplicationNumber, TotalAmount)
+// AckNotify 按支付宝要求，处理成功返回纯文本 success，否则返回 fail 等待支付宝重试
+func (AlipayProvider) AckNotify(w http.ResponseWriter, ok bool) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("success"))
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("fail"))
 }
 
-// PaymentOrderCreationArticle 著作支付订单创建
-func PaymentOrderCreationArticle(ApplicationNumber, TotalAmount string) string {
-	return PaymentOrderCreation("著作年费", ApplicationNumber, TotalAmount)
+// alipayTradeStatus 支付宝交易状态转换为通用的交易状态
+func alipayTradeStatus(status alipay.TradeStatus) string {
+	switch {
+	case IsAlipayTradePaid(status):
+		return TradeStatusPaid
+	case status == alipay.TradeStatusClosed:
+		return TradeStatusClosed
+	}
+	return TradeStatusWaitPay
 }
 
-// PaymentOrderCreationTrademark 商标支付订单创建
-func PaymentOrderCreationTrademark(ApplicationNumber, TotalAmount string) string {
-	return PaymentOrderCreation("商标年费", ApplicationNumber, TotalAmount)
+// QueryTrade 查询支付宝交易
+func (AlipayProvider) QueryTrade(ctx context.Context, outTradeNo string) (RemoteTrade, error) {
+	client, _, err := getAlipayClient()
+	if err != nil {
+		return RemoteTrade{}, err
+	}
+	rsp, err := client.TradeQuery(ctx, alipay.TradeQuery{OutTradeNo: outTradeNo})
+	if err != nil {
+		var aliErr *alipay.Error
+		if errors.As(err, &aliErr) && aliErr.SubCode == "ACQ.TRADE_NOT_EXIST" {
+			return RemoteTrade{}, nil
+		}
+		return RemoteTrade{}, err
+	}
+	if rsp.IsFailure() {
+		if rsp.SubCode == "ACQ.TRADE_NOT_EXIST" {
+			return RemoteTrade{}, nil
+		}
+		return RemoteTrade{}, rsp.Error
+	}
+
+	trade := RemoteTrade{Exists: true, TradeNo: rsp.TradeNo, Status: alipayTradeStatus(rsp.TradeStatus)}
+	trade.TotalAmount, _ = strconv.ParseFloat(rsp.TotalAmount, 64)
+	return trade, nil
+}
+
+// CloseTrade 关闭支付宝交易
+// 手机网站支付在用户打开支付页面前支付宝中没有交易，此时无需关闭，下单时设置的超时时间保证之后也无法支付
+func (AlipayProvider) CloseTrade(ctx context.Context, outTradeNo string) error {
+	client, _, err := getAlipayClient()
+	if err != nil {
+		return err
+	}
+	rsp, err := client.TradeClose(ctx, alipay.TradeClose{OutTradeNo: outTradeNo})
+	if err != nil {
+		var aliErr *alipay.Error
+		if errors.As(err, &aliErr) && aliErr.SubCode == "ACQ.TRADE_NOT_EXIST" {
+			return nil
+		}
+		return err
+	}
+	if rsp.IsFailure() {
+		if rsp.SubCode == "ACQ.TRADE_NOT_EXIST" {
+			return nil
+		}
+		return rsp.Error
+	}
+	return nil
+}
+
+// RefundTrade 发起支付宝退款，支持部分退款
+func (AlipayProvider) RefundTrade(ctx context.Context, outTradeNo string, refundNo string, amount float64, total float64, reason string) error {
+	client, _, err := getAlipayClient()
+	if err != nil {
+		return err
+	}
+	rsp, err := client.TradeRefund(ctx, alipay.TradeRefund{
+		OutTradeNo:   outTradeNo,
+		OutRequestNo: refundNo,
+		RefundAmount: FormatAmount(amount),
+		RefundReason: reason,
+	})
+	if err != nil {
+		return err
+	}
+	if rsp.IsFailure() {
+		return rsp.Error
+	}
+	return nil
 }
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// 病毒扫描驱动
+const (
+	ScannerNone  = "none"  // 不扫描，本地开发使用
+	ScannerClamd = "clamd" // ClamAV 的 clamd 服务
+)
+
+// clamd INSTREAM 每次发送的数据块大小，需小于 clamd 的 StreamMaxLength
+const clamdChunkSize = 64 << 10
+
+// ScannerConfig 病毒扫描配置
+type ScannerConfig struct {
+	Driver  string        `json:"driver"`  // none 或 clamd
+	Address string        `json:"address"` // clamd 地址，tcp 为 host:port，unix socket 为 unix:/path 或以 / 开头的路径
+	Timeout time.Duration `json:"timeout"` // 单个文件的扫描超时时间
+}
+
+// ScanResult 扫描结果
+type ScanResult struct {
+	Infected  bool   // 是否检出病毒
+	Signature string // 病毒名称
+}
+
+// Scanner 上传文件的病毒扫描
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (ScanResult, error)
+}
+
+// UploadScanner 全局病毒扫描实现，测试时可替换
+var UploadScanner Scanner = NoopScanner{}
+
+// NewScanner 按配置创建病毒扫描实现
+func NewScanner(cfg ScannerConfig) (Scanner, error) {
+	switch cfg.Driver {
+	case ScannerNone, "":
+		return NoopScanner{}, nil
+	case ScannerClamd:
+		if cfg.Address == "" {
+			return nil, errors.New("未配置 clamd 地址")
+		}
+		return &ClamdScanner{Address: cfg.Address, Timeout: cfg.Timeout}, nil
+	}
+	return nil, errors.New("不支持的病毒扫描驱动: " + cfg.Driver)
+}
+
+// unavailableScanner 扫描配置错误时使用，拒绝全部上传，避免未经扫描的文件入库
+type unavailableScanner struct {
+	err error
+}
+
+// Scan 返回配置错误
+func (s unavailableScanner) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	return ScanResult{}, s.err
+}
+
+// NoopScanner 不做扫描，所有文件视为正常
+type NoopScanner struct{}
+
+// Scan 直接返回未感染
+func (NoopScanner) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	return ScanResult{}, nil
+}
+
+// ClamdScanner 通过 INSTREAM 命令把文件内容发送给 clamd 扫描
+type ClamdScanner struct {
+	Address string
+	Timeout time.Duration
+}
+
+// dial 连接 clamd
+func (s *ClamdScanner) dial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	network, address := "tcp", s.Address
+	if strings.HasPrefix(address, "unix:") {
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	} else if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	return d.DialContext(ctx, network, address)
+}
+
+// Scan 发送 zINSTREAM，数据按 4 字节大端长度 + 数据块发送，长度 0 表示结束
+// 应答为 "stream: OK"、"stream: <病毒名> FOUND" 或 "<原因> ERROR"
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (ScanResult, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return ScanResult{}, err
+	}
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return ScanResult{}, err
+			}
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			return ScanResult{}, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return ScanResult{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return ScanResult{}, err
+	}
+	return ParseClamdReply(reply)
+}
+
+// ParseClamdReply 解析 clamd 的扫描应答
+func ParseClamdReply(reply string) (ScanResult, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return ScanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return ScanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	}
+	return ScanResult{}, errors.New("clamd 扫描失败: " + reply)
+}
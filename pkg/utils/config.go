@@ -1,6 +1,10 @@
 package utils
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 var GinConfig App
 
@@ -10,6 +14,13 @@ type App struct {
 	AppName string `mapstructure:"app_name" json:"app_name" yaml:"app_name"`
 	AppUrl  string `mapstructure:"app_url" json:"app_url" yaml:"app_url"`
 	JwtKey  string `mapstructure:"jwt_key" json:"jwt_key" yaml:"jwt_key"`
+	// AccessTokenTTL 访问令牌有效期，RefreshTokenTTL 刷新令牌有效期
+	AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl" json:"access_token_ttl" yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl" json:"refresh_token_ttl" yaml:"refresh_token_ttl"`
+	// PublicRoutes 不需要登录即可访问的接口，以 /* 结尾表示前缀匹配
+	PublicRoutes []string `mapstructure:"public_routes" json:"public_routes" yaml:"public_routes"`
+	// TrustedProxies 可信的反向代理地址或网段，只有来自这些地址的请求才读取 X-Forwarded-For，为空时使用连接的对端地址
+	TrustedProxies []string `mapstructure:"trusted_proxies" json:"trusted_proxies" yaml:"trusted_proxies"`
 }
 
 // GetGinConfig 读取Gin 配置文件
@@ -26,5 +37,9 @@ func GetGinConfig() {
 	m.Port = viper.GetString("app.port")
 	m.AppName = viper.GetString("app.app_name")
 	m.JwtKey = viper.GetString("app.jwt_key")
+	m.AccessTokenTTL = viper.GetDuration("app.access_token_ttl")
+	m.RefreshTokenTTL = viper.GetDuration("app.refresh_token_ttl")
+	m.PublicRoutes = viper.GetStringSlice("app.public_routes")
+	m.TrustedProxies = viper.GetStringSlice("app.trusted_proxies")
 	GinConfig = m
 }
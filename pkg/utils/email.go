@@ -2,11 +2,14 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"fmt"
+	"github.com/go-redis/redis/v8"
 	"github.com/jordan-wright/email"
 	"github.com/spf13/viper"
 	"math/big"
 	"net/smtp"
+	"time"
 )
 
 // 发送邮件
@@ -42,8 +45,33 @@ func generateSecureCode() string {
 	return fmt.Sprintf("%04d", n)
 }
 
-// SendAddUserEmailCode 发送邮件
+// 验证码在 redis 中的 key 前缀，不同用途的验证码互不影响
+// 注册验证码沿用邮箱地址本身作为 key
+const (
+	passwordResetPrefix = "pwd_reset:"
+	codeAttemptsSuffix  = ":attempts"
+)
+
+// maxCodeAttempts 验证码最多可以输错的次数，超过后验证码作废
+const maxCodeAttempts = 5
+
+// PasswordResetKey 找回密码验证码的 key
+func PasswordResetKey(email string) string {
+	return passwordResetPrefix + email
+}
+
+// SendAddUserEmailCode 发送注册验证码
 func SendAddUserEmailCode(toEmail string, subject string) error {
+	return SendEmailCode(toEmail, toEmail, subject)
+}
+
+// SendPasswordResetCode 发送找回密码验证码
+func SendPasswordResetCode(toEmail string) error {
+	return SendEmailCode(PasswordResetKey(toEmail), toEmail, "找回密码")
+}
+
+// SendEmailCode 生成验证码保存到 redis 的 key 下，并发送到邮箱
+func SendEmailCode(key string, toEmail string, subject string) error {
 	config := getEmailConfig()
 	e := email.NewEmail()
 	//设置发送方的邮箱
@@ -54,14 +82,45 @@ func SendAddUserEmailCode(toEmail string, subject string) error {
 	e.Subject = subject
 	//设置code
 	code := generateSecureCode()
-	err := RedisSet(toEmail, code)
+	err := RedisSet(key, code)
 	if err != nil {
 		Logger.Error(err.Error())
 		return err
 	}
+	//重新发送后错误次数清零
+	if err := RedisDel(key + codeAttemptsSuffix); err != nil {
+		Logger.Error(err.Error())
+		return err
+	}
 	//设置文件发送的内容
 	e.Text = []byte("【知识产权】您此次验证码为" + code + "，5分钟内有效，请您尽快验证！")
 	//设置服务器相关的配置
 
 	return e.Send(config.Url+":25", smtp.PlainAuth("", config.MyEmail, config.Password, config.Url))
 }
+
+// VerifyEmailCode 校验验证码，校验通过后验证码立即失效
+// 输错超过 maxCodeAttempts 次验证码作废，防止暴力猜测
+func VerifyEmailCode(key string, code string) (bool, error) {
+	stored, err := RDB.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if code == "" || subtle.ConstantTimeCompare([]byte(stored), []byte(code)) != 1 {
+		attempts, err := RDB.Incr(ctx, key+codeAttemptsSuffix).Result()
+		if err != nil {
+			return false, err
+		}
+		if attempts == 1 {
+			RDB.Expire(ctx, key+codeAttemptsSuffix, 5*time.Minute)
+		}
+		if attempts >= maxCodeAttempts {
+			return false, RDB.Del(ctx, key, key+codeAttemptsSuffix).Err()
+		}
+		return false, nil
+	}
+	return true, RDB.Del(ctx, key, key+codeAttemptsSuffix).Err()
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"math"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	FeeScheduleConfig FeeSchedule
+	FeeOverdueConfig  FeeOverdue
+)
+
+// FeeTariffBracket 年费分段：第 From 年到第 To 年（含）每年缴纳 Amount
+type FeeTariffBracket struct {
+	From   int     `json:"from" mapstructure:"from"`
+	To     int     `json:"to" mapstructure:"to"`
+	Amount float64 `json:"amount" mapstructure:"amount"`
+}
+
+// AnnuityRule 专利年费规则
+type AnnuityRule struct {
+	TermYears int                `json:"term_years" mapstructure:"term_years"` // 保护期限(年)，自申请日起算
+	Tariff    []FeeTariffBracket `json:"tariff" mapstructure:"tariff"`         // 按年度分段的年费
+}
+
+// AmountForYear 第 year 年的年费，没有匹配的分段时返回 0
+func (r AnnuityRule) AmountForYear(year int) float64 {
+	for _, b := range r.Tariff {
+		if year >= b.From && year <= b.To {
+			return b.Amount
+		}
+	}
+	return 0
+}
+
+// RenewalRule 商标续展费规则
+type RenewalRule struct {
+	PeriodYears int     `json:"period_years" mapstructure:"period_years"` // 每个有效期的年数，自核准注册日起算
+	Periods     int     `json:"periods" mapstructure:"periods"`           // 核准注册时预先生成的续展次数
+	Amount      float64 `json:"amount" mapstructure:"amount"`             // 每次续展的费用
+}
+
+// FeeSchedule 授权后费用计划的配置
+type FeeSchedule struct {
+	Invention AnnuityRule `json:"invention" mapstructure:"invention"` // 发明专利
+	Utility   AnnuityRule `json:"utility" mapstructure:"utility"`     // 实用新型
+	Design    AnnuityRule `json:"design" mapstructure:"design"`       // 外观设计
+	Trademark RenewalRule `json:"trademark" mapstructure:"trademark"` // 商标续展
+}
+
+// defaultFeeSchedule 配置文件中没有配置的项使用默认值，金额参照国家知识产权局现行标准
+// 金额只用于初始化费用标准表，实际生成费用时以费用标准表为准
+var defaultFeeSchedule = FeeSchedule{
+	Invention: AnnuityRule{TermYears: 20, Tariff: []FeeTariffBracket{
+		{From: 1, To: 3, Amount: 900},
+		{From: 4, To: 6, Amount: 1200},
+		{From: 7, To: 9, Amount: 2000},
+		{From: 10, To: 12, Amount: 4000},
+		{From: 13, To: 15, Amount: 6000},
+		{From: 16, To: 20, Amount: 8000},
+	}},
+	Utility: AnnuityRule{TermYears: 10, Tariff: []FeeTariffBracket{
+		{From: 1, To: 3, Amount: 600},
+		{From: 4, To: 5, Amount: 900},
+		{From: 6, To: 8, Amount: 1200},
+		{From: 9, To: 10, Amount: 2000},
+	}},
+	Design: AnnuityRule{TermYears: 15, Tariff: []FeeTariffBracket{
+		{From: 1, To: 3, Amount: 600},
+		{From: 4, To: 5, Amount: 900},
+		{From: 6, To: 8, Amount: 1200},
+		{From: 9, To: 10, Amount: 2000},
+		{From: 11, To: 15, Amount: 3000},
+	}},
+	Trademark: RenewalRule{PeriodYears: 10, Periods: 1, Amount: 500},
+}
+
+// getFeeScheduleConfig 读取费用计划配置文件
+func getFeeScheduleConfig() FeeSchedule {
+	m := defaultFeeSchedule
+	viper.SetConfigName("fee")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("./config")
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error("读取配置错误")
+		return m
+	}
+	for key, rule := range map[string]*AnnuityRule{
+		"fee.patent.invention": &m.Invention,
+		"fee.patent.utility":   &m.Utility,
+		"fee.patent.design":    &m.Design,
+	} {
+		if !viper.IsSet(key) {
+			continue
+		}
+		var r AnnuityRule
+		if err := viper.UnmarshalKey(key, &r); err != nil {
+			Logger.Error("读取费用计划配置错误: " + key)
+			continue
+		}
+		*rule = r
+	}
+	if viper.IsSet("fee.trademark") {
+		var r RenewalRule
+		if err := viper.UnmarshalKey("fee.trademark", &r); err != nil {
+			Logger.Error("读取费用计划配置错误: fee.trademark")
+		} else {
+			m.Trademark = r
+		}
+	}
+	return m
+}
+
+// FeeOverdue 逾期费用处理配置
+// 授权后的年费和续展费超过缴费期限后进入宽限期，宽限期内补缴需加收滞纳金，宽限期满仍未缴纳则资产失效
+type FeeOverdue struct {
+	Interval         time.Duration `json:"interval"`           // 检查逾期费用的间隔
+	GraceMonths      int           `json:"grace_months"`       // 宽限期(月)，自缴费期限起算
+	SurchargeRate    float64       `json:"surcharge_rate"`     // 每超过一个整月加收的滞纳金比例
+	SurchargeMaxRate float64       `json:"surcharge_max_rate"` // 滞纳金比例上限
+}
+
+// defaultFeeOverdue 参照专利年费滞纳金标准：超过期限每满一个月加收 5%，最多 25%，宽限期 6 个月
+var defaultFeeOverdue = FeeOverdue{
+	Interval:         time.Hour,
+	GraceMonths:      6,
+	SurchargeRate:    0.05,
+	SurchargeMaxRate: 0.25,
+}
+
+// getFeeOverdueConfig 读取逾期费用处理配置
+func getFeeOverdueConfig() FeeOverdue {
+	m := defaultFeeOverdue
+	viper.SetConfigName("fee")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("./config")
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error("读取配置错误")
+		return m
+	}
+	if viper.IsSet("fee.overdue.interval") {
+		m.Interval = viper.GetDuration("fee.overdue.interval")
+	}
+	if viper.IsSet("fee.overdue.grace_months") {
+		m.GraceMonths = viper.GetInt("fee.overdue.grace_months")
+	}
+	if viper.IsSet("fee.overdue.surcharge_rate") {
+		m.SurchargeRate = viper.GetFloat64("fee.overdue.surcharge_rate")
+	}
+	if viper.IsSet("fee.overdue.surcharge_max_rate") {
+		m.SurchargeMaxRate = viper.GetFloat64("fee.overdue.surcharge_max_rate")
+	}
+	return m
+}
+
+// overdueMonths 超过缴费期限的整月数，不足一个月不计
+func overdueMonths(deadline time.Time, now time.Time) int {
+	months := 0
+	for !deadline.AddDate(0, months+1, 0).After(now) {
+		months++
+	}
+	return months
+}
+
+// Surcharge 在 now 补缴金额为 amount 的费用需要加收的滞纳金，精确到分
+func (o FeeOverdue) Surcharge(amount float64, deadline time.Time, now time.Time) float64 {
+	rate := o.SurchargeRate * float64(overdueMonths(deadline, now))
+	if rate > o.SurchargeMaxRate {
+		rate = o.SurchargeMaxRate
+	}
+	if rate <= 0 {
+		return 0
+	}
+	return math.Round(amount*rate*100) / 100
+}
+
+// LapseAt 宽限期届满的时间，届满后仍未缴费则资产失效
+func (o FeeOverdue) LapseAt(deadline time.Time) time.Time {
+	return deadline.AddDate(0, o.GraceMonths, 0)
+}
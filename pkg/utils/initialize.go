@@ -42,4 +42,53 @@ func init() {
 	//初始化Nginx配置
 	NgX = getNginxConfig()
 
+	//初始化附件存储
+	StorageSettings = getStorageConfig()
+	// 配置错误时不能退回到其他目录，否则文件会写到错误的位置或被公开访问
+	Store, err = NewStorage(StorageSettings)
+	if err != nil {
+		Logger.Fatal("初始化附件存储失败: " + err.Error())
+	}
+	PublicStore, err = NewPublicStorage(StorageSettings)
+	if err != nil {
+		Logger.Fatal("初始化公开存储失败: " + err.Error())
+	}
+
+	//初始化上传校验和病毒扫描
+	UploadConfig = getUploadConfig()
+	scanner, err := NewScanner(UploadConfig.Scanner)
+	if err != nil {
+		Logger.Error("初始化病毒扫描失败: " + err.Error())
+		scanner = unavailableScanner{err: err}
+	}
+	UploadScanner = scanner
+
+	//初始化密码策略
+	PwdPolicy = getPasswordConfig()
+
+	//初始化登录防护配置
+	LoginGuardConfig = getLoginGuardConfig()
+
+	//初始化费用计划配置
+	FeeScheduleConfig = getFeeScheduleConfig()
+
+	//初始化逾期费用处理配置
+	FeeOverdueConfig = getFeeOverdueConfig()
+
+	//初始化支付订单配置
+	PaymentConfig = getPaymentConfig()
+
+	//初始化支付宝客户端
+	if err := InitAlipay(getAlipayConfig()); err != nil {
+		Logger.Error("初始化支付宝失败: " + err.Error())
+	}
+
+	//初始化微信支付，未配置商户号时不启用
+	if err := InitWechatPay(getWechatPayConfig()); err != nil {
+		Logger.Error("初始化微信支付失败: " + err.Error())
+	}
+
+	//线下转账
+	RegisterPaymentProvider(OfflineProvider{Config: getOfflinePayConfig()})
+
 }
@@ -1,82 +1,320 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-// 定义 JWT 密钥（生产环境应从安全位置获取）
-var jwtKey = []byte(GinConfig.JwtKey)
+// 令牌相关的 redis key 前缀
+const (
+	refreshTokenPrefix = "refresh_token:" // 刷新令牌 -> 用户信息
+	tokenVersionPrefix = "token_version:" // 用户的令牌版本号，自增后该用户之前签发的令牌全部失效
+	revokedJTIPrefix   = "revoked_jti:"   // 已注销的访问令牌
+)
+
+// 令牌有效期默认值，配置文件中没有配置时使用
+const (
+	defaultAccessTokenTTL  = 30 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// 令牌相关错误
+var (
+	ErrTokenRevoked        = errors.New("令牌已失效")
+	ErrInvalidRefreshToken = errors.New("无效的刷新令牌")
+)
+
+// jwtKey JWT 密钥
+// 每次从配置中读取，不能在包初始化时求值，否则 GinConfig 还没有加载
+func jwtKey() []byte {
+	return []byte(GinConfig.JwtKey)
+}
 
 // CustomClaims 自定义 Claims 结构体
 type CustomClaims struct {
 	UserID    int    `json:"user_id"`
 	Authority string `json:"authority"`  //权限控制
 	UserName  string `json:"user_name" ` //姓名
+	Version   int64  `json:"ver"`        //签发时用户的令牌版本号
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成 JWT Token
-func GenerateToken(userID int, authority string, userName string) (string, error) {
+// TokenPair 登录或刷新后返回给前端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` //访问令牌有效期(秒)
+}
+
+// refreshSession 刷新令牌在 redis 中保存的内容
+type refreshSession struct {
+	UserID    int    `json:"user_id"`
+	Authority string `json:"authority"`
+	UserName  string `json:"user_name"`
+	Version   int64  `json:"ver"`
+}
+
+// accessTokenTTL 访问令牌有效期
+func accessTokenTTL() time.Duration {
+	if GinConfig.AccessTokenTTL > 0 {
+		return GinConfig.AccessTokenTTL
+	}
+	return defaultAccessTokenTTL
+}
+
+// refreshTokenTTL 刷新令牌有效期
+func refreshTokenTTL() time.Duration {
+	if GinConfig.RefreshTokenTTL > 0 {
+		return GinConfig.RefreshTokenTTL
+	}
+	return defaultRefreshTokenTTL
+}
+
+// randomToken 生成随机字符串，用作 jti 和刷新令牌
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
 
+// generateAccessToken 按会话信息签发访问令牌
+func generateAccessToken(s refreshSession) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := CustomClaims{
-		UserID:    userID,
-		Authority: authority,
-		UserName:  userName,
+		UserID:    s.UserID,
+		Authority: s.Authority,
+		UserName:  s.UserName,
+		Version:   s.Version,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour * 7)), // 过期时间-7天
-			IssuedAt:  jwt.NewNumericDate(time.Now()),                         // 签发时间
-			Issuer:    "Zhu",                                                  // 签发者
+			ID:        jti,                                           // 令牌ID，注销时使用
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL())), // 过期时间
+			IssuedAt:  jwt.NewNumericDate(now),                       // 签发时间
+			Issuer:    "Zhu",                                         // 签发者
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return token.SignedString(jwtKey())
+}
+
+// GenerateTokenPair 登录成功后签发访问令牌和刷新令牌
+// 刷新令牌是随机字符串，保存在 redis 中，过期或注销后即失效
+func GenerateTokenPair(userID int, authority string, userName string) (TokenPair, error) {
+	version, err := GetTokenVersion(userID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return issueTokenPair(refreshSession{
+		UserID:    userID,
+		Authority: authority,
+		UserName:  userName,
+		Version:   version,
+	})
+}
+
+// issueTokenPair 签发一对新令牌并保存刷新令牌
+func issueTokenPair(s refreshSession) (TokenPair, error) {
+	access, err := generateAccessToken(s)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if err := RDB.Set(ctx, refreshTokenPrefix+refresh, data, refreshTokenTTL()).Err(); err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(accessTokenTTL() / time.Second),
+	}, nil
+}
+
+// RefreshTokenPair 用刷新令牌换取新的令牌
+// 刷新令牌只能使用一次，换取后旧的刷新令牌立即失效
+func RefreshTokenPair(refreshToken string) (TokenPair, error) {
+	if refreshToken == "" {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	data, err := RDB.GetDel(ctx, refreshTokenPrefix+refreshToken).Result()
+	if err == redis.Nil {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+	var s refreshSession
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	// 修改密码、离职等情况下用户的令牌版本号会变化，之前的刷新令牌不能再使用
+	version, err := GetTokenVersion(s.UserID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if version != s.Version {
+		return TokenPair{}, ErrTokenRevoked
+	}
+	return issueTokenPair(s)
+}
+
+// RevokeRefreshToken 删除用户自己的刷新令牌
+// 令牌不属于该用户时返回 ErrInvalidRefreshToken，防止注销他人的登录；令牌已失效时视为成功
+func RevokeRefreshToken(refreshToken string, userID int) error {
+	if refreshToken == "" {
+		return nil
+	}
+	data, err := RDB.Get(ctx, refreshTokenPrefix+refreshToken).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var s refreshSession
+	if err := json.Unmarshal([]byte(data), &s); err != nil || s.UserID != userID {
+		return ErrInvalidRefreshToken
+	}
+	return RDB.Del(ctx, refreshTokenPrefix+refreshToken).Err()
+}
+
+// RevokeAccessToken 注销访问令牌，在令牌剩余有效期内记录其 jti
+func RevokeAccessToken(claims *CustomClaims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return RDB.Set(ctx, revokedJTIPrefix+claims.ID, 1, ttl).Err()
+}
+
+// RevokeUserTokens 使用户之前签发的所有令牌失效
+// 用于修改密码、禁用账号或在所有设备上退出登录
+func RevokeUserTokens(userID int) error {
+	return RDB.Incr(ctx, tokenVersionPrefix+strconv.Itoa(userID)).Err()
+}
+
+// GetTokenVersion 获取用户当前的令牌版本号，没有记录时为 0
+func GetTokenVersion(userID int) (int64, error) {
+	v, err := RDB.Get(ctx, tokenVersionPrefix+strconv.Itoa(userID)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return v, err
+}
+
+// CheckTokenRevoked 检查访问令牌是否已被注销
+func CheckTokenRevoked(claims *CustomClaims) error {
+	n, err := RDB.Exists(ctx, revokedJTIPrefix+claims.ID).Result()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrTokenRevoked
+	}
+	version, err := GetTokenVersion(claims.UserID)
+	if err != nil {
+		return err
+	}
+	if version != claims.Version {
+		return ErrTokenRevoked
+	}
+	return nil
 }
 
 // JWTMiddleware JWT 中间件
+// 全局注册，配置在 app.public_routes 中的接口直接放行
 func JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if IsPublicRoute(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
 		// 从 Header 获取 token
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未提供认证令牌"})
+			Resp(c, false, http.StatusUnauthorized, "未提供认证令牌", nil)
+			c.Abort()
 			return
 		}
 
 		// 验证 token 格式
-		if len(tokenString) < 7 || tokenString[:7] != "Bearer " {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "令牌格式错误"})
+		if !strings.HasPrefix(tokenString, "Bearer ") {
+			Resp(c, false, http.StatusUnauthorized, "令牌格式错误", nil)
+			c.Abort()
 			return
 		}
-		tokenString = tokenString[7:]
 
 		// 解析并验证 Token
-		token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("无效的签名方法: %v", token.Header["alg"])
-			}
-			return jwtKey, nil
-		})
-
+		claims, err := ParseToken(strings.TrimPrefix(tokenString, "Bearer "))
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "令牌验证失败", "details": err.Error()})
+			Resp(c, false, http.StatusUnauthorized, "无效的访问令牌", nil)
+			c.Abort()
 			return
 		}
 
-		// 类型断言获取 Claims
-		if claims, ok := token.Claims.(*CustomClaims); ok && token.Valid {
-			c.Set("userID", claims.UserID)       // 将用户信息存入上下文 --设置需要的存入的信息
-			c.Set("authority", claims.Authority) //用户权限
-			c.Set("userName", claims.UserName)   //用户名字
-			c.Next()
-		} else {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "无效令牌"})
+		// 检查是否已注销
+		if err := CheckTokenRevoked(claims); err != nil {
+			if errors.Is(err, ErrTokenRevoked) {
+				Resp(c, false, http.StatusUnauthorized, "令牌已失效，请重新登录", nil)
+			} else {
+				Logger.Error(err.Error())
+				Resp(c, false, http.StatusInternalServerError, "系统错误", nil)
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("claims", claims)              // 注销登录时使用
+		c.Set("userID", claims.UserID)       // 将用户信息存入上下文 --设置需要的存入的信息
+		c.Set("authority", claims.Authority) //用户权限
+		c.Set("userName", claims.UserName)   //用户名字
+		c.Next()
+	}
+}
+
+// IsPublicRoute 判断接口是否在免登录白名单中
+// 白名单项以 /* 结尾时按前缀匹配，否则要求完全一致
+func IsPublicRoute(path string) bool {
+	for _, p := range GinConfig.PublicRoutes {
+		if prefix, ok := strings.CutSuffix(p, "/*"); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if path == p {
+			return true
 		}
 	}
+	return false
 }
 
 // ParseToken 解析 JWT Token 并返回 Claims 信息
@@ -89,7 +327,7 @@ func ParseToken(tokenString string) (*CustomClaims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("非预期的签名方法: %v", token.Header["alg"])
 		}
-		return jwtKey, nil // 直接从配置获取最新密钥
+		return jwtKey(), nil // 直接从配置获取最新密钥
 	})
 
 	if err != nil {
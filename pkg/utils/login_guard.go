@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 登录失败计数和锁定在 redis 中的 key 前缀
+const (
+	loginFailAccountPrefix = "login_fail:account:"
+	loginFailIPPrefix      = "login_fail:ip:"
+	loginLockAccountPrefix = "login_lock:account:"
+	loginLockIPPrefix      = "login_lock:ip:"
+)
+
+var LoginGuardConfig LoginGuard
+
+// LoginGuard 登录防暴力破解配置
+// 同一账号或同一IP在 Window 内连续失败达到阈值后锁定 BaseLock，之后每多失败一次锁定时间翻倍，最长 MaxLock
+type LoginGuard struct {
+	MaxAccountFailures int           `json:"max_account_failures"` // 账号失败次数阈值
+	MaxIPFailures      int           `json:"max_ip_failures"`      // IP失败次数阈值
+	Window             time.Duration `json:"window"`               // 失败次数统计窗口
+	BaseLock           time.Duration `json:"base_lock"`            // 首次锁定时长
+	MaxLock            time.Duration `json:"max_lock"`             // 最长锁定时长
+}
+
+// defaultLoginGuard 配置文件中没有配置的项使用默认值
+var defaultLoginGuard = LoginGuard{
+	MaxAccountFailures: 5,
+	MaxIPFailures:      20,
+	Window:             15 * time.Minute,
+	BaseLock:           time.Minute,
+	MaxLock:            time.Hour,
+}
+
+// getLoginGuardConfig 读取登录防护配置
+func getLoginGuardConfig() LoginGuard {
+	m := defaultLoginGuard
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("./config")
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error("读取配置错误")
+		return m
+	}
+	if viper.IsSet("login.max_account_failures") {
+		m.MaxAccountFailures = viper.GetInt("login.max_account_failures")
+	}
+	if viper.IsSet("login.max_ip_failures") {
+		m.MaxIPFailures = viper.GetInt("login.max_ip_failures")
+	}
+	if viper.IsSet("login.window") {
+		m.Window = viper.GetDuration("login.window")
+	}
+	if viper.IsSet("login.base_lock") {
+		m.BaseLock = viper.GetDuration("login.base_lock")
+	}
+	if viper.IsSet("login.max_lock") {
+		m.MaxLock = viper.GetDuration("login.max_lock")
+	}
+	return m
+}
+
+// LockDuration 根据连续失败次数计算锁定时长
+// 未达到阈值返回 0；达到阈值锁定 base，之后每多失败一次翻倍，不超过 max
+func LockDuration(failures int, threshold int, base time.Duration, max time.Duration) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+	d := base
+	for i := threshold; i < failures; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// LoginLockRemaining 返回账号或IP剩余的锁定时间，取两者中较长的
+func LoginLockRemaining(email string, ip string) (time.Duration, error) {
+	var remaining time.Duration
+	for _, key := range []string{loginLockAccountPrefix + email, loginLockIPPrefix + ip} {
+		ttl, err := RDB.PTTL(ctx, key).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ttl > remaining {
+			remaining = ttl
+		}
+	}
+	return remaining, nil
+}
+
+// RecordLoginFailure 记录一次登录失败，达到阈值后锁定账号或IP
+// 返回本次失败后的锁定时长，未锁定时为 0
+func RecordLoginFailure(email string, ip string) (time.Duration, error) {
+	g := LoginGuardConfig
+	accountLock, err := incrLoginFailure(loginFailAccountPrefix+email, loginLockAccountPrefix+email, g.MaxAccountFailures)
+	if err != nil {
+		return 0, err
+	}
+	ipLock, err := incrLoginFailure(loginFailIPPrefix+ip, loginLockIPPrefix+ip, g.MaxIPFailures)
+	if err != nil {
+		return 0, err
+	}
+	if ipLock > accountLock {
+		return ipLock, nil
+	}
+	return accountLock, nil
+}
+
+// incrLoginFailure 失败次数加一并按需写入锁定标记
+func incrLoginFailure(failKey string, lockKey string, threshold int) (time.Duration, error) {
+	g := LoginGuardConfig
+	failures, err := RDB.Incr(ctx, failKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	lock := LockDuration(int(failures), threshold, g.BaseLock, g.MaxLock)
+	// 统计窗口至少要覆盖锁定时间，否则锁定结束时计数已清零，无法继续翻倍
+	window := g.Window
+	if lock*2 > window {
+		window = lock * 2
+	}
+	if err := RDB.Expire(ctx, failKey, window).Err(); err != nil {
+		return 0, err
+	}
+	if lock > 0 {
+		if err := RDB.Set(ctx, lockKey, failures, lock).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return lock, nil
+}
+
+// ResetLoginFailures 清除账号的失败次数和锁定，登录成功或管理员解锁时调用
+// IP 的计数不在登录成功时清除，避免攻击者用自己的账号登录来重置
+func ResetLoginFailures(email string) error {
+	return RDB.Del(ctx, loginFailAccountPrefix+email, loginLockAccountPrefix+email).Err()
+}
+
+// UnlockLoginIP 清除IP的失败次数和锁定
+func UnlockLoginIP(ip string) error {
+	return RDB.Del(ctx, loginFailIPPrefix+ip, loginLockIPPrefix+ip).Err()
+}
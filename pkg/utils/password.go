@@ -5,19 +5,179 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
-)
+	"unicode"
 
-// 安全要求配置
-const (
-	RequiredPasswordLength = 8 // 强制密码长度
-	SaltLength             = 8 // 盐值长度（增强至32字节）
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/argon2"
 )
 
-// GenerateSecureSalt 生成密码学安全随机盐
+// 旧版 MD5 哈希的盐值长度
+// 旧格式为 "盐值:哈希"，只用于校验老用户的密码，登录成功后自动升级为 argon2id
+const SaltLength = 8
+
+// argon2id 哈希格式前缀
+// 完整格式：$argon2id$v=19$m=65536,t=3,p=2$<盐值>$<哈希>，盐值和哈希为无填充的 base64
+const argon2idPrefix = "$argon2id$"
+
+var PwdPolicy PasswordPolicy
+
+// PasswordPolicy 密码策略和 argon2id 参数
+type PasswordPolicy struct {
+	MinLength      int    `json:"min_length"`      // 最短长度
+	RequireUpper   bool   `json:"require_upper"`   // 必须包含大写字母
+	RequireLower   bool   `json:"require_lower"`   // 必须包含小写字母
+	RequireDigit   bool   `json:"require_digit"`   // 必须包含数字
+	RequireSpecial bool   `json:"require_special"` // 必须包含特殊字符
+	Memory         uint32 `json:"memory"`          // argon2id 内存开销(KB)
+	Iterations     uint32 `json:"iterations"`      // argon2id 迭代次数
+	Parallelism    uint8  `json:"parallelism"`     // argon2id 并行度
+	SaltLength     uint32 `json:"salt_length"`     // 盐值长度(字节)
+	KeyLength      uint32 `json:"key_length"`      // 哈希长度(字节)
+}
+
+// defaultPasswordPolicy 配置文件中没有配置的项使用默认值
+var defaultPasswordPolicy = PasswordPolicy{
+	MinLength:    8,
+	RequireLower: true,
+	RequireDigit: true,
+	Memory:       64 * 1024,
+	Iterations:   3,
+	Parallelism:  2,
+	SaltLength:   16,
+	KeyLength:    32,
+}
+
+// getPasswordConfig 读取密码配置文件
+func getPasswordConfig() PasswordPolicy {
+	m := defaultPasswordPolicy
+	viper.SetConfigName("password")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("./config")
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error("读取配置错误")
+		return m
+	}
+	if viper.IsSet("password.min_length") {
+		m.MinLength = viper.GetInt("password.min_length")
+	}
+	if viper.IsSet("password.require_upper") {
+		m.RequireUpper = viper.GetBool("password.require_upper")
+	}
+	if viper.IsSet("password.require_lower") {
+		m.RequireLower = viper.GetBool("password.require_lower")
+	}
+	if viper.IsSet("password.require_digit") {
+		m.RequireDigit = viper.GetBool("password.require_digit")
+	}
+	if viper.IsSet("password.require_special") {
+		m.RequireSpecial = viper.GetBool("password.require_special")
+	}
+	if viper.IsSet("password.argon2.memory") {
+		m.Memory = viper.GetUint32("password.argon2.memory")
+	}
+	if viper.IsSet("password.argon2.iterations") {
+		m.Iterations = viper.GetUint32("password.argon2.iterations")
+	}
+	if viper.IsSet("password.argon2.parallelism") {
+		m.Parallelism = uint8(viper.GetUint("password.argon2.parallelism"))
+	}
+	return m
+}
+
+// ValidatePassword 按密码策略校验新密码
+func ValidatePassword(password string) error {
+	p := PwdPolicy
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("密码长度不能少于%d位", p.MinLength)
+	}
+	var upper, lower, digit, special bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			special = true
+		}
+	}
+	switch {
+	case p.RequireUpper && !upper:
+		return errors.New("密码必须包含大写字母")
+	case p.RequireLower && !lower:
+		return errors.New("密码必须包含小写字母")
+	case p.RequireDigit && !digit:
+		return errors.New("密码必须包含数字")
+	case p.RequireSpecial && !special:
+		return errors.New("密码必须包含特殊字符")
+	}
+	return nil
+}
+
+// HashPassword 使用 argon2id 加密密码，返回自描述的编码格式，直接存入数据库
+func HashPassword(password string) (string, error) {
+	p := PwdPolicy
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("salt generation failed: %v", err)
+	}
+	hash := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// CheckPassword 校验密码，同时支持 argon2id 和旧版 MD5 格式
+// needsRehash 为 true 表示密码正确但存储格式或参数已过时，调用方应重新加密保存
+func CheckPassword(password string, stored string) (ok bool, needsRehash bool) {
+	if !strings.HasPrefix(stored, argon2idPrefix) {
+		// 旧版 "盐值:哈希"
+		if VerifyPassword(password, GetSlat(stored), stored) {
+			return true, true
+		}
+		return false, false
+	}
+
+	var version int
+	var memory, iterations uint32
+	var parallelism uint8
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, iterations, memory, parallelism, uint32(len(hash)))
+	if subtle.ConstantTimeCompare(computed, hash) != 1 {
+		return false, false
+	}
+	p := PwdPolicy
+	return true, memory != p.Memory || iterations != p.Iterations || parallelism != p.Parallelism ||
+		uint32(len(salt)) != p.SaltLength || uint32(len(hash)) != p.KeyLength
+}
+
+// GenerateSecureSalt 生成密码学安全随机盐（旧版 MD5 格式）
 func GenerateSecureSalt() (string, error) {
 	salt := make([]byte, SaltLength)
 	if _, err := rand.Read(salt); err != nil {
@@ -26,13 +186,8 @@ func GenerateSecureSalt() (string, error) {
 	return hex.EncodeToString(salt), nil
 }
 
-// SecureHashWithSalt 带长度验证的密码哈希
+// SecureHashWithSalt 旧版双重 MD5 哈希，只用于校验老用户的密码
 func SecureHashWithSalt(password string, salt string) (string, error) {
-	// 严格密码长度校验
-	if len(password) != RequiredPasswordLength {
-		return "", errors.New("password must be exactly 16 characters")
-	}
-
 	// 盐值解码验证
 	decodedSalt, err := hex.DecodeString(salt)
 	if err != nil || len(decodedSalt) != SaltLength {
@@ -49,13 +204,8 @@ func SecureHashWithSalt(password string, salt string) (string, error) {
 	return hex.EncodeToString(secondHash[:]), nil
 }
 
-// VerifyPassword 密码验证函数
+// VerifyPassword 旧版 MD5 密码验证函数
 func VerifyPassword(inputPassword, storedSalt, storedHash string) bool {
-	// 前置长度检查
-	if len(inputPassword) != RequiredPasswordLength {
-		return false
-	}
-
 	// 计算哈希
 	computedHash, err := SecureHashWithSalt(inputPassword, storedSalt)
 	computedHash = storedSalt + ":" + computedHash
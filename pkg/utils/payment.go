@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 支付渠道
+const (
+	PaymentChannelAlipay  = "alipay"  // 支付宝
+	PaymentChannelWechat  = "wechat"  // 微信支付
+	PaymentChannelOffline = "offline" // 线下转账，由财务人工确认
+)
+
+// 支付渠道中的交易状态
+const (
+	TradeStatusWaitPay = "wait_pay" // 等待付款
+	TradeStatusPaid    = "paid"     // 已付款
+	TradeStatusClosed  = "closed"   // 已关闭或全额退款
+)
+
+// 支付渠道相关错误
+var (
+	ErrPaymentChannel      = errors.New("不支持的支付渠道")
+	ErrPaymentNotSupported = errors.New("该支付渠道不支持此操作")
+)
+
+// PaymentOrderRequest 向支付渠道下单的参数
+type PaymentOrderRequest struct {
+	OrderNo  string    // 商户订单号
+	Subject  string    // 订单标题
+	Amount   float64   // 订单金额(元)
+	ExpireAt time.Time // 支付截止时间，到期后渠道自动关闭交易，为空时使用渠道默认值
+}
+
+// PaymentPrepay 下单结果，不同渠道返回的支付方式不同
+type PaymentPrepay struct {
+	PayURL       string `json:"pay_url,omitempty"`      // 支付宝跳转地址或微信支付二维码链接
+	Instructions string `json:"instructions,omitempty"` // 线下转账的收款账户说明
+}
+
+// PaymentNotify 校验通过的支付渠道异步通知
+type PaymentNotify struct {
+	OrderNo string    // 商户订单号
+	TradeNo string    // 渠道交易号
+	Status  string    // 交易状态
+	Amount  float64   // 交易金额(元)
+	PaidAt  time.Time // 支付时间
+	Payload string    // 通知原文，保存到支付订单中
+}
+
+// RemoteTrade 支付渠道中查询到的交易
+type RemoteTrade struct {
+	Exists      bool    // 支付渠道中是否存在该交易
+	TradeNo     string  // 渠道交易号
+	Status      string  // 交易状态
+	TotalAmount float64 // 交易金额
+}
+
+// TradeQuerier 按商户订单号查询支付渠道中的交易，对账时使用
+// 测试时可以替换为模拟实现
+type TradeQuerier interface {
+	QueryTrade(ctx context.Context, outTradeNo string) (RemoteTrade, error)
+}
+
+// TradeRefunder 按商户订单号向支付渠道发起退款
+// refundNo 为退款请求号，同一请求号重复调用不会重复退款；total 为原订单金额
+type TradeRefunder interface {
+	RefundTrade(ctx context.Context, outTradeNo string, refundNo string, amount float64, total float64, reason string) error
+}
+
+// TradeCloser 按商户订单号关闭支付渠道中未支付的交易
+// 交易不存在或已关闭时返回 nil；交易已支付时返回错误，款项由支付通知按需要退款处理
+type TradeCloser interface {
+	CloseTrade(ctx context.Context, outTradeNo string) error
+}
+
+// PaymentProvider 支付渠道
+// 下单、校验异步通知、查询交易、关闭交易、退款，新增渠道时实现该接口并调用 RegisterPaymentProvider
+type PaymentProvider interface {
+	TradeQuerier
+	TradeRefunder
+	TradeCloser
+	// Channel 渠道名称，与支付订单中的 channel 字段一致
+	Channel() string
+	// CreateOrder 向支付渠道下单
+	CreateOrder(ctx context.Context, req PaymentOrderRequest) (PaymentPrepay, error)
+	// VerifyNotify 校验并解析支付渠道的异步通知
+	VerifyNotify(r *http.Request) (PaymentNotify, error)
+	// AckNotify 按渠道要求的格式应答异步通知，ok 为 false 时渠道会重试
+	AckNotify(w http.ResponseWriter, ok bool)
+}
+
+var (
+	paymentProviders   = map[string]PaymentProvider{}
+	paymentProvidersMu sync.RWMutex
+)
+
+// RegisterPaymentProvider 注册支付渠道，同名渠道会被替换
+func RegisterPaymentProvider(p PaymentProvider) {
+	paymentProvidersMu.Lock()
+	defer paymentProvidersMu.Unlock()
+	paymentProviders[p.Channel()] = p
+}
+
+// GetPaymentProvider 按渠道名称获取支付渠道
+func GetPaymentProvider(channel string) (PaymentProvider, error) {
+	paymentProvidersMu.RLock()
+	defer paymentProvidersMu.RUnlock()
+	p, ok := paymentProviders[channel]
+	if !ok {
+		return nil, ErrPaymentChannel
+	}
+	return p, nil
+}
+
+// GetTradeRefunder 按渠道名称获取退款接口
+func GetTradeRefunder(channel string) (TradeRefunder, error) {
+	return GetPaymentProvider(channel)
+}
+
+// GetTradeCloser 按渠道名称获取关闭交易接口
+func GetTradeCloser(channel string) (TradeCloser, error) {
+	return GetPaymentProvider(channel)
+}
+
+// PaymentChannels 已注册的支付渠道名称
+func PaymentChannels() []string {
+	paymentProvidersMu.RLock()
+	defer paymentProvidersMu.RUnlock()
+	channels := make([]string, 0, len(paymentProviders))
+	for c := range paymentProviders {
+		channels = append(channels, c)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// FormatAmount 金额格式化为两位小数(元)
+func FormatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// PaymentSettings 支付订单配置
+type PaymentSettings struct {
+	OrderExpire   time.Duration `json:"order_expire"`   // 渠道订单的支付有效期，到期后渠道关闭交易
+	CloseInterval time.Duration `json:"close_interval"` // 重试关闭渠道交易的间隔
+}
+
+// PaymentConfig 全局支付订单配置
+var PaymentConfig PaymentSettings
+
+// getPaymentConfig 读取支付订单配置
+func getPaymentConfig() PaymentSettings {
+	m := PaymentSettings{OrderExpire: 2 * time.Hour, CloseInterval: 5 * time.Minute}
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("./config")
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error("读取配置错误")
+		return m
+	}
+	if viper.IsSet("payment.order_expire") {
+		m.OrderExpire = viper.GetDuration("payment.order_expire")
+	}
+	if viper.IsSet("payment.close_interval") {
+		m.CloseInterval = viper.GetDuration("payment.close_interval")
+	}
+	return m
+}
+
+// OfflinePay 线下转账收款账户配置
+type OfflinePay struct {
+	AccountName string `json:"account_name"` // 收款户名
+	AccountNo   string `json:"account_no"`   // 收款账号
+	BankName    string `json:"bank_name"`    // 开户行
+}
+
+// getOfflinePayConfig 读取线下转账配置
+func getOfflinePayConfig() OfflinePay {
+	m := OfflinePay{}
+	viper.SetConfigName("config")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("./config")
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error("读取配置错误")
+	}
+	m.AccountName = viper.GetString("offline_pay.account_name")
+	m.AccountNo = viper.GetString("offline_pay.account_no")
+	m.BankName = viper.GetString("offline_pay.bank_name")
+	return m
+}
+
+// OfflineProvider 线下转账
+// 申请人按收款账户转账后上传凭证，财务核对到账后人工确认，没有异步通知和渠道查询
+type OfflineProvider struct {
+	Config OfflinePay
+}
+
+// Channel 渠道名称
+func (OfflineProvider) Channel() string {
+	return PaymentChannelOffline
+}
+
+// CreateOrder 返回收款账户说明，转账附言需填写订单号以便财务核对
+func (p OfflineProvider) CreateOrder(ctx context.Context, req PaymentOrderRequest) (PaymentPrepay, error) {
+	return PaymentPrepay{
+		Instructions: "请转账 " + FormatAmount(req.Amount) + " 元至 " + p.Config.BankName + " " +
+			p.Config.AccountName + " " + p.Config.AccountNo + "，附言填写订单号 " + req.OrderNo + "，转账后上传凭证等待财务确认",
+	}, nil
+}
+
+// VerifyNotify 线下转账没有异步通知
+func (OfflineProvider) VerifyNotify(r *http.Request) (PaymentNotify, error) {
+	return PaymentNotify{}, ErrPaymentNotSupported
+}
+
+// AckNotify 线下转账没有异步通知
+func (OfflineProvider) AckNotify(w http.ResponseWriter, ok bool) {
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// QueryTrade 线下转账无法查询，对账时跳过
+func (OfflineProvider) QueryTrade(ctx context.Context, outTradeNo string) (RemoteTrade, error) {
+	return RemoteTrade{}, ErrPaymentNotSupported
+}
+
+// CloseTrade 线下转账没有渠道交易，无需关闭
+func (OfflineProvider) CloseTrade(ctx context.Context, outTradeNo string) error {
+	return nil
+}
+
+// RefundTrade 线下退款由财务人工转账，这里只记账不调用任何接口
+func (OfflineProvider) RefundTrade(ctx context.Context, outTradeNo string, refundNo string, amount float64, total float64, reason string) error {
+	return nil
+}
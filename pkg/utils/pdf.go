@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf16"
+)
+
+// A4 纸张尺寸(pt)
+const (
+	PDFPageWidth  = 595.28
+	PDFPageHeight = 841.89
+)
+
+// PDFDoc 最小化的单页 PDF 生成器，只支持文字和直线，用于生成收据等简单单据
+// 中文使用阅读器内置的 STSong-Light 字体，不嵌入字体文件，生成的文件只有几KB
+// 坐标原点在页面左下角，单位为 pt
+type PDFDoc struct {
+	content bytes.Buffer
+}
+
+// NewPDFDoc 创建空白的 A4 页面
+func NewPDFDoc() *PDFDoc {
+	return &PDFDoc{}
+}
+
+// Text 在 (x, y) 处写一行文字，size 为字号
+func (d *PDFDoc) Text(x float64, y float64, size float64, s string) {
+	fmt.Fprintf(&d.content, "BT /F1 %s Tf %s %s Td <%s> Tj ET\n",
+		pdfNum(size), pdfNum(x), pdfNum(y), pdfUCS2Hex(s))
+}
+
+// Line 从 (x1, y1) 到 (x2, y2) 画一条线，width 为线宽
+func (d *PDFDoc) Line(x1 float64, y1 float64, x2 float64, y2 float64, width float64) {
+	fmt.Fprintf(&d.content, "%s w %s %s m %s %s l S\n",
+		pdfNum(width), pdfNum(x1), pdfNum(y1), pdfNum(x2), pdfNum(y2))
+}
+
+// TextWidth 估算文字宽度：中文为全角，ASCII 为半角
+func TextWidth(s string, size float64) float64 {
+	var w float64
+	for _, r := range s {
+		if r < 0x80 {
+			w += size / 2
+		} else {
+			w += size
+		}
+	}
+	return w
+}
+
+// Bytes 生成完整的 PDF 文件内容
+func (d *PDFDoc) Bytes() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+			pdfNum(PDFPageWidth), pdfNum(PDFPageHeight)),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", d.content.Len(), d.content.String()),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [6 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light " +
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> " +
+			"/FontDescriptor 7 0 R /DW 1000 /W [1 95 500] >>",
+		"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] " +
+			"/ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfNum 数字格式化，最多保留两位小数
+func pdfNum(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+// pdfUCS2Hex 文字转换为 UCS-2 大端十六进制串，UniGB-UCS2-H 编码只支持基本平面，其余字符替换为问号
+func pdfUCS2Hex(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		fmt.Fprintf(&buf, "%04X", r)
+	}
+	return buf.String()
+}
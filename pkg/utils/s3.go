@@ -0,0 +1,341 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 签名算法和不计算请求体哈希时使用的占位值
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Config S3 或 MinIO 对象存储配置
+type S3Config struct {
+	Endpoint  string `json:"endpoint"`   // 服务地址，例如 https://s3.amazonaws.com、http://127.0.0.1:9000
+	Region    string `json:"region"`     // 区域，MinIO 默认 us-east-1
+	Bucket    string `json:"bucket"`     // 存储桶
+	AccessKey string `json:"access_key"` // 访问密钥ID
+	SecretKey string `json:"secret_key"` // 访问密钥
+	PathStyle bool   `json:"path_style"` // 使用 endpoint/bucket/key 形式的地址，MinIO 需要开启
+	PublicURL string `json:"public_url"` // 公开读地址前缀，为空时使用存储桶地址
+}
+
+// S3Storage S3 协议对象存储，使用 AWS Signature V4 签名
+// 请求体不参与签名(UNSIGNED-PAYLOAD)，上传大文件时不需要先计算哈希
+type S3Storage struct {
+	config S3Config
+	base   *url.URL
+	client *http.Client
+	// Now 签名使用的当前时间，测试时可替换
+	Now func() time.Time
+}
+
+// NewS3Storage 创建 S3 对象存储
+func NewS3Storage(config S3Config) (*S3Storage, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("对象存储配置不完整")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	base, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || base.Host == "" {
+		return nil, errors.New("对象存储地址无效: " + config.Endpoint)
+	}
+	return &S3Storage{
+		config: config,
+		base:   base,
+		client: &http.Client{Timeout: 5 * time.Minute},
+		Now:    time.Now,
+	}, nil
+}
+
+// objectURL 对象地址，key 为空时为存储桶地址
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.base
+	p := strings.TrimRight(u.Path, "/")
+	if s.config.PathStyle {
+		p += "/" + s.config.Bucket
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+	}
+	u.Path = p + "/" + key
+	u.RawPath = s3EscapePath(u.Path)
+	return &u
+}
+
+// s3Escape 按 SigV4 规则编码，只保留字母、数字和 -_.~
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// s3EscapePath 编码对象路径，保留路径分隔符
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+// s3CanonicalQuery 按参数名排序并编码查询参数
+func s3CanonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string{}, q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// scope 签名范围：日期/区域/s3/aws4_request
+func (s *S3Storage) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.config.Region + "/s3/aws4_request"
+}
+
+// signature 计算 SigV4 签名
+// headers 为参与签名的请求头，名称需为小写
+func (s *S3Storage) signature(method string, u *url.URL, query url.Values, headers map[string]string, payloadHash string, t time.Time) (string, string) {
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		s3CanonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		t.Format("20060102T150405Z"),
+		s.scope(t),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), t.Format("20060102"))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign)), signedHeaders
+}
+
+// do 发送签名后的请求，调用方负责关闭响应体
+func (s *S3Storage) do(ctx context.Context, method string, key string, query url.Values, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	u := s.objectURL(key)
+	u.RawQuery = s3CanonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	t := s.Now().UTC()
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": s3UnsignedPayload,
+		"x-amz-date":           t.Format("20060102T150405Z"),
+	}
+	sig, signedHeaders := s.signature(method, u, query, headers, s3UnsignedPayload, t)
+	req.Header.Set("X-Amz-Content-Sha256", headers["x-amz-content-sha256"])
+	req.Header.Set("X-Amz-Date", headers["x-amz-date"])
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.config.AccessKey, s.scope(t), signedHeaders, sig))
+	return s.client.Do(req)
+}
+
+// s3Error 读取错误响应
+func s3Error(rsp *http.Response) error {
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(rsp.Body, 4096))
+	if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+		return fmt.Errorf("对象存储请求失败: %d %s %s", rsp.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Errorf("对象存储请求失败: %d", rsp.StatusCode)
+}
+
+// Put 上传对象，大小未知时先读入内存
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+	rsp, err := s.do(ctx, http.MethodPut, key, nil, r, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return s3Error(rsp)
+	}
+	return nil
+}
+
+// Get 下载对象
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	rsp, err := s.do(ctx, http.MethodGet, key, nil, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode == http.StatusOK {
+		return rsp.Body, nil
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotFound
+	}
+	return nil, s3Error(rsp)
+}
+
+// s3ListResult ListObjectsV2 的响应
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// List 使用 ListObjectsV2 分页列出 prefix 下的全部对象
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	var objects []StorageObject
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		rsp, err := s.do(ctx, http.MethodGet, "", query, nil, 0, "")
+		if err != nil {
+			return nil, err
+		}
+		if rsp.StatusCode != http.StatusOK {
+			err := s3Error(rsp)
+			rsp.Body.Close()
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(rsp.Body).Decode(&result)
+		rsp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			objects = append(objects, StorageObject{Key: c.Key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Delete 删除对象
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return err
+	}
+	rsp, err := s.do(ctx, http.MethodDelete, key, nil, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode == http.StatusNoContent || rsp.StatusCode == http.StatusOK || rsp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return s3Error(rsp)
+}
+
+// PresignedURL 生成预签名下载地址，签名参数放在查询字符串中
+func (s *S3Storage) PresignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	u := s.objectURL(key)
+	t := s.Now().UTC()
+	query := url.Values{
+		"X-Amz-Algorithm":     {s3Algorithm},
+		"X-Amz-Credential":    {s.config.AccessKey + "/" + s.scope(t)},
+		"X-Amz-Date":          {t.Format("20060102T150405Z")},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires / time.Second))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	sig, _ := s.signature(http.MethodGet, u, query, map[string]string{"host": u.Host}, s3UnsignedPayload, t)
+	u.RawQuery = s3CanonicalQuery(query) + "&X-Amz-Signature=" + sig
+	return u.String(), nil
+}
+
+// URL 公开访问地址，需要存储桶或 PublicURL 对应的路径允许匿名读取
+func (s *S3Storage) URL(key string) string {
+	if s.config.PublicURL != "" {
+		return strings.TrimRight(s.config.PublicURL, "/") + "/" + s3EscapePath(key)
+	}
+	return s.objectURL(key).String()
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 定时任务锁在 redis 中的 key 前缀
+const jobLockPrefix = "job_lock:"
+
+// renewLockScript 只有锁仍由自己持有时才延长过期时间
+var renewLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// TryLock 尝试获取 redis 分布式锁，获取成功时返回锁的 token，续期时用于确认锁仍由自己持有
+// 锁不主动释放，在 ttl 后自动过期
+func TryLock(key string, ttl time.Duration) (string, bool, error) {
+	token, err := randomToken(16)
+	if err != nil {
+		return "", false, err
+	}
+	ok, err := RDB.SetNX(ctx, key, token, ttl).Result()
+	return token, ok, err
+}
+
+// RenewLock 延长自己持有的锁，锁已过期或被其他实例持有时返回 false
+func RenewLock(key string, token string, ttl time.Duration) (bool, error) {
+	n, err := renewLockScript.Run(ctx, RDB, []string{key}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// RunPeriodicJob 每隔 interval 执行一次定时任务，直到 c 结束
+// 多个实例同时运行时，每个周期只有抢到锁的实例执行；锁在执行期间续期，结束后不主动释放，再过 interval 后过期，
+// 这样各实例的定时器即使错开，同一周期内也不会重复执行
+func RunPeriodicJob(c context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	if interval <= 0 {
+		Logger.Error("定时任务间隔无效，不启动: " + name)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		runJobOnce(c, name, interval, job)
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runJobOnce 抢锁后执行一次定时任务
+// 执行期间定期续期，执行时间超过 interval 时其他实例也不会开始同一任务；续期失败说明锁已丢失，取消本次执行
+func runJobOnce(c context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	key := jobLockPrefix + name
+	token, ok, err := TryLock(key, interval)
+	if err != nil {
+		Logger.Error("定时任务获取锁失败: " + name + " " + err.Error())
+		return
+	}
+	if !ok {
+		return
+	}
+	jobCtx, cancel := context.WithCancel(c)
+	defer cancel()
+	go keepJobLock(jobCtx, cancel, name, key, token, interval)
+
+	defer func() {
+		if r := recover(); r != nil {
+			Logger.Error(fmt.Sprintf("定时任务异常: %s %v\n%s", name, r, debug.Stack()))
+		}
+	}()
+	if err := job(jobCtx); err != nil {
+		Logger.Error("定时任务执行失败: " + name + " " + err.Error())
+	}
+}
+
+// keepJobLock 任务执行期间每隔 ttl/3 续期一次，任务结束取消 c 后停止
+func keepJobLock(c context.Context, cancel context.CancelFunc, name, key, token string, ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := RenewLock(key, token, ttl)
+		if err != nil {
+			// redis 暂时不可用时继续执行，锁在 ttl 内仍然有效，下次续期再试
+			Logger.Warn("定时任务续期锁失败: " + name + " " + err.Error())
+			continue
+		}
+		if !ok {
+			Logger.Error("定时任务的锁已丢失，取消本次执行: " + name)
+			cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 附件存储驱动
+const (
+	StorageDriverLocal = "local" // 本地磁盘，头像由 nginx 提供下载，其他文件经服务端鉴权后下载
+	StorageDriverS3    = "s3"    // S3 或 MinIO 等兼容 S3 协议的对象存储
+)
+
+// 附件存储相关错误
+var (
+	ErrObjectNotFound = errors.New("文件不存在")
+	ErrStorageKey     = errors.New("非法的文件路径")
+	ErrNotPublic      = errors.New("该存储的文件不能公开访问")
+)
+
+// Store 全局附件存储，专利、著作、商标附件、转账凭证和收据等都保存在这里
+// 这些文件不能公开访问，只能经服务端鉴权后读取
+var Store Storage
+
+// PublicStore 公开存储，只用于头像等保存公开地址的文件
+var PublicStore Storage
+
+// StorageObject 存储中的一个文件
+type StorageObject struct {
+	Key     string    `json:"key"`      // 文件路径，例如 patent/CN2026.../说明书.pdf
+	Size    int64     `json:"size"`     // 文件大小(字节)
+	ModTime time.Time `json:"mod_time"` // 最后修改时间
+}
+
+// Storage 附件存储
+// key 使用 / 分隔的相对路径，第一级为文件类别(patent、article、trademark、avatar、voucher)
+// 多实例部署时使用对象存储，单机部署可以继续使用本地磁盘
+type Storage interface {
+	// Put 写入文件，同名文件会被覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取文件，不存在时返回 ErrObjectNotFound，调用方负责关闭
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List 列出 prefix 下的全部文件，包括子目录中的文件，按 key 排序
+	List(ctx context.Context, prefix string) ([]StorageObject, error)
+	// Delete 删除文件，文件不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// PresignedURL 生成有效期为 expires 的临时下载地址
+	PresignedURL(ctx context.Context, key string, expires time.Duration) (string, error)
+	// URL 长期有效的公开访问地址，用于头像等需要保存到数据库的地址
+	URL(key string) string
+}
+
+// StorageConfig 附件存储配置
+type StorageConfig struct {
+	Driver       string        `json:"driver"` // local 或 s3
+	Local        LocalConfig   `json:"local"`
+	S3           S3Config      `json:"s3"`
+	TaskInterval time.Duration `json:"task_interval"` // 重试未完成的文件删除任务的间隔
+}
+
+// StorageSettings 全局附件存储配置
+var StorageSettings StorageConfig
+
+// LocalConfig 本地磁盘存储配置
+type LocalConfig struct {
+	Root       string `json:"root"`        // 根目录，不能位于 nginx 提供访问的目录下
+	PublicRoot string `json:"public_root"` // 公开文件根目录，nginx 的 html 目录
+	BaseURL    string `json:"base_url"`    // nginx 访问地址，公开文件的地址前缀
+}
+
+// 本地存储默认的根目录
+const (
+	defaultLocalRoot       = "./data/storage"
+	defaultLocalPublicRoot = "./data/public"
+)
+
+// getStorageConfig 读取附件存储配置文件
+// 没有配置时使用本地磁盘，公开文件的根目录为 nginx.yaml 中专利目录的上级目录，与原有头像位置保持一致
+func getStorageConfig() StorageConfig {
+	m := StorageConfig{
+		Driver: StorageDriverLocal,
+		Local: LocalConfig{
+			Root:       defaultLocalRoot,
+			PublicRoot: defaultLocalPublicRoot,
+			BaseURL:    NgX.Url,
+		},
+		TaskInterval: time.Minute,
+	}
+	if NgX.LocationPatent != "" {
+		m.Local.PublicRoot = parentDir(NgX.LocationPatent)
+	}
+	viper.SetConfigName("storage")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("./config")
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error("读取配置错误")
+		return m
+	}
+	if viper.IsSet("storage.driver") {
+		m.Driver = viper.GetString("storage.driver")
+	}
+	if viper.IsSet("storage.local.root") {
+		m.Local.Root = viper.GetString("storage.local.root")
+	}
+	if viper.IsSet("storage.local.public_root") {
+		m.Local.PublicRoot = viper.GetString("storage.local.public_root")
+	}
+	if viper.IsSet("storage.local.base_url") {
+		m.Local.BaseURL = viper.GetString("storage.local.base_url")
+	}
+	m.S3.Endpoint = viper.GetString("storage.s3.endpoint")
+	m.S3.Region = viper.GetString("storage.s3.region")
+	m.S3.Bucket = viper.GetString("storage.s3.bucket")
+	m.S3.AccessKey = viper.GetString("storage.s3.access_key")
+	m.S3.SecretKey = viper.GetString("storage.s3.secret_key")
+	m.S3.PathStyle = viper.GetBool("storage.s3.path_style")
+	m.S3.PublicURL = viper.GetString("storage.s3.public_url")
+	if viper.IsSet("storage.task_interval") {
+		m.TaskInterval = viper.GetDuration("storage.task_interval")
+	}
+	return m
+}
+
+// parentDir 上级目录，nginx.yaml 中可能是 Windows 路径，两种分隔符都要识别
+func parentDir(p string) string {
+	p = strings.TrimRight(p, `/\`)
+	if i := strings.LastIndexAny(p, `/\`); i >= 0 {
+		return p[:i]
+	}
+	return "."
+}
+
+// NewStorage 按配置创建附件存储
+// 本地磁盘的根目录不能位于公开目录下，否则附件可以绕过鉴权直接从 nginx 下载
+func NewStorage(cfg StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case StorageDriverLocal, "":
+		if err := checkPrivateRoot(cfg.Local.Root, cfg.Local.PublicRoot); err != nil {
+			return nil, err
+		}
+		return NewLocalStorage(LocalConfig{Root: cfg.Local.Root}), nil
+	case StorageDriverS3:
+		return NewS3Storage(cfg.S3)
+	}
+	return nil, errors.New("不支持的存储驱动: " + cfg.Driver)
+}
+
+// NewPublicStorage 按配置创建公开存储
+// 对象存储与附件使用同一个存储桶，存储桶策略只能允许匿名读取 avatar/ 下的文件
+func NewPublicStorage(cfg StorageConfig) (Storage, error) {
+	switch cfg.Driver {
+	case StorageDriverLocal, "":
+		if cfg.Local.PublicRoot == "" {
+			return nil, errors.New("未配置公开文件根目录 storage.local.public_root")
+		}
+		return NewLocalStorage(LocalConfig{Root: cfg.Local.PublicRoot, BaseURL: cfg.Local.BaseURL}), nil
+	case StorageDriverS3:
+		return NewS3Storage(cfg.S3)
+	}
+	return nil, errors.New("不支持的存储驱动: " + cfg.Driver)
+}
+
+// checkPrivateRoot 校验附件根目录与公开目录不重叠
+func checkPrivateRoot(root, publicRoot string) error {
+	if root == "" {
+		return errors.New("未配置附件根目录 storage.local.root")
+	}
+	if publicRoot == "" {
+		return nil
+	}
+	r, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	p, err := filepath.Abs(filepath.FromSlash(strings.ReplaceAll(publicRoot, `\`, "/")))
+	if err != nil {
+		return err
+	}
+	if isSubDir(p, r) || isSubDir(r, p) {
+		return errors.New("附件根目录 " + root + " 与公开目录 " + publicRoot + " 重叠")
+	}
+	return nil
+}
+
+// isSubDir dir 是否为 parent 或其子目录
+func isSubDir(parent, dir string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// CleanStorageKey 校验并规范化 key，拒绝绝对路径和 .. 等越出根目录的路径
+func CleanStorageKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", ErrStorageKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", ErrStorageKey
+		}
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." {
+		return "", ErrStorageKey
+	}
+	return cleaned, nil
+}
+
+// PutUploadedFile 把表单上传的文件写入存储
+func PutUploadedFile(ctx context.Context, s Storage, key string, fh *multipart.FileHeader) error {
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Put(ctx, key, f, fh.Size, fh.Header.Get("Content-Type"))
+}
+
+// DeletePrefix 删除 prefix 下的全部文件
+func DeletePrefix(ctx context.Context, s Storage, prefix string) error {
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := s.Delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LocalStorage 本地磁盘存储
+// 文件保存在 Root/key；配置了 BaseURL 时为公开存储，下载地址为 BaseURL/key，由 nginx 直接提供
+type LocalStorage struct {
+	Root    string
+	BaseURL string
+}
+
+// NewLocalStorage 创建本地磁盘存储
+func NewLocalStorage(cfg LocalConfig) *LocalStorage {
+	return &LocalStorage{Root: cfg.Root, BaseURL: strings.TrimRight(cfg.BaseURL, "/")}
+}
+
+// path 文件在磁盘上的路径
+func (s *LocalStorage) path(key string) (string, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
+
+// Put 写入文件，先写临时文件再改名，避免读到写了一半的文件
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get 读取文件
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return f, err
+}
+
+// List 列出 prefix 下的全部文件
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	dir := strings.TrimSuffix(prefix, "/")
+	p, err := s.path(dir)
+	if err != nil {
+		return nil, err
+	}
+	var objects []StorageObject
+	err = filepath.WalkDir(p, func(fp string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// 跳过写入中的临时文件
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.Root, fp)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, StorageObject{Key: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Delete 删除文件，并清理删除后为空的目录
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	root := filepath.Clean(s.Root)
+	for dir := filepath.Dir(p); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// PresignedURL 本地存储由 nginx 直接提供文件，无法签名，返回公开地址
+// 没有配置 BaseURL 的存储不能公开访问，返回 ErrNotPublic
+func (s *LocalStorage) PresignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	if s.BaseURL == "" {
+		return "", ErrNotPublic
+	}
+	return s.URL(key), nil
+}
+
+// URL 公开访问地址，没有配置 BaseURL 时为空
+func (s *LocalStorage) URL(key string) string {
+	if s.BaseURL == "" {
+		return ""
+	}
+	return s.BaseURL + "/" + key
+}
@@ -39,3 +39,17 @@ func ConvertStringSliceToInt(strSlice []string) []int {
 
 	return result
 }
+
+// TruncateString 把字符串截断为最多 n 个字符，不会截断多字节字符
+// 无效的 UTF-8 字节替换为 U+FFFD，MySQL 严格模式下无效字节或截断的字符都会导致写入失败
+func TruncateString(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
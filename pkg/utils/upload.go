@@ -0,0 +1,425 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 GIF 解码器
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// 上传文件的类别，与资产类型一致，另有头像和转账凭证
+const (
+	UploadAvatar  = "avatar"
+	UploadVoucher = "voucher"
+)
+
+// 文件名最长字节数，超出时截断主文件名并保留扩展名
+const maxFileNameBytes = 200
+
+// 头像最大像素数，先读取图片尺寸，超出时不解码，防止解压炸弹
+const maxAvatarPixels = 4096 * 4096
+
+// MaxUploadFormFiles 新建、重新提交申请时一个表单中的文件数上限，用于计算请求体大小上限
+const MaxUploadFormFiles = 10
+
+// 表单中文件以外的字段和 multipart 分隔符预留的大小
+const uploadFormOverhead = 1 << 20
+
+// 上传校验错误
+var (
+	ErrUploadName     = errors.New("文件名无效")
+	ErrUploadTooLarge = errors.New("文件大小超出限制")
+	ErrUploadType     = errors.New("不支持的文件类型")
+	ErrUploadImage    = errors.New("图片无法识别或尺寸过大")
+	ErrUploadInfected = errors.New("文件未通过病毒扫描，已被隔离")
+	ErrScanFailed     = errors.New("病毒扫描服务不可用")
+)
+
+// UploadPolicy 某类上传文件的限制
+type UploadPolicy struct {
+	MaxSize    int64    `json:"max_size"`   // 最大字节数
+	Extensions []string `json:"extensions"` // 允许的扩展名，小写，带点
+	MimeTypes  []string `json:"mime_types"` // 允许的内容类型，按文件内容识别，不信任客户端的 Content-Type
+}
+
+// ResumableSettings 分片上传配置
+type ResumableSettings struct {
+	ChunkSize       int64         `json:"chunk_size"`       // 分片大小，最后一片可以更小
+	MaxSize         int64         `json:"max_size"`         // 分片上传的文件大小上限，代替各类别的 MaxSize
+	Expire          time.Duration `json:"expire"`           // 未完成的上传保留时长，过期后删除已上传的分片
+	CleanupInterval time.Duration `json:"cleanup_interval"` // 清理过期上传的间隔
+}
+
+// UploadSettings 上传校验配置
+type UploadSettings struct {
+	Policies         map[string]UploadPolicy `json:"policies"`
+	Scanner          ScannerConfig           `json:"scanner"`
+	QuarantinePrefix string                  `json:"quarantine_prefix"` // 隔离区在存储中的目录
+	Resumable        ResumableSettings       `json:"resumable"`
+}
+
+// UploadConfig 全局上传校验配置
+var UploadConfig UploadSettings
+
+// Office 文档按内容识别为 zip(docx) 或 application/octet-stream(doc)
+var documentMimeTypes = []string{"application/pdf", "application/zip", "application/octet-stream", "text/plain", "image/jpeg", "image/png"}
+
+// extensionMimeTypes 各扩展名按内容识别时允许的类型，扩展名和内容必须对应
+// 无法识别的内容为 application/octet-stream，只有旧版 Word 文档(.doc)允许
+var extensionMimeTypes = map[string][]string{
+	".pdf":  {"application/pdf"},
+	".doc":  {"application/octet-stream"},
+	".docx": {"application/zip"},
+	".zip":  {"application/zip"},
+	".txt":  {"text/plain"},
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".png":  {"image/png"},
+	".gif":  {"image/gif"},
+}
+
+// defaultUploadPolicies 没有配置时的默认限制
+func defaultUploadPolicies() map[string]UploadPolicy {
+	const mb = 1 << 20
+	return map[string]UploadPolicy{
+		"patent": {
+			MaxSize:    50 * mb,
+			Extensions: []string{".pdf", ".doc", ".docx", ".txt", ".jpg", ".jpeg", ".png", ".zip"},
+			MimeTypes:  documentMimeTypes,
+		},
+		"article": {
+			MaxSize:    50 * mb,
+			Extensions: []string{".pdf", ".doc", ".docx", ".txt", ".jpg", ".jpeg", ".png", ".zip"},
+			MimeTypes:  documentMimeTypes,
+		},
+		"trademark": {
+			MaxSize:    20 * mb,
+			Extensions: []string{".pdf", ".jpg", ".jpeg", ".png", ".gif"},
+			MimeTypes:  []string{"application/pdf", "image/jpeg", "image/png", "image/gif"},
+		},
+		UploadAvatar: {
+			MaxSize:    5 * mb,
+			Extensions: []string{".jpg", ".jpeg", ".png", ".gif"},
+			MimeTypes:  []string{"image/jpeg", "image/png", "image/gif"},
+		},
+		UploadVoucher: {
+			MaxSize:    10 * mb,
+			Extensions: []string{".pdf", ".jpg", ".jpeg", ".png"},
+			MimeTypes:  []string{"application/pdf", "image/jpeg", "image/png"},
+		},
+	}
+}
+
+// getUploadConfig 读取上传校验配置文件，未配置的类别使用默认限制
+func getUploadConfig() UploadSettings {
+	m := UploadSettings{
+		Policies:         defaultUploadPolicies(),
+		Scanner:          ScannerConfig{Driver: ScannerNone, Timeout: 30 * time.Second},
+		QuarantinePrefix: "quarantine/",
+		Resumable: ResumableSettings{
+			ChunkSize:       8 << 20,
+			MaxSize:         1 << 30,
+			Expire:          24 * time.Hour,
+			CleanupInterval: time.Hour,
+		},
+	}
+	viper.SetConfigName("upload")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("./config")
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error("读取配置错误")
+		return m
+	}
+	for category, policy := range m.Policies {
+		key := "upload.policies." + category
+		if viper.IsSet(key + ".max_size_mb") {
+			policy.MaxSize = viper.GetInt64(key+".max_size_mb") << 20
+		}
+		if viper.IsSet(key + ".extensions") {
+			policy.Extensions = viper.GetStringSlice(key + ".extensions")
+		}
+		if viper.IsSet(key + ".mime_types") {
+			policy.MimeTypes = viper.GetStringSlice(key + ".mime_types")
+		}
+		m.Policies[category] = policy
+	}
+	if viper.IsSet("upload.scanner.driver") {
+		m.Scanner.Driver = viper.GetString("upload.scanner.driver")
+	}
+	m.Scanner.Address = viper.GetString("upload.scanner.address")
+	if viper.IsSet("upload.scanner.timeout") {
+		m.Scanner.Timeout = viper.GetDuration("upload.scanner.timeout")
+	}
+	if viper.IsSet("upload.quarantine_prefix") {
+		m.QuarantinePrefix = strings.TrimSuffix(viper.GetString("upload.quarantine_prefix"), "/") + "/"
+	}
+	if viper.IsSet("upload.resumable.chunk_size_mb") {
+		m.Resumable.ChunkSize = viper.GetInt64("upload.resumable.chunk_size_mb") << 20
+	}
+	if viper.IsSet("upload.resumable.max_size_mb") {
+		m.Resumable.MaxSize = viper.GetInt64("upload.resumable.max_size_mb") << 20
+	}
+	if viper.IsSet("upload.resumable.expire") {
+		m.Resumable.Expire = viper.GetDuration("upload.resumable.expire")
+	}
+	if viper.IsSet("upload.resumable.cleanup_interval") {
+		m.Resumable.CleanupInterval = viper.GetDuration("upload.resumable.cleanup_interval")
+	}
+	return m
+}
+
+// SanitizeFileName 清理客户端提交的文件名
+// 去掉目录部分、控制字符和 Windows 保留字符，去掉首尾的空格和点，过长时截断主文件名；无法得到有效文件名时返回空
+func SanitizeFileName(filename string) string {
+	filename = strings.ReplaceAll(filename, "\\", "/")
+	name := path.Base(filename)
+	if name == "." || name == "/" {
+		return ""
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError || unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return ""
+	}
+	if len(name) > maxFileNameBytes {
+		ext := path.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		base := name[:len(name)-len(ext)]
+		for len(base)+len(ext) > maxFileNameBytes {
+			_, size := utf8.DecodeLastRuneInString(base)
+			base = base[:len(base)-size]
+		}
+		name = base + ext
+	}
+	return name
+}
+
+// Check 按文件名、大小和内容识别出的类型校验
+// 内容类型既要在类别允许的范围内，也要与扩展名对应，防止把其他文件改成允许的扩展名上传
+func (p UploadPolicy) Check(name string, size int64, contentType string) error {
+	if err := p.CheckName(name, size); err != nil {
+		return err
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !containsFold(p.MimeTypes, mediaType) {
+		return ErrUploadType
+	}
+	if !containsFold(extensionMimeTypes[strings.ToLower(path.Ext(name))], mediaType) {
+		return ErrUploadType
+	}
+	return nil
+}
+
+// CheckName 只按文件名和大小校验，分片上传开始前内容还未上传时使用
+func (p UploadPolicy) CheckName(name string, size int64) error {
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return ErrUploadTooLarge
+	}
+	if !containsFold(p.Extensions, strings.ToLower(path.Ext(name))) {
+		return ErrUploadType
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckedUpload 通过校验的上传文件
+type CheckedUpload struct {
+	Name     string // 清理后的文件名
+	Size     int64
+	MimeType string // 按内容识别的类型
+}
+
+// OpenFunc 打开上传文件的内容，校验、扫描和保存时各读取一次
+type OpenFunc func() (io.ReadCloser, error)
+
+// FileHeaderOpener 表单文件的 OpenFunc
+func FileHeaderOpener(fh *multipart.FileHeader) OpenFunc {
+	return func() (io.ReadCloser, error) {
+		return fh.Open()
+	}
+}
+
+// uploadPolicy 查询类别的限制，未知类别不允许上传
+func uploadPolicy(category string) (UploadPolicy, error) {
+	policy, ok := UploadConfig.Policies[category]
+	if !ok {
+		return UploadPolicy{}, ErrUploadType
+	}
+	return policy, nil
+}
+
+// UploadBodyLimit 上传请求体的大小上限，files 为表单中的文件数上限；类别未限制大小时返回 0
+func UploadBodyLimit(category string, files int) int64 {
+	policy, err := uploadPolicy(category)
+	if err != nil || policy.MaxSize <= 0 {
+		return 0
+	}
+	return policy.MaxSize*int64(files) + uploadFormOverhead
+}
+
+// ResumablePolicy 分片上传使用的限制，大小上限取类别限制和分片上传限制中较大的
+func ResumablePolicy(category string) (UploadPolicy, error) {
+	policy, err := uploadPolicy(category)
+	if err != nil {
+		return policy, err
+	}
+	if UploadConfig.Resumable.MaxSize > policy.MaxSize {
+		policy.MaxSize = UploadConfig.Resumable.MaxSize
+	}
+	return policy, nil
+}
+
+// PrecheckUpload 只校验文件名、大小和类型，不做病毒扫描
+func PrecheckUpload(category string, fh *multipart.FileHeader) (CheckedUpload, error) {
+	policy, err := uploadPolicy(category)
+	if err != nil {
+		return CheckedUpload{}, err
+	}
+	return precheckUpload(policy, fh.Filename, fh.Size, FileHeaderOpener(fh))
+}
+
+// precheckUpload 清理文件名，读取文件开头识别类型后按限制校验
+func precheckUpload(policy UploadPolicy, filename string, size int64, open OpenFunc) (CheckedUpload, error) {
+	name := SanitizeFileName(filename)
+	if name == "" {
+		return CheckedUpload{}, ErrUploadName
+	}
+	if policy.MaxSize > 0 && size > policy.MaxSize {
+		return CheckedUpload{}, ErrUploadTooLarge
+	}
+	f, err := open()
+	if err != nil {
+		return CheckedUpload{}, err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return CheckedUpload{}, err
+	}
+	checked := CheckedUpload{Name: name, Size: size, MimeType: http.DetectContentType(head[:n])}
+	if err := policy.Check(name, size, checked.MimeType); err != nil {
+		return CheckedUpload{}, err
+	}
+	return checked, nil
+}
+
+// ValidateUpload 校验上传文件并做病毒扫描
+// 扫描出病毒时把文件复制到隔离区并返回 ErrUploadInfected；扫描服务不可用时拒绝上传
+func ValidateUpload(ctx context.Context, category string, fh *multipart.FileHeader) (CheckedUpload, error) {
+	policy, err := uploadPolicy(category)
+	if err != nil {
+		return CheckedUpload{}, err
+	}
+	return validateUpload(ctx, category, policy, fh.Filename, fh.Size, FileHeaderOpener(fh))
+}
+
+// ValidateResumableUpload 校验分片上传合并后的文件并做病毒扫描
+func ValidateResumableUpload(ctx context.Context, category string, filename string, size int64, open OpenFunc) (CheckedUpload, error) {
+	policy, err := ResumablePolicy(category)
+	if err != nil {
+		return CheckedUpload{}, err
+	}
+	return validateUpload(ctx, category, policy, filename, size, open)
+}
+
+// validateUpload 按限制校验后做病毒扫描
+func validateUpload(ctx context.Context, category string, policy UploadPolicy, filename string, size int64, open OpenFunc) (CheckedUpload, error) {
+	checked, err := precheckUpload(policy, filename, size, open)
+	if err != nil {
+		return checked, err
+	}
+	f, err := open()
+	if err != nil {
+		return checked, err
+	}
+	defer f.Close()
+	result, err := UploadScanner.Scan(ctx, f)
+	if err != nil {
+		Logger.Error("病毒扫描失败: " + err.Error())
+		return checked, ErrScanFailed
+	}
+	if result.Infected {
+		quarantineUpload(ctx, category, checked.Name, size, open, result.Signature)
+		return checked, ErrUploadInfected
+	}
+	return checked, nil
+}
+
+// quarantineUpload 把感染文件保存到隔离区，供管理员核查，隔离失败只记录日志
+func quarantineUpload(ctx context.Context, category string, name string, size int64, open OpenFunc, signature string) {
+	now := time.Now()
+	key := fmt.Sprintf("%s%s/%s/%d-%s", UploadConfig.QuarantinePrefix, now.Format("20060102"), category, now.UnixNano(), name)
+	Logger.Warn(fmt.Sprintf("上传文件感染病毒 %s，已隔离到 %s", signature, key))
+	f, err := open()
+	if err != nil {
+		Logger.Error("隔离文件失败: " + err.Error())
+		return
+	}
+	defer f.Close()
+	if err := Store.Put(ctx, key, f, size, "application/octet-stream"); err != nil {
+		Logger.Error("隔离文件失败: " + err.Error())
+	}
+}
+
+// ReencodeImage 解码图片后重新编码为 PNG
+// 去掉 EXIF 等元数据以及附加在图片后面的其他内容，只保留像素
+func ReencodeImage(r io.Reader, maxPixels int) ([]byte, error) {
+	var buf bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &buf))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrUploadImage
+	}
+	img, _, err := image.Decode(io.MultiReader(&buf, r))
+	if err != nil {
+		return nil, ErrUploadImage
+	}
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// ReencodeAvatar 重新编码头像
+func ReencodeAvatar(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReencodeImage(f, maxAvatarPixels)
+}
@@ -0,0 +1,424 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 微信支付 API v3 官方网关
+const wechatPayGateway = "https://api.mch.weixin.qq.com"
+
+// 微信支付应答和通知的签名时间与本地时间最大允许偏差，超过视为重放
+const wechatPayMaxClockSkew = 5 * time.Minute
+
+// 微信支付相关错误
+var (
+	ErrWechatPaySignFail = errors.New("微信支付签名校验失败")
+	ErrWechatPayExpired  = errors.New("微信支付签名已过期")
+	ErrWechatPayMchID    = errors.New("微信支付通知的商户号不匹配")
+)
+
+// WechatPay 微信支付 API v3 配置
+type WechatPay struct {
+	MchID             string `json:"mch_id"`              // 商户号
+	AppID             string `json:"app_id"`              // 公众号或小程序 appid
+	SerialNo          string `json:"serial_no"`           // 商户 API 证书序列号
+	PrivateKey        string `json:"private_key"`         // 商户 API 私钥，PEM 或 base64 编码的 PKCS8
+	APIv3Key          string `json:"api_v3_key"`          // APIv3 密钥，用于解密通知
+	PlatformPublicKey string `json:"platform_public_key"` // 微信支付公钥，PEM 或 base64 编码的 PKIX
+	PlatformSerial    string `json:"platform_serial"`     // 微信支付公钥ID，为空时不校验
+	NotifyUrl         string `json:"notify_url"`          // 异步通知地址
+	Gateway           string `json:"gateway"`             // 自定义网关地址，为空时使用官方网关，测试时可指向本地模拟网关
+}
+
+// getWechatPayConfig 读取微信支付配置文件
+func getWechatPayConfig() WechatPay {
+	m := WechatPay{}
+	viper.SetConfigName("wechatpay")
+	viper.SetConfigType("yaml")
+	viper.AddConfigPath("./config")
+	if err := viper.ReadInConfig(); err != nil {
+		Logger.Error("读取配置错误")
+	}
+	m.MchID = viper.GetString("wechatpay.mch_id")
+	m.AppID = viper.GetString("wechatpay.app_id")
+	m.SerialNo = viper.GetString("wechatpay.serial_no")
+	m.PrivateKey = viper.GetString("wechatpay.private_key")
+	m.APIv3Key = viper.GetString("wechatpay.api_v3_key")
+	m.PlatformPublicKey = viper.GetString("wechatpay.platform_public_key")
+	m.PlatformSerial = viper.GetString("wechatpay.platform_serial")
+	m.NotifyUrl = viper.GetString("wechatpay.notify_url")
+	m.Gateway = viper.GetString("wechatpay.gateway")
+	return m
+}
+
+// WechatPayProvider 微信支付渠道，使用 Native 支付（扫码）
+type WechatPayProvider struct {
+	config     WechatPay
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+	client     *http.Client
+}
+
+// InitWechatPay 按配置创建微信支付渠道并注册
+// 未配置商户号时不启用
+func InitWechatPay(config WechatPay) error {
+	if config.MchID == "" {
+		return nil
+	}
+	p, err := NewWechatPayProvider(config)
+	if err != nil {
+		return err
+	}
+	RegisterPaymentProvider(p)
+	return nil
+}
+
+// NewWechatPayProvider 创建微信支付渠道
+func NewWechatPayProvider(config WechatPay) (*WechatPayProvider, error) {
+	if len(config.APIv3Key) != 32 {
+		return nil, errors.New("微信支付 APIv3 密钥长度必须为32位")
+	}
+	der, err := decodeKey(config.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("微信支付商户私钥必须为 RSA 私钥")
+	}
+	der, err = decodeKey(config.PlatformPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("微信支付公钥必须为 RSA 公钥")
+	}
+	if config.Gateway == "" {
+		config.Gateway = wechatPayGateway
+	}
+	return &WechatPayProvider{
+		config:     config,
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// decodeKey 解析 PEM 或 base64 编码的密钥，返回 DER
+func decodeKey(s string) ([]byte, error) {
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		return block.Bytes, nil
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+}
+
+// Channel 渠道名称
+func (p *WechatPayProvider) Channel() string {
+	return PaymentChannelWechat
+}
+
+// wechatAmount 微信支付金额，单位为分
+type wechatAmount struct {
+	Total    int64  `json:"total,omitempty"`
+	Refund   int64  `json:"refund,omitempty"`
+	Currency string `json:"currency,omitempty"`
+}
+
+// toFen 金额转换为分
+func toFen(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// CreateOrder Native 下单，返回支付二维码链接
+func (p *WechatPayProvider) CreateOrder(ctx context.Context, req PaymentOrderRequest) (PaymentPrepay, error) {
+	body := map[string]interface{}{
+		"appid":        p.config.AppID,
+		"mchid":        p.config.MchID,
+		"description":  req.Subject,
+		"out_trade_no": req.OrderNo,
+		"notify_url":   p.config.NotifyUrl,
+		"amount":       wechatAmount{Total: toFen(req.Amount), Currency: "CNY"},
+	}
+	if !req.ExpireAt.IsZero() {
+		body["time_expire"] = req.ExpireAt.Format(time.RFC3339)
+	}
+	var rsp struct {
+		CodeURL string `json:"code_url"`
+	}
+	if _, err := p.do(ctx, http.MethodPost, "/v3/pay/transactions/native", body, &rsp); err != nil {
+		return PaymentPrepay{}, err
+	}
+	return PaymentPrepay{PayURL: rsp.CodeURL}, nil
+}
+
+// wechatTransaction 微信支付订单
+type wechatTransaction struct {
+	AppID         string       `json:"appid"`
+	MchID         string       `json:"mchid"`
+	OutTradeNo    string       `json:"out_trade_no"`
+	TransactionID string       `json:"transaction_id"`
+	TradeState    string       `json:"trade_state"`
+	SuccessTime   string       `json:"success_time"`
+	Amount        wechatAmount `json:"amount"`
+}
+
+// status 微信支付交易状态转换为通用的交易状态
+// 退款后微信的状态为 REFUND，订单仍视为已付款，退款金额以本地记录为准
+func (t wechatTransaction) status() string {
+	switch t.TradeState {
+	case "SUCCESS", "REFUND":
+		return TradeStatusPaid
+	case "CLOSED", "REVOKED", "PAYERROR":
+		return TradeStatusClosed
+	}
+	return TradeStatusWaitPay
+}
+
+// QueryTrade 按商户订单号查询微信支付订单
+func (p *WechatPayProvider) QueryTrade(ctx context.Context, outTradeNo string) (RemoteTrade, error) {
+	var t wechatTransaction
+	path := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(outTradeNo) + "?mchid=" + url.QueryEscape(p.config.MchID)
+	status, err := p.do(ctx, http.MethodGet, path, nil, &t)
+	if status == http.StatusNotFound {
+		return RemoteTrade{}, nil
+	}
+	if err != nil {
+		return RemoteTrade{}, err
+	}
+	return RemoteTrade{
+		Exists:      true,
+		TradeNo:     t.TransactionID,
+		Status:      t.status(),
+		TotalAmount: float64(t.Amount.Total) / 100,
+	}, nil
+}
+
+// CloseTrade 关闭微信支付订单，订单不存在时视为成功
+func (p *WechatPayProvider) CloseTrade(ctx context.Context, outTradeNo string) error {
+	path := "/v3/pay/transactions/out-trade-no/" + url.PathEscape(outTradeNo) + "/close"
+	status, err := p.do(ctx, http.MethodPost, path, map[string]string{"mchid": p.config.MchID}, nil)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// RefundTrade 申请退款，微信支付要求同时传入原订单金额
+func (p *WechatPayProvider) RefundTrade(ctx context.Context, outTradeNo string, refundNo string, amount float64, total float64, reason string) error {
+	body := map[string]interface{}{
+		"out_trade_no":  outTradeNo,
+		"out_refund_no": refundNo,
+		"reason":        reason,
+		"amount":        wechatAmount{Refund: toFen(amount), Total: toFen(total), Currency: "CNY"},
+	}
+	_, err := p.do(ctx, http.MethodPost, "/v3/refund/domestic/refunds", body, nil)
+	return err
+}
+
+// VerifyNotify 校验微信支付通知签名并解密通知内容
+func (p *WechatPayProvider) VerifyNotify(r *http.Request) (PaymentNotify, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return PaymentNotify{}, err
+	}
+	if err := p.verify(r.Header, body); err != nil {
+		return PaymentNotify{}, err
+	}
+
+	var notify struct {
+		EventType string `json:"event_type"`
+		Resource  struct {
+			Algorithm      string `json:"algorithm"`
+			Ciphertext     string `json:"ciphertext"`
+			AssociatedData string `json:"associated_data"`
+			Nonce          string `json:"nonce"`
+		} `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &notify); err != nil {
+		return PaymentNotify{}, err
+	}
+	if notify.Resource.Algorithm != "AEAD_AES_256_GCM" {
+		return PaymentNotify{}, errors.New("不支持的微信支付通知加密算法: " + notify.Resource.Algorithm)
+	}
+	plain, err := p.decrypt(notify.Resource.Ciphertext, notify.Resource.Nonce, notify.Resource.AssociatedData)
+	if err != nil {
+		return PaymentNotify{}, err
+	}
+
+	var t wechatTransaction
+	if err := json.Unmarshal(plain, &t); err != nil {
+		return PaymentNotify{}, err
+	}
+	if t.MchID != p.config.MchID || t.AppID != p.config.AppID {
+		return PaymentNotify{}, ErrWechatPayMchID
+	}
+	paidAt, err := time.Parse(time.RFC3339, t.SuccessTime)
+	if err != nil {
+		paidAt = time.Now()
+	}
+	return PaymentNotify{
+		OrderNo: t.OutTradeNo,
+		TradeNo: t.TransactionID,
+		Status:  t.status(),
+		Amount:  float64(t.Amount.Total) / 100,
+		PaidAt:  paidAt,
+		Payload: string(plain),
+	}, nil
+}
+
+// AckNotify 按微信支付要求应答，失败时返回非 2xx 状态码，微信会重试
+func (p *WechatPayProvider) AckNotify(w http.ResponseWriter, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"SUCCESS","message":"成功"}`))
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(`{"code":"FAIL","message":"失败"}`))
+}
+
+// do 发送签名请求并校验应答签名，out 不为空时解析应答内容
+// 返回应答的 HTTP 状态码，非 2xx 时同时返回错误
+func (p *WechatPayProvider) do(ctx context.Context, method string, path string, in interface{}, out interface{}) (int, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.config.Gateway+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	auth, err := p.authorization(method, path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return rsp.StatusCode, err
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		var e struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &e)
+		return rsp.StatusCode, fmt.Errorf("微信支付请求失败: %d %s %s", rsp.StatusCode, e.Code, e.Message)
+	}
+	if err := p.verify(rsp.Header, data); err != nil {
+		return rsp.StatusCode, err
+	}
+	if out != nil && len(data) > 0 {
+		return rsp.StatusCode, json.Unmarshal(data, out)
+	}
+	return rsp.StatusCode, nil
+}
+
+// authorization 生成请求的 Authorization 头
+// 签名串：请求方法\nURL路径\n时间戳\n随机串\n请求体\n，使用商户私钥 SHA256withRSA 签名
+func (p *WechatPayProvider) authorization(method string, path string, body []byte) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	nonceStr := hex.EncodeToString(nonce)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	message := method + "\n" + path + "\n" + timestamp + "\n" + nonceStr + "\n" + string(body) + "\n"
+	h := sha256.Sum256([]byte(message))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.privateKey, crypto.SHA256, h[:])
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`WECHATPAY2-SHA256-RSA2048 mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		p.config.MchID, nonceStr, base64.StdEncoding.EncodeToString(sig), timestamp, p.config.SerialNo), nil
+}
+
+// verify 使用微信支付公钥校验应答或通知的签名
+// 签名串：时间戳\n随机串\n报文主体\n
+func (p *WechatPayProvider) verify(header http.Header, body []byte) error {
+	if p.config.PlatformSerial != "" && header.Get("Wechatpay-Serial") != p.config.PlatformSerial {
+		return ErrWechatPaySignFail
+	}
+	timestamp := header.Get("Wechatpay-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrWechatPaySignFail
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > wechatPayMaxClockSkew || d < -wechatPayMaxClockSkew {
+		return ErrWechatPayExpired
+	}
+	sig, err := base64.StdEncoding.DecodeString(header.Get("Wechatpay-Signature"))
+	if err != nil {
+		return ErrWechatPaySignFail
+	}
+	message := timestamp + "\n" + header.Get("Wechatpay-Nonce") + "\n" + string(body) + "\n"
+	h := sha256.Sum256([]byte(message))
+	if err := rsa.VerifyPKCS1v15(p.publicKey, crypto.SHA256, h[:], sig); err != nil {
+		return ErrWechatPaySignFail
+	}
+	return nil
+}
+
+// decrypt 使用 APIv3 密钥解密通知内容（AEAD_AES_256_GCM）
+func (p *WechatPayProvider) decrypt(ciphertext string, nonce string, associatedData string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher([]byte(p.config.APIv3Key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("微信支付通知的随机串长度无效")
+	}
+	return gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+}
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// alipaySignContent 按支付宝规则拼接待签名字符串：去掉 ignores 中的参数后按参数名排序
+// 请求只排除 sign，异步通知还要排除 sign_type
+func alipaySignContent(values url.Values, ignores ...string) string {
+	var pairs []string
+	for k, vs := range values {
+		if slices.Contains(ignores, k) {
+			continue
+		}
+		for _, v := range vs {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// alipaySign 模拟支付宝用自己的私钥签名
+func alipaySign(t *testing.T, key *rsa.PrivateKey, values url.Values) {
+	h := sha256.Sum256([]byte(alipaySignContent(values, "sign", "sign_type")))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	values.Set("sign_type", "RSA2")
+	values.Set("sign", base64.StdEncoding.EncodeToString(sig))
+}
+
+// setupFakeAlipay 生成应用和支付宝两对密钥，启动本地模拟网关
+// 模拟网关用应用公钥校验收到的支付请求签名
+func setupFakeAlipay(t *testing.T) (*rsa.PrivateKey, *httptest.Server) {
+	appKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appPri, err := x509.MarshalPKCS8PrivateKey(appKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliPub, err := x509.MarshalPKIXPublicKey(&aliKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+		sig, err := base64.StdEncoding.DecodeString(values.Get("sign"))
+		if err != nil {
+			http.Error(w, "bad sign", http.StatusBadRequest)
+			return
+		}
+		h := sha256.Sum256([]byte(alipaySignContent(values, "sign")))
+		if err := rsa.VerifyPKCS1v15(&appKey.PublicKey, crypto.SHA256, h[:], sig); err != nil {
+			http.Error(w, "sign mismatch", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(gateway.Close)
+
+	if err := utils.InitAlipay(utils.AliPay{
+		AppID:       "2021000000000000",
+		PrivateKey:  base64.StdEncoding.EncodeToString(appPri),
+		PublicKey:   base64.StdEncoding.EncodeToString(aliPub),
+		NotifyUrl:   "http://127.0.0.1/pay/notify",
+		ProductCode: "QUICK_WAP_WAY",
+		Gateway:     gateway.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return aliKey, gateway
+}
+
+func Test_AlipayPaymentOrder(t *testing.T) {
+	_, gateway := setupFakeAlipay(t)
+
+	outTradeNo := models.NewOutTradeNo(models.AssetTypePatent, 12)
+	payURL, err := utils.PaymentOrderCreation("专利年费", outTradeNo, models.FormatFeeAmount(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(payURL, gateway.URL) {
+		t.Fatalf("支付地址没有指向模拟网关: %s", payURL)
+	}
+	resp, err := http.Get(payURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("模拟网关校验签名失败: %d", resp.StatusCode)
+	}
+}
+
+func Test_AlipayNotifyVerify(t *testing.T) {
+	aliKey, _ := setupFakeAlipay(t)
+
+	notify := func() url.Values {
+		v := url.Values{}
+		v.Set("app_id", "2021000000000000")
+		v.Set("notify_id", "n1")
+		v.Set("trade_no", "2024000001")
+		v.Set("out_trade_no", "patent_12_1700000000000")
+		v.Set("trade_status", "TRADE_SUCCESS")
+		v.Set("total_amount", "1.00")
+		v.Set("gmt_payment", "2024-01-02 03:04:05")
+		return v
+	}
+
+	values := notify()
+	alipaySign(t, aliKey, values)
+	n, err := utils.VerifyAlipayNotify(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.OutTradeNo != "patent_12_1700000000000" || !utils.IsAlipayTradePaid(n.TradeStatus) {
+		t.Fatalf("通知解析错误: %+v", n)
+	}
+
+	// 签名后篡改金额
+	values.Set("total_amount", "0.01")
+	if _, err := utils.VerifyAlipayNotify(values); !errors.Is(err, utils.ErrAlipaySignFail) {
+		t.Fatalf("篡改金额后应校验失败, got %v", err)
+	}
+
+	// 签名正确但不是本应用的通知
+	values = notify()
+	values.Set("app_id", "2021000000000001")
+	alipaySign(t, aliKey, values)
+	if _, err := utils.VerifyAlipayNotify(values); !errors.Is(err, utils.ErrAlipayAppID) {
+		t.Fatalf("app_id 不匹配应校验失败, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package tests
+
+import (
+	"intellectual_property/pkg/models"
+	"testing"
+)
+
+func Test_AttachmentDownloadPath(t *testing.T) {
+	a := &models.Attachment{ID: 12, OwnerType: models.AssetTypeTrademark, OwnerID: 3}
+	if got := models.AttachmentDownloadPath(a); got != "/trademark/3/attachments/12/download" {
+		t.Errorf("AttachmentDownloadPath = %s", got)
+	}
+	if got := models.AssetFilePrefix(models.AssetTypePatent, "CN001"); got != "patent/CN001/" {
+		t.Errorf("AssetFilePrefix = %s", got)
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"intellectual_property/pkg/models"
+	"testing"
+)
+
+func Test_ResolveRefundAmount(t *testing.T) {
+	order := models.PaymentOrder{Amount: 100, RefundedAmount: 30, State: models.PaymentStatePaid}
+
+	// 不填金额时退还剩余全部金额，扣除已退款和审批中的金额
+	if got, err := models.ResolveRefundAmount(order, 20, 0); err != nil || got != 50 {
+		t.Errorf("全额退款 = %v, %v, want 50", got, err)
+	}
+	if got, err := models.ResolveRefundAmount(order, 20, 50); err != nil || got != 50 {
+		t.Errorf("恰好等于可退金额 = %v, %v", got, err)
+	}
+	if _, err := models.ResolveRefundAmount(order, 20, 50.01); err != models.ErrRefundExceeds {
+		t.Errorf("超过可退金额 err = %v", err)
+	}
+	if _, err := models.ResolveRefundAmount(order, 70, 0); err != models.ErrRefundExceeds {
+		t.Errorf("没有可退金额 err = %v", err)
+	}
+}
+
+func Test_PlanRefund(t *testing.T) {
+	order := models.PaymentOrder{Amount: 100, RefundedAmount: 0.1, State: models.PaymentStatePaid}
+
+	// 部分退款：订单仍为已支付，费用状态不变
+	outcome, err := models.PlanRefund(order, 39.9)
+	if err != nil || outcome.RefundedAmount != 40 || outcome.OrderState != models.PaymentStatePaid || outcome.FeeRefunded {
+		t.Errorf("部分退款 = %+v, %v", outcome, err)
+	}
+
+	// 全额退款：订单改为已退款，费用改为已退款
+	order.RefundedAmount = 40
+	outcome, err = models.PlanRefund(order, 60)
+	if err != nil || outcome.RefundedAmount != 100 || outcome.OrderState != models.PaymentStateRefunded || !outcome.FeeRefunded {
+		t.Errorf("全额退款 = %+v, %v", outcome, err)
+	}
+
+	if _, err := models.PlanRefund(order, 60.01); err != models.ErrRefundExceeds {
+		t.Errorf("超额退款 err = %v", err)
+	}
+	if _, err := models.PlanRefund(order, 0); err != models.ErrFeeAdjustAmount {
+		t.Errorf("零金额 err = %v", err)
+	}
+}
+
+func Test_PlanRefundDuplicateOrder(t *testing.T) {
+	// 重复支付的订单没有计入费用，全额退款不改变费用状态
+	order := models.PaymentOrder{Amount: 100, State: models.PaymentStatePaid, Remark: "费用已由其他订单支付，需要退款"}
+	if !order.NeedsRefund() {
+		t.Fatal("有备注的订单应需要退款")
+	}
+	outcome, err := models.PlanRefund(order, 100)
+	if err != nil || outcome.OrderState != models.PaymentStateRefunded || outcome.FeeRefunded {
+		t.Errorf("重复支付退款 = %+v, %v", outcome, err)
+	}
+}
+
+func Test_PlanWaiver(t *testing.T) {
+	// 免除：应缴全额含滞纳金，评审费不变
+	waived, fee, err := models.PlanWaiver(models.FeeAdjustWaiver, 800, 40.5, 0)
+	if err != nil || waived != 840.5 || fee != 800 {
+		t.Errorf("免除 = %v %v %v", waived, fee, err)
+	}
+
+	// 减免：扣减评审费
+	waived, fee, err = models.PlanWaiver(models.FeeAdjustReduction, 800, 40.5, 300.25)
+	if err != nil || waived != 300.25 || fee != 499.75 {
+		t.Errorf("减免 = %v %v %v", waived, fee, err)
+	}
+	for _, amount := range []float64{0, -1, 800, 900} {
+		if _, _, err := models.PlanWaiver(models.FeeAdjustReduction, 800, 0, amount); err != models.ErrFeeAdjustAmount {
+			t.Errorf("减免 %v err = %v", amount, err)
+		}
+	}
+	if _, _, err := models.PlanWaiver(models.FeeAdjustRefund, 800, 0, 0); err != models.ErrFeeAdjustInvalidKind {
+		t.Errorf("无效类型 err = %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package tests
+
+import (
+	"intellectual_property/pkg/models"
+	"testing"
+)
+
+func Test_FeeQueryNormalize(t *testing.T) {
+	q := models.FeeQuery{PageSize: 1000}
+	if err := q.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if q.Sort != "payment_deadline" || q.Order != "asc" || q.Page != 1 || q.PageSize != 100 {
+		t.Errorf("默认值错误: %+v", q)
+	}
+
+	q = models.FeeQuery{Sort: "amount", Order: "DESC"}
+	if err := q.Normalize(); err != nil || q.Order != "desc" {
+		t.Errorf("排序参数: %v %+v", err, q)
+	}
+
+	for _, bad := range []models.FeeQuery{
+		{Sort: "review_fee; DROP TABLE users"},
+		{Order: "sideways"},
+		{AssetType: "copyright"},
+	} {
+		if err := bad.Normalize(); err != models.ErrFeeQuery {
+			t.Errorf("%+v 应返回 ErrFeeQuery, got %v", bad, err)
+		}
+	}
+}
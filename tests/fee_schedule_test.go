@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"testing"
+	"time"
+)
+
+func Test_AnnuityAmountForYear(t *testing.T) {
+	rule := utils.FeeScheduleConfig.Invention
+	cases := map[int]float64{1: 900, 3: 900, 4: 1200, 10: 4000, 20: 8000, 21: 0}
+	for year, want := range cases {
+		if got := rule.AmountForYear(year); got != want {
+			t.Errorf("AmountForYear(%d) = %v, want %v", year, got, want)
+		}
+	}
+}
+
+func Test_BuildAnnuitySchedule(t *testing.T) {
+	apply := time.Date(2020, 3, 15, 0, 0, 0, 0, time.Local)
+	grant := time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local)
+
+	// 发明专利：授权时处于第3年度，生成第3到第20年的年费
+	fees := models.BuildAnnuitySchedule(utils.FeeScheduleConfig.Invention, apply, grant)
+	if len(fees) != 18 {
+		t.Fatalf("年费数量 = %d, want 18", len(fees))
+	}
+	first, last := fees[0], fees[len(fees)-1]
+	if first.Year != 3 || !first.Deadline.Equal(grant.AddDate(0, 1, 0)) || first.Amount != 900 {
+		t.Errorf("授权当年年费错误: %+v", first)
+	}
+	if fees[1].Year != 4 || !fees[1].Deadline.Equal(apply.AddDate(3, 0, 0)) || fees[1].Amount != 1200 {
+		t.Errorf("第4年年费错误: %+v", fees[1])
+	}
+	if last.Year != 20 || last.Amount != 8000 || last.Kind != models.FeeKindAnnuity {
+		t.Errorf("第20年年费错误: %+v", last)
+	}
+
+	// 实用新型和外观设计的保护期限
+	if n := len(models.BuildAnnuitySchedule(utils.FeeScheduleConfig.Utility, apply, apply)); n != 10 {
+		t.Errorf("实用新型年费数量 = %d, want 10", n)
+	}
+	if n := len(models.BuildAnnuitySchedule(utils.FeeScheduleConfig.Design, apply, apply)); n != 15 {
+		t.Errorf("外观设计年费数量 = %d, want 15", n)
+	}
+}
+
+func Test_BuildRenewalSchedule(t *testing.T) {
+	grant := time.Date(2022, 6, 1, 0, 0, 0, 0, time.Local)
+	rule := utils.RenewalRule{PeriodYears: 10, Periods: 2, Amount: 500}
+	fees := models.BuildRenewalSchedule(rule, grant)
+	if len(fees) != 2 {
+		t.Fatalf("续展费数量 = %d, want 2", len(fees))
+	}
+	if !fees[0].Deadline.Equal(grant.AddDate(10, 0, 0)) || !fees[1].Deadline.Equal(grant.AddDate(20, 0, 0)) {
+		t.Errorf("续展期限错误: %+v", fees)
+	}
+	if fees[1].Year != 2 || fees[1].Amount != 500 || fees[1].Kind != models.FeeKindRenewal {
+		t.Errorf("第2次续展费错误: %+v", fees[1])
+	}
+}
+
+func Test_FeeOverdueSurcharge(t *testing.T) {
+	policy := utils.FeeOverdue{GraceMonths: 6, SurchargeRate: 0.05, SurchargeMaxRate: 0.25}
+	deadline := time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local)
+	cases := []struct {
+		now  time.Time
+		want float64
+	}{
+		{deadline.AddDate(0, 0, 20), 0},   // 不足一个月不加收
+		{deadline.AddDate(0, 1, 0), 45},   // 满一个月 5%
+		{deadline.AddDate(0, 3, 10), 135}, // 满三个月 15%
+		{deadline.AddDate(0, 5, 28), 225}, // 上限 25%
+	}
+	for _, c := range cases {
+		if got := policy.Surcharge(900, deadline, c.now); got != c.want {
+			t.Errorf("Surcharge(900, %s) = %v, want %v", c.now.Format("2006-01-02"), got, c.want)
+		}
+	}
+	if got := policy.LapseAt(deadline); !got.Equal(deadline.AddDate(0, 6, 0)) {
+		t.Errorf("LapseAt = %v", got)
+	}
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"intellectual_property/pkg/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_ParseStatsPeriod(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 0, 0, 0, time.Local)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.Local) }
+	cases := []struct {
+		name                              string
+		period, value, from, to, granular string
+		wantFrom, wantTo                  time.Time
+		wantGranularity                   string
+		wantBuckets                       int
+	}{
+		{"默认本月按天", "", "", "", "", "", day(2026, 10, 1), day(2026, 11, 1), models.StatsGranularityDay, 31},
+		{"指定月份", "month", "2026-02", "", "", "", day(2026, 2, 1), day(2026, 3, 1), models.StatsGranularityDay, 28},
+		{"季度按月", "quarter", "2026-Q3", "", "", "", day(2026, 7, 1), day(2026, 10, 1), models.StatsGranularityMonth, 3},
+		{"本年按季度", "year", "", "", "", "quarter", day(2026, 1, 1), day(2027, 1, 1), models.StatsGranularityQuarter, 4},
+		{"自定义区间包含结束日", "custom", "", "2026-03-15", "2026-04-10", "", day(2026, 3, 15), day(2026, 4, 11), models.StatsGranularityDay, 27},
+		{"自定义长区间按月", "custom", "", "2025-11-20", "2026-03-01", "", day(2025, 11, 20), day(2026, 3, 2), models.StatsGranularityMonth, 5},
+	}
+	for _, c := range cases {
+		p, err := models.ParseStatsPeriod(c.period, c.value, c.from, c.to, c.granular, now)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !p.From.Equal(c.wantFrom) || !p.To.Equal(c.wantTo) || p.Granularity != c.wantGranularity {
+			t.Errorf("%s: got %v ~ %v %s", c.name, p.From, p.To, p.Granularity)
+		}
+		if n := len(p.Buckets()); n != c.wantBuckets {
+			t.Errorf("%s: buckets = %d, want %d", c.name, n, c.wantBuckets)
+		}
+	}
+
+	p, _ := models.ParseStatsPeriod("year", "2026", "", "", "quarter", now)
+	if got := p.Buckets(); !reflect.DeepEqual(got, []string{"2026-Q1", "2026-Q2", "2026-Q3", "2026-Q4"}) {
+		t.Errorf("季度分组 = %v", got)
+	}
+
+	for _, bad := range [][5]string{
+		{"month", "2026/10", "", "", ""},
+		{"quarter", "2026-Q5", "", "", ""},
+		{"custom", "", "2026-05-01", "2026-04-01", ""},
+		{"custom", "", "2020-01-01", "2026-01-01", "day"},
+		{"week", "", "", "", ""},
+		{"month", "", "", "", "hour"},
+	} {
+		if _, err := models.ParseStatsPeriod(bad[0], bad[1], bad[2], bad[3], bad[4], now); err == nil {
+			t.Errorf("%v 应返回错误", bad)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"intellectual_property/pkg/models"
+	"testing"
+	"time"
+)
+
+func Test_PickFeeTariff(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.Local)
+	tariffs := []models.FeeTariff{
+		{ID: 1, SubType: models.WorkflowAnySubType, YearFrom: 1, YearTo: 20, Amount: 500, EffectiveFrom: now.AddDate(-5, 0, 0)},
+		{ID: 2, SubType: models.PatentInvention, YearFrom: 1, YearTo: 3, Amount: 900, EffectiveFrom: now.AddDate(-5, 0, 0)},
+		{ID: 3, SubType: models.PatentInvention, YearFrom: 1, YearTo: 3, Amount: 950, EffectiveFrom: now.AddDate(-1, 0, 0)},
+		{ID: 4, SubType: models.PatentInvention, YearFrom: 1, YearTo: 3, Amount: 1000, EffectiveFrom: now.AddDate(0, 1, 0)},
+	}
+	cases := []struct {
+		name    string
+		subType int
+		year    int
+		at      time.Time
+		wantID  int
+	}{
+		{"精确子类型优先且取已生效的最新标准", models.PatentInvention, 2, now, 3},
+		{"未来生效的标准到期后使用", models.PatentInvention, 2, now.AddDate(0, 2, 0), 4},
+		{"按历史时间取当时的标准", models.PatentInvention, 2, now.AddDate(-2, 0, 0), 2},
+		{"年度不在区间时退回全部子类型", models.PatentInvention, 5, now, 1},
+		{"其他子类型使用全部子类型", models.AppearanceDesign, 2, now, 1},
+	}
+	for _, c := range cases {
+		got, ok := models.PickFeeTariff(tariffs, c.subType, c.year, c.at)
+		if !ok || got.ID != c.wantID {
+			t.Errorf("%s: got id %d (ok=%v), want %d", c.name, got.ID, ok, c.wantID)
+		}
+	}
+	if _, ok := models.PickFeeTariff(tariffs, models.PatentInvention, 21, now); ok {
+		t.Error("超出所有年度区间时不应匹配")
+	}
+}
+
+func Test_FeeDiscount(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	d := models.FeeDiscount{Code: "small_entity", PayRatio: 0.3, FeeKinds: "review, annuity",
+		EffectiveFrom: now.AddDate(-1, 0, 0), Enabled: true}
+	if !d.Applies(models.AssetTypePatent, models.FeeKindAnnuity, now) {
+		t.Error("年费应适用减缴")
+	}
+	if d.Applies(models.AssetTypeTrademark, models.FeeKindRenewal, now) {
+		t.Error("续展费不在适用范围内")
+	}
+	d.EffectiveTo = &expired
+	if d.Applies(models.AssetTypePatent, models.FeeKindReview, now) {
+		t.Error("已失效的减缴政策不应适用")
+	}
+	if got := models.ApplyFeeDiscount(900, 0.3); got != 270 {
+		t.Errorf("ApplyFeeDiscount(900, 0.3) = %v, want 270", got)
+	}
+	if got := models.ApplyFeeDiscount(1, 0.15); got != 0.15 {
+		t.Errorf("ApplyFeeDiscount(1, 0.15) = %v, want 0.15", got)
+	}
+}
+
+func Test_FeeDiscountEligible(t *testing.T) {
+	d := models.FeeDiscount{Code: "small_entity", UserIDs: "12, 15", DepIDs: "3"}
+	if !d.Eligible(15, 7) {
+		t.Error("名单中的用户应可使用")
+	}
+	if !d.Eligible(20, 3) {
+		t.Error("名单中单位的用户应可使用")
+	}
+	if d.Eligible(20, 7) || d.Eligible(1, 0) {
+		t.Error("不在名单中的用户不应可使用")
+	}
+	if (models.FeeDiscount{}).Eligible(12, 3) {
+		t.Error("没有名单的减缴政策任何人都不能使用")
+	}
+}
@@ -0,0 +1,26 @@
+package tests
+
+import (
+	"intellectual_property/pkg/utils"
+	"testing"
+)
+
+func Test_IsPublicRoute(t *testing.T) {
+	old := utils.GinConfig.PublicRoutes
+	defer func() { utils.GinConfig.PublicRoutes = old }()
+	utils.GinConfig.PublicRoutes = []string{"/login", "/pay/*"}
+
+	cases := map[string]bool{
+		"/login":          true,
+		"/login/x":        false,
+		"/pay":            true,
+		"/pay/tosuccess":  true,
+		"/payment":        false,
+		"/patent/get_all": false,
+	}
+	for path, want := range cases {
+		if got := utils.IsPublicRoute(path); got != want {
+			t.Errorf("IsPublicRoute(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"intellectual_property/pkg/utils"
+	"testing"
+	"time"
+)
+
+func Test_LockDuration(t *testing.T) {
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{8, 8 * time.Minute},
+		{20, time.Hour},
+	}
+	for _, c := range cases {
+		if got := utils.LockDuration(c.failures, 5, time.Minute, time.Hour); got != c.want {
+			t.Errorf("LockDuration(%d) = %v, want %v", c.failures, got, c.want)
+		}
+	}
+}
+
+func Test_TruncateString(t *testing.T) {
+	cases := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"Mozilla/5.0", 255, "Mozilla/5.0"},
+		{"Mozilla/5.0", 7, "Mozilla"},
+		{"浏览器标识", 3, "浏览器"},
+		{"a\xffb", 3, "a\uFFFDb"},
+		{"", 3, ""},
+	}
+	for _, c := range cases {
+		if got := utils.TruncateString(c.in, c.n); got != c.want {
+			t.Errorf("TruncateString(%q, %d) = %q, want %q", c.in, c.n, got, c.want)
+		}
+	}
+}
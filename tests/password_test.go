@@ -16,3 +16,49 @@ func Test_GetSlat(t *testing.T) {
 		t.Log(utils.GetSlat(s[i]))
 	}
 }
+
+func Test_HashPassword(t *testing.T) {
+	hashed, err := utils.HashPassword("abc12345")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, rehash := utils.CheckPassword("abc12345", hashed); !ok || rehash {
+		t.Fatalf("CheckPassword = %v, %v, want true, false", ok, rehash)
+	}
+	if ok, _ := utils.CheckPassword("abc12346", hashed); ok {
+		t.Fatal("错误的密码通过了校验")
+	}
+}
+
+func Test_CheckLegacyPassword(t *testing.T) {
+	salt, err := utils.GenerateSecureSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := utils.SecureHashWithSalt("abcd1234", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := salt + ":" + hash
+	if ok, rehash := utils.CheckPassword("abcd1234", stored); !ok || !rehash {
+		t.Fatalf("CheckPassword = %v, %v, want true, true", ok, rehash)
+	}
+	if ok, _ := utils.CheckPassword("abcd1235", stored); ok {
+		t.Fatal("错误的密码通过了校验")
+	}
+}
+
+func Test_ValidatePassword(t *testing.T) {
+	cases := map[string]bool{
+		"abc12345":      true,
+		"abc1234":       false, // 太短
+		"abcdefgh":      false, // 没有数字
+		"12345678":      false, // 没有小写字母
+		"longer pass 9": true,
+	}
+	for pw, want := range cases {
+		if err := utils.ValidatePassword(pw); (err == nil) != want {
+			t.Errorf("ValidatePassword(%q) = %v, want ok=%v", pw, err, want)
+		}
+	}
+}
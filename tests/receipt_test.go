@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"bytes"
+	"intellectual_property/pkg/models"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_RenderReceiptPDF(t *testing.T) {
+	pdf := models.RenderReceiptPDF(&models.Receipt{
+		ReceiptNo:         "R20261018000001",
+		AssetType:         models.AssetTypePatent,
+		PayerName:         "张三",
+		ApplicationNumber: "CN202600001",
+		FeeName:           models.FeeDisplayName(models.FeeKindAnnuity, 3),
+		Amount:            900,
+		PaidAt:            time.Date(2026, 10, 18, 10, 0, 0, 0, time.Local),
+	})
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("PDF 头尾格式错误")
+	}
+	// "张三" 的 UCS-2 编码
+	if !bytes.Contains(pdf, []byte("<5F204E09>")) {
+		t.Error("缺少缴费人姓名")
+	}
+
+	// startxref 指向 xref 表，且每个对象的偏移量正确
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("缺少 startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatal("startxref 偏移量错误")
+	}
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, o := range offsets {
+		off, _ := strconv.Atoi(string(o[1]))
+		if !bytes.HasPrefix(pdf[off:], []byte(strconv.Itoa(i+1)+" 0 obj")) {
+			t.Errorf("对象 %d 偏移量错误", i+1)
+		}
+	}
+}
+
+func Test_CheckReceiptFee(t *testing.T) {
+	cases := []struct {
+		name     string
+		isPaid   bool
+		status   int
+		refunded float64
+		want     error
+	}{
+		{"已支付", true, models.FeeStatusPaid, 0, nil},
+		{"未支付", false, models.FeeStatusPending, 0, models.ErrReceiptFeeNotPaid},
+		{"已免除", false, models.FeeStatusWaived, 0, models.ErrReceiptFeeNotPaid},
+		{"部分退款", true, models.FeeStatusPaid, 0.01, models.ErrReceiptFeeRefunded},
+		{"全额退款", false, models.FeeStatusRefunded, 100, models.ErrReceiptFeeRefunded},
+	}
+	for _, c := range cases {
+		if err := models.CheckReceiptFee(c.isPaid, c.status, c.refunded); err != c.want {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"testing"
+)
+
+// mockQuerier 模拟支付渠道交易查询
+type mockQuerier map[string]utils.RemoteTrade
+
+func (m mockQuerier) QueryTrade(ctx context.Context, outTradeNo string) (utils.RemoteTrade, error) {
+	if outTradeNo == "error" {
+		return utils.RemoteTrade{}, errors.New("network error")
+	}
+	return m[outTradeNo], nil
+}
+
+func Test_PaymentTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.PaymentStateCreated, models.PaymentStatePaid, true},
+		{models.PaymentStateCreated, models.PaymentStateClosed, true},
+		{models.PaymentStatePaid, models.PaymentStateRefunded, true},
+		{models.PaymentStatePaid, models.PaymentStateClosed, false},
+		{models.PaymentStateClosed, models.PaymentStatePaid, false},
+		{models.PaymentStateRefunded, models.PaymentStatePaid, false},
+	}
+	for _, c := range cases {
+		if got := models.CanTransitionPayment(c.from, c.to); got != c.want {
+			t.Errorf("CanTransitionPayment(%s, %s) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func Test_ReconcilePaymentOrders(t *testing.T) {
+	paid := func(tradeNo string, amount float64) utils.RemoteTrade {
+		return utils.RemoteTrade{Exists: true, TradeNo: tradeNo, Status: utils.TradeStatusPaid, TotalAmount: amount}
+	}
+	orders := []models.PaymentOrder{
+		{Channel: utils.PaymentChannelAlipay, OrderNo: "ok_paid", State: models.PaymentStatePaid, Amount: 100, TradeNo: "t1"},
+		{Channel: utils.PaymentChannelAlipay, OrderNo: "ok_created", State: models.PaymentStateCreated, Amount: 100},
+		{Channel: utils.PaymentChannelAlipay, OrderNo: "missing_notify", State: models.PaymentStateCreated, Amount: 100},
+		{Channel: utils.PaymentChannelAlipay, OrderNo: "remote_closed", State: models.PaymentStateCreated, Amount: 100},
+		{Channel: utils.PaymentChannelAlipay, OrderNo: "remote_unpaid", State: models.PaymentStatePaid, Amount: 100, TradeNo: "t5"},
+		{Channel: utils.PaymentChannelAlipay, OrderNo: "closed_paid", State: models.PaymentStateClosed, Amount: 100},
+		{Channel: utils.PaymentChannelAlipay, OrderNo: "amount", State: models.PaymentStatePaid, Amount: 100, TradeNo: "t7"},
+		{Channel: utils.PaymentChannelAlipay, OrderNo: "trade_no", State: models.PaymentStatePaid, Amount: 100, TradeNo: "t8"},
+		{Channel: utils.PaymentChannelAlipay, OrderNo: "error", State: models.PaymentStateCreated, Amount: 100},
+		// 线下转账不参与对账
+		{Channel: utils.PaymentChannelOffline, OrderNo: "offline", State: models.PaymentStatePaid, Amount: 100},
+	}
+	querier := mockQuerier{
+		"ok_paid":        paid("t1", 100),
+		"missing_notify": paid("t3", 100),
+		"remote_closed":  {Exists: true, Status: utils.TradeStatusClosed, TotalAmount: 100},
+		"closed_paid":    paid("t6", 100),
+		"amount":         paid("t7", 0.01),
+		"trade_no":       paid("t9", 100),
+	}
+
+	want := map[string]string{
+		"missing_notify": models.MismatchMissingNotify,
+		"remote_closed":  models.MismatchRemoteClosed,
+		"remote_unpaid":  models.MismatchRemoteUnpaid,
+		"closed_paid":    models.MismatchClosedPaid,
+		"amount":         models.MismatchAmount,
+		"trade_no":       models.MismatchTradeNo,
+		"error":          models.MismatchQueryFailed,
+	}
+	mismatches := models.ReconcilePaymentOrders(context.Background(), orders, map[string]utils.TradeQuerier{
+		utils.PaymentChannelAlipay: querier,
+	})
+	if len(mismatches) != len(want) {
+		t.Fatalf("差异数量 = %d, want %d: %+v", len(mismatches), len(want), mismatches)
+	}
+	for _, m := range mismatches {
+		if want[m.Order.OrderNo] != m.Kind {
+			t.Errorf("%s 差异类型 = %s, want %s", m.Order.OrderNo, m.Kind, want[m.Order.OrderNo])
+		}
+	}
+}
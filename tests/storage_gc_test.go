@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_PlanStorageGC(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	old := now.Add(-48 * time.Hour)
+	recent := now.Add(-time.Minute)
+	objects := []utils.StorageObject{
+		// 现有申请：已登记的附件、旧版附件、没有记录的附件和收据、刚上传的附件
+		{Key: "patent/CN2026001/attachments/a1/说明书.pdf", ModTime: old},
+		{Key: "patent/CN2026001/旧附件.pdf", ModTime: old},
+		{Key: "patent/CN2026001/attachments/b2/附图.png", ModTime: old},
+		{Key: "patent/CN2026001/receipts/R001.pdf", ModTime: old},
+		{Key: "patent/CN2026001/attachments/c3/新文件.pdf", ModTime: recent},
+		// 已删除的申请
+		{Key: "article/CN2025009/attachments/d4/a.pdf", ModTime: old},
+		{Key: "article/CN2025009/receipts/R002.pdf", ModTime: old},
+		// 正在创建、尚未提交事务的申请
+		{Key: "trademark/CN2026002/attachments/e5/logo.png", ModTime: recent},
+		// 其他目录不处理
+		{Key: "avatar/1-123.png", ModTime: old},
+		{Key: "quarantine/20261001/patent/1-x.pdf", ModTime: old},
+	}
+	assetDirs := map[string]bool{"patent/CN2026001/": true}
+	referenced := map[string]bool{"patent/CN2026001/attachments/a1/说明书.pdf": true}
+
+	got := models.PlanStorageGC(objects, assetDirs, referenced, 24*time.Hour, now)
+	want := []models.StorageGarbage{
+		{Kind: models.GarbageOrphanDir, Key: "article/CN2025009/", Detail: "没有对应的申请，2 个文件"},
+		{Kind: models.GarbageOrphanFile, Key: "patent/CN2026001/attachments/b2/附图.png", Detail: "没有附件或收据记录引用"},
+		{Kind: models.GarbageOrphanFile, Key: "patent/CN2026001/receipts/R001.pdf", Detail: "没有附件或收据记录引用"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PlanStorageGC =\n%+v\nwant\n%+v", got, want)
+	}
+}
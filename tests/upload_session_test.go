@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"intellectual_property/pkg/models"
+	"intellectual_property/pkg/utils"
+	"io"
+	"testing"
+)
+
+func Test_UploadChunkLength(t *testing.T) {
+	cases := []struct {
+		size, chunkSize int64
+		chunks          int
+		last            int64
+	}{
+		{10, 4, 3, 2},
+		{8, 4, 2, 4},
+		{1, 4, 1, 1},
+	}
+	for _, c := range cases {
+		s := &models.UploadSession{Size: c.size, ChunkSize: c.chunkSize, TotalChunks: models.UploadChunkCount(c.size, c.chunkSize)}
+		if s.TotalChunks != c.chunks {
+			t.Errorf("UploadChunkCount(%d, %d) = %d, want %d", c.size, c.chunkSize, s.TotalChunks, c.chunks)
+			continue
+		}
+		if got := s.ChunkLength(0); c.chunks > 1 && got != c.chunkSize {
+			t.Errorf("size %d: ChunkLength(0) = %d", c.size, got)
+		}
+		if got := s.ChunkLength(c.chunks - 1); got != c.last {
+			t.Errorf("size %d: 最后一片 = %d, want %d", c.size, got, c.last)
+		}
+		if s.ChunkLength(-1) != 0 || s.ChunkLength(c.chunks) != 0 {
+			t.Errorf("size %d: 无效序号应返回0", c.size)
+		}
+	}
+	if models.UploadChunkCount(0, 4) != 0 {
+		t.Error("空文件分片数应为0")
+	}
+}
+
+func Test_ValidateResumableUpload(t *testing.T) {
+	saved := utils.UploadConfig
+	t.Cleanup(func() { utils.UploadConfig = saved })
+	utils.UploadConfig = utils.UploadSettings{
+		Policies: map[string]utils.UploadPolicy{
+			"article": {MaxSize: 16, Extensions: []string{".pdf"}, MimeTypes: []string{"application/pdf"}},
+		},
+		Resumable: utils.ResumableSettings{MaxSize: 1024},
+	}
+	content := append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("x"), 100)...)
+	open := func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	// 超过类别限制，但在分片上传限制以内
+	checked, err := utils.ValidateResumableUpload(context.Background(), "article", "../书稿.pdf", int64(len(content)), open)
+	if err != nil || checked.Name != "书稿.pdf" || checked.MimeType != "application/pdf" {
+		t.Errorf("ValidateResumableUpload = %+v, %v", checked, err)
+	}
+	if _, err := utils.ValidateResumableUpload(context.Background(), "article", "书稿.pdf", 2048, open); !errors.Is(err, utils.ErrUploadTooLarge) {
+		t.Errorf("超出分片上传限制 err = %v", err)
+	}
+	if _, err := utils.ValidateResumableUpload(context.Background(), "article", "书稿.zip", int64(len(content)), open); !errors.Is(err, utils.ErrUploadType) {
+		t.Errorf("扩展名不允许 err = %v", err)
+	}
+
+	policy, err := utils.ResumablePolicy("article")
+	if err != nil || policy.CheckName("书稿.pdf", 1024) != nil || !errors.Is(policy.CheckName("书稿.pdf", 1025), utils.ErrUploadTooLarge) {
+		t.Errorf("ResumablePolicy = %+v, %v", policy, err)
+	}
+}
@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"intellectual_property/pkg/utils"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func Test_SanitizeFileName(t *testing.T) {
+	cases := map[string]string{
+		"说明书.pdf":                         "说明书.pdf",
+		`C:\Users\张三\附图 1.png`:            "附图 1.png",
+		"../../etc/passwd":                "passwd",
+		"dir/sub/":                        "sub",
+		"a<b>:c|d?.pdf":                   "a_b__c_d_.pdf",
+		" .hidden. ":                      "hidden",
+		"x\x00y\r\n.txt":                  "xy.txt",
+		"..":                              "",
+		"":                                "",
+		"/":                               "",
+		strings.Repeat("长", 100) + ".pdf": strings.Repeat("长", 65) + ".pdf",
+	}
+	for in, want := range cases {
+		if got := utils.SanitizeFileName(in); got != want {
+			t.Errorf("SanitizeFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func Test_UploadPolicyCheck(t *testing.T) {
+	p := utils.UploadPolicy{
+		MaxSize:    1024,
+		Extensions: []string{".pdf", ".png"},
+		MimeTypes:  []string{"application/pdf", "image/png"},
+	}
+	cases := []struct {
+		name        string
+		size        int64
+		contentType string
+		want        error
+	}{
+		{"a.pdf", 100, "application/pdf", nil},
+		{"A.PDF", 100, "application/pdf", nil},
+		{"a.pdf", 2048, "application/pdf", utils.ErrUploadTooLarge},
+		{"a.exe", 100, "application/pdf", utils.ErrUploadType},
+		// 扩展名伪装，按内容识别出的类型不在允许范围内
+		{"a.png", 100, "text/html; charset=utf-8", utils.ErrUploadType},
+		{"noext", 100, "application/pdf", utils.ErrUploadType},
+		// 类型在允许范围内，但与扩展名不对应
+		{"a.png", 100, "application/pdf", utils.ErrUploadType},
+	}
+	for _, c := range cases {
+		if err := p.Check(c.name, c.size, c.contentType); !errors.Is(err, c.want) {
+			t.Errorf("Check(%q, %d, %q) = %v, want %v", c.name, c.size, c.contentType, err, c.want)
+		}
+	}
+
+	// 无法识别的内容只允许旧版 Word 文档
+	doc := utils.UploadPolicy{
+		Extensions: []string{".pdf", ".doc", ".zip"},
+		MimeTypes:  []string{"application/pdf", "application/zip", "application/octet-stream"},
+	}
+	for name, want := range map[string]error{"a.doc": nil, "a.pdf": utils.ErrUploadType, "a.zip": utils.ErrUploadType} {
+		if err := doc.Check(name, 100, "application/octet-stream"); !errors.Is(err, want) {
+			t.Errorf("Check(%q, octet-stream) = %v, want %v", name, err, want)
+		}
+	}
+}
+
+func Test_UploadBodyLimit(t *testing.T) {
+	saved := utils.UploadConfig
+	t.Cleanup(func() { utils.UploadConfig = saved })
+	utils.UploadConfig = utils.UploadSettings{
+		Policies: map[string]utils.UploadPolicy{
+			"patent":  {MaxSize: 1 << 20},
+			"unlimit": {},
+		},
+	}
+	if got := utils.UploadBodyLimit("patent", 3); got != 4<<20 {
+		t.Errorf("UploadBodyLimit(patent, 3) = %d, want %d", got, 4<<20)
+	}
+	for _, category := range []string{"unlimit", "unknown"} {
+		if got := utils.UploadBodyLimit(category, 1); got != 0 {
+			t.Errorf("UploadBodyLimit(%s) = %d, want 0", category, got)
+		}
+	}
+}
+
+func Test_ReencodeImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var src bytes.Buffer
+	jpeg.Encode(&src, img, nil)
+	// 图片后附加其他内容，重新编码后应被去掉
+	src.WriteString("<?php echo 1; ?>")
+
+	out, err := utils.ReencodeImage(bytes.NewReader(src.Bytes()), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bounds().Dx() != 8 || decoded.Bounds().Dy() != 4 {
+		t.Errorf("尺寸 = %v", decoded.Bounds())
+	}
+	if bytes.Contains(out, []byte("<?php")) {
+		t.Error("附加内容未被去掉")
+	}
+
+	if _, err := utils.ReencodeImage(bytes.NewReader(src.Bytes()), 16); !errors.Is(err, utils.ErrUploadImage) {
+		t.Errorf("超出像素限制 err = %v", err)
+	}
+	if _, err := utils.ReencodeImage(strings.NewReader("not an image"), 1000); !errors.Is(err, utils.ErrUploadImage) {
+		t.Errorf("非图片 err = %v", err)
+	}
+}
+
+func Test_ParseClamdReply(t *testing.T) {
+	if r, err := utils.ParseClamdReply("stream: OK\x00"); err != nil || r.Infected {
+		t.Errorf("OK = %+v, %v", r, err)
+	}
+	r, err := utils.ParseClamdReply("stream: Eicar-Test-Signature FOUND\x00")
+	if err != nil || !r.Infected || r.Signature != "Eicar-Test-Signature" {
+		t.Errorf("FOUND = %+v, %v", r, err)
+	}
+	if _, err := utils.ParseClamdReply("INSTREAM size limit exceeded. ERROR\x00"); err == nil {
+		t.Error("ERROR 应返回错误")
+	}
+}
+
+// fakeClamd 模拟 clamd 的 INSTREAM 协议，内容包含 EICAR 时报告感染
+func fakeClamd(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var data []byte
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					chunk := make([]byte, size)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+				if bytes.Contains(data, []byte("EICAR-STANDARD-ANTIVIRUS-TEST-FILE")) {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func Test_ClamdScanner(t *testing.T) {
+	scanner, err := utils.NewScanner(utils.ScannerConfig{Driver: utils.ScannerClamd, Address: fakeClamd(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	// 超过一个数据块的正常文件
+	clean := strings.Repeat("a", 200<<10)
+	if r, err := scanner.Scan(ctx, strings.NewReader(clean)); err != nil || r.Infected {
+		t.Errorf("正常文件 = %+v, %v", r, err)
+	}
+	eicar := `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+	r, err := scanner.Scan(ctx, strings.NewReader(eicar))
+	if err != nil || !r.Infected || r.Signature != "Eicar-Test-Signature" {
+		t.Errorf("EICAR = %+v, %v", r, err)
+	}
+
+	if _, err := utils.NewScanner(utils.ScannerConfig{Driver: utils.ScannerClamd}); err == nil {
+		t.Error("未配置地址应返回错误")
+	}
+}
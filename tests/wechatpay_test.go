@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"intellectual_property/pkg/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const wechatAPIv3Key = "0123456789abcdef0123456789abcdef"
+
+// wechatSign 模拟微信支付用平台私钥签名，写入应答或通知的签名头
+func wechatSign(t *testing.T, key *rsa.PrivateKey, header http.Header, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := "nonce123"
+	h := sha256.Sum256([]byte(timestamp + "\n" + nonce + "\n" + string(body) + "\n"))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.Set("Wechatpay-Timestamp", timestamp)
+	header.Set("Wechatpay-Nonce", nonce)
+	header.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(sig))
+	header.Set("Wechatpay-Serial", "PUB_KEY_ID_1")
+}
+
+// setupFakeWechatPay 生成商户和微信支付两对密钥，启动本地模拟网关
+// 模拟网关用商户公钥校验请求签名，用平台私钥签名应答
+func setupFakeWechatPay(t *testing.T) (*utils.WechatPayProvider, *rsa.PrivateKey) {
+	mchKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	platformKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mchPri, err := x509.MarshalPKCS8PrivateKey(mchKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	platformPub, err := x509.MarshalPKIXPublicKey(&platformKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authRe := regexp.MustCompile(`nonce_str="([^"]+)",signature="([^"]+)",timestamp="([^"]+)"`)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		m := authRe.FindStringSubmatch(r.Header.Get("Authorization"))
+		if m == nil {
+			http.Error(w, `{"code":"SIGN_ERROR"}`, http.StatusUnauthorized)
+			return
+		}
+		sig, _ := base64.StdEncoding.DecodeString(m[2])
+		h := sha256.Sum256([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + m[3] + "\n" + m[1] + "\n" + string(body) + "\n"))
+		if err := rsa.VerifyPKCS1v15(&mchKey.PublicKey, crypto.SHA256, h[:], sig); err != nil {
+			http.Error(w, `{"code":"SIGN_ERROR"}`, http.StatusUnauthorized)
+			return
+		}
+
+		var rsp []byte
+		switch r.URL.Path {
+		case "/v3/pay/transactions/native":
+			rsp = []byte(`{"code_url":"weixin://wxpay/bizpayurl?pr=abc"}`)
+		case "/v3/pay/transactions/out-trade-no/patent_1_3/close":
+			// 关单成功返回 204，没有应答内容
+			wechatSign(t, platformKey, w.Header(), nil)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/v3/pay/transactions/out-trade-no/patent_1_1":
+			rsp = []byte(`{"appid":"wx1","mchid":"190000","out_trade_no":"patent_1_1","transaction_id":"4200001","trade_state":"SUCCESS","amount":{"total":10000}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"ORDER_NOT_EXIST","message":"订单不存在"}`))
+			return
+		}
+		wechatSign(t, platformKey, w.Header(), rsp)
+		w.Write(rsp)
+	}))
+	t.Cleanup(gateway.Close)
+
+	p, err := utils.NewWechatPayProvider(utils.WechatPay{
+		MchID:             "190000",
+		AppID:             "wx1",
+		SerialNo:          "MCH_SERIAL",
+		PrivateKey:        base64.StdEncoding.EncodeToString(mchPri),
+		APIv3Key:          wechatAPIv3Key,
+		PlatformPublicKey: base64.StdEncoding.EncodeToString(platformPub),
+		PlatformSerial:    "PUB_KEY_ID_1",
+		NotifyUrl:         "http://127.0.0.1/pay/wechat/notify",
+		Gateway:           gateway.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, platformKey
+}
+
+func Test_WechatPayOrderAndQuery(t *testing.T) {
+	p, _ := setupFakeWechatPay(t)
+	ctx := context.Background()
+
+	prepay, err := p.CreateOrder(ctx, utils.PaymentOrderRequest{OrderNo: "patent_1_1", Subject: "专利年费", Amount: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prepay.PayURL != "weixin://wxpay/bizpayurl?pr=abc" {
+		t.Fatalf("二维码链接错误: %q", prepay.PayURL)
+	}
+
+	trade, err := p.QueryTrade(ctx, "patent_1_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !trade.Exists || trade.Status != utils.TradeStatusPaid || trade.TotalAmount != 100 || trade.TradeNo != "4200001" {
+		t.Fatalf("查询结果错误: %+v", trade)
+	}
+
+	trade, err = p.QueryTrade(ctx, "patent_1_2")
+	if err != nil || trade.Exists {
+		t.Fatalf("不存在的订单应返回 Exists=false, got %+v %v", trade, err)
+	}
+}
+
+func Test_WechatPayCloseTrade(t *testing.T) {
+	p, _ := setupFakeWechatPay(t)
+	ctx := context.Background()
+
+	if err := p.CloseTrade(ctx, "patent_1_3"); err != nil {
+		t.Fatalf("关闭订单失败: %v", err)
+	}
+	// 用户没有扫码时微信中没有订单，视为关闭成功
+	if err := p.CloseTrade(ctx, "patent_1_2"); err != nil {
+		t.Fatalf("不存在的订单应视为关闭成功: %v", err)
+	}
+}
+
+func Test_WechatPayNotifyVerify(t *testing.T) {
+	p, platformKey := setupFakeWechatPay(t)
+
+	// 按微信支付规则用 APIv3 密钥加密通知内容
+	plain := `{"appid":"wx1","mchid":"190000","out_trade_no":"patent_1_1","transaction_id":"4200001","trade_state":"SUCCESS","success_time":"2024-01-02T03:04:05+08:00","amount":{"total":10000}}`
+	block, _ := aes.NewCipher([]byte(wechatAPIv3Key))
+	gcm, _ := cipher.NewGCM(block)
+	nonce := "abcdefghijkl"
+	ciphertext := gcm.Seal(nil, []byte(nonce), []byte(plain), []byte("transaction"))
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":         "EV-1",
+		"event_type": "TRANSACTION.SUCCESS",
+		"resource": map[string]string{
+			"algorithm":       "AEAD_AES_256_GCM",
+			"ciphertext":      base64.StdEncoding.EncodeToString(ciphertext),
+			"associated_data": "transaction",
+			"nonce":           nonce,
+		},
+	})
+
+	newRequest := func(body []byte) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/pay/wechat/notify", bytes.NewReader(body))
+		wechatSign(t, platformKey, r.Header, body)
+		return r
+	}
+
+	notify, err := p.VerifyNotify(newRequest(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notify.OrderNo != "patent_1_1" || notify.TradeNo != "4200001" || notify.Status != utils.TradeStatusPaid || notify.Amount != 100 {
+		t.Fatalf("通知解析错误: %+v", notify)
+	}
+
+	// 签名后篡改报文
+	r := newRequest(body)
+	r.Body = io.NopCloser(bytes.NewReader(bytes.Replace(body, []byte("EV-1"), []byte("EV-2"), 1)))
+	if _, err := p.VerifyNotify(r); !errors.Is(err, utils.ErrWechatPaySignFail) {
+		t.Fatalf("篡改报文后应校验失败, got %v", err)
+	}
+
+	// 过期的签名
+	r = newRequest(body)
+	r.Header.Set("Wechatpay-Timestamp", strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	if _, err := p.VerifyNotify(r); !errors.Is(err, utils.ErrWechatPayExpired) {
+		t.Fatalf("过期签名应校验失败, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"intellectual_property/pkg/models"
+	"testing"
+)
+
+func Test_WorkflowAdvance(t *testing.T) {
+	//发明专利三级审批
+	w, ok := models.DefaultWorkflow(models.AssetTypePatent, models.PatentInvention)
+	if !ok || len(w.Stages) != 3 {
+		t.Fatalf("发明专利应为三级审批, got %+v", w)
+	}
+
+	_, next, status, err := w.Advance(1, true)
+	if err != nil || next != 2 || status != models.ApprovalStatusInProgress {
+		t.Errorf("第一环节通过后应进入第二环节, got next=%d status=%d err=%v", next, status, err)
+	}
+	_, next, status, err = w.Advance(3, true)
+	if err != nil || next != 3 || status != models.ApprovalStatusApproved {
+		t.Errorf("最后环节通过后应审批通过, got next=%d status=%d err=%v", next, status, err)
+	}
+	_, _, status, err = w.Advance(2, false)
+	if err != nil || status != models.ApprovalStatusRejected {
+		t.Errorf("驳回后应为驳回状态, got status=%d err=%v", status, err)
+	}
+	if _, _, _, err = w.Advance(4, true); err == nil {
+		t.Error("不存在的环节应返回错误")
+	}
+}
+
+func Test_DefaultWorkflowSubType(t *testing.T) {
+	//网页资源只有一个环节
+	w, _ := models.DefaultWorkflow(models.AssetTypeArticle, int(models.WebResources))
+	if len(w.Stages) != 1 {
+		t.Errorf("网页资源应为一级审批, got %d", len(w.Stages))
+	}
+	//实用新型使用专利默认流程
+	w, _ = models.DefaultWorkflow(models.AssetTypePatent, models.PracticalInvention)
+	if w.SubType != models.WorkflowAnySubType || len(w.Stages) != 2 {
+		t.Errorf("实用新型应使用默认两级审批, got %+v", w)
+	}
+	if err := w.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	w.Stages[1].Step = 3
+	if err := w.Validate(); err == nil {
+		t.Error("环节序号不连续时应校验失败")
+	}
+}